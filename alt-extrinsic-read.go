@@ -8,20 +8,10 @@ import (
 )
 
 func ReadExtrinsic(c *Connection, blockHash string) {
-	meta, err := c.Api.RPC.State.GetMetadataLatest()
-	checkErr(err)
-
 	hash, err := types.NewHashFromHexString(blockHash)
 	checkErr(err)
 
-	key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
-	checkErr(err)
-
-	raw, err := c.Api.RPC.State.GetStorageRaw(key, hash)
-	checkErr(err)
-
-	events := types.EventRecords{}
-	err = types.EventRecordsRaw(*raw).DecodeEventRecords(meta, &events)
+	events, err := c.GetEvents(hash)
 	checkErr(err)
 
 	fmt.Println("Read Block blockHash: ", hash.Hex())
@@ -30,12 +20,12 @@ func ReadExtrinsic(c *Connection, blockHash string) {
 	block, err := c.Api.RPC.Chain.GetBlock(hash)
 	checkErr(err)
 
-	for _, event := range events.Balances_Transfer {
-		send, _ := subkey.SS58Address(event.From[:], 0)
+	for _, event := range findEvents(events, "Balances.Transfer") {
+		send, _ := subkey.SS58Address(event.AccountField(0), 0)
 		fmt.Printf("from : %+v\n", send)
-		to, _ := subkey.SS58Address(event.To[:], 0)
+		to, _ := subkey.SS58Address(event.AccountField(1), 0)
 		fmt.Printf("to : %+v\n", to)
-		fmt.Printf("value : %+v\n", event.Value)
+		fmt.Printf("value : %+v\n", event.BalanceField(2))
 		fmt.Printf("phase : %+v\n", event.Phase)
 		fmt.Printf("topics : %+v\n", event.Topics)
 
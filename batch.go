@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// BatchMode selects the Utility call that wraps the transfers of a batch.
+type BatchMode int
+
+const (
+	// BatchAll dispatches the transfers atomically - if any transfer fails, none of them are applied.
+	BatchAll BatchMode = iota
+	// Batch dispatches the transfers in order and stops at the first failure. Earlier transfers are applied.
+	Batch
+	// ForceBatch dispatches every transfer, whether or not the others fail.
+	ForceBatch
+)
+
+func (m BatchMode) call() (string, error) {
+	switch m {
+	case BatchAll:
+		return "Utility.batch_all", nil
+	case Batch:
+		return "Utility.batch", nil
+	case ForceBatch:
+		return "Utility.force_batch", nil
+	}
+	return "", fmt.Errorf("unknown batch mode %d", m)
+}
+
+// batchLimitPercent is the share of the block weight and length limits that a single batch extrinsic may use.
+// Batch weights are estimated, so this leaves headroom in case the estimate is low.
+const batchLimitPercent = 75
+
+// errPayoutNotAttempted is the outcome of a payout whose chunk was never included in a block.
+var errPayoutNotAttempted = errors.New("payout not attempted")
+
+// Payout is a single transfer within a batch.
+type Payout struct {
+	// To is the recipient - a hex encoded public key or an SS58 address.
+	To     string
+	Amount uint64
+}
+
+// PayoutResult is the outcome of a single payout.
+type PayoutResult struct {
+	Payout
+	// Chunk is the index of the chunk (see BatchResult.Chunks) that carried the payout, -1 if it was never sent.
+	Chunk         int
+	ExtrinsicHash types.Hash
+	BlockHash     types.Hash
+	Success       bool
+	// Err is the reason that the payout failed. Nil if Success is true.
+	Err error
+}
+
+// BatchChunk is one batch extrinsic, carrying the payouts Start to End-1.
+type BatchChunk struct {
+	Start int
+	End   int
+	// Fee is the fee estimated before the chunk was submitted. The fee actually paid is in the receipt.
+	Fee           *FeeEstimate
+	ExtrinsicHash types.Hash
+	BlockHash     types.Hash
+	Receipt       *Receipt
+}
+
+// BatchResult holds the chunks that were submitted and the outcome of every payout, in the order given.
+type BatchResult struct {
+	Chunks  []BatchChunk
+	Payouts []PayoutResult
+}
+
+// BatchOptions controls how a batch of payouts is built and sent. The TxOptions apply to every chunk - if a
+// Nonce is given it is used for the first chunk and incremented for each following chunk. Each chunk is tracked
+// as set by Track before the next one is submitted.
+type BatchOptions struct {
+	TxOptions
+	Mode  BatchMode
+	Track TrackOptions
+}
+
+// batchLimits are the bounds on a single normal class extrinsic.
+type batchLimits struct {
+	maxWeight uint64
+	maxLength uint64
+	// maxCalls is the Utility.batched_calls_limit, zero if the runtime doesn't set one.
+	maxCalls uint64
+}
+
+// batchCost models the weight and length of a batch extrinsic as a fixed overhead plus a cost per call.
+type batchCost struct {
+	baseWeight uint64
+	callWeight uint64
+	baseLength uint64
+}
+
+// BatchTransfer pays every recipient in payouts from the sender, wrapping Balances.transfer_keep_alive calls in
+// Utility batch calls. Payouts are split into as many chunks as the block weight and length limits require;
+// chunks are submitted one after the other, each once the previous one has been tracked to the point set by
+// opts.Track.
+//
+// An error is returned if a chunk can't be built or submitted. The result is returned alongside it - payouts in
+// chunks that were included have their outcome set, the rest fail with "payout not attempted".
+func (c *Connection) BatchTransfer(sender signature.KeyringPair, payouts []Payout, opts BatchOptions) (*BatchResult, error) {
+	if len(payouts) == 0 {
+		return nil, fmt.Errorf("no payouts to send")
+	}
+	batchCall, err := opts.Mode.call()
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := c.Api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, fmt.Errorf("fetch metadata failed: %w", err)
+	}
+
+	calls := make([]types.Call, len(payouts))
+	callLengths := make([]uint64, len(payouts))
+	for i, p := range payouts {
+		calls[i], err = NewDynamicCall(meta, "Balances.transfer_keep_alive", CallArgs{"dest": p.To, "value": p.Amount})
+		if err != nil {
+			return nil, fmt.Errorf("error building transfer to %s: %w", p.To, err)
+		}
+		encoded, err := types.EncodeToBytes(calls[i])
+		if err != nil {
+			return nil, err
+		}
+		callLengths[i] = uint64(len(encoded))
+	}
+
+	limits, err := batchLimitsFromMetadata(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce uint64
+	if opts.Nonce != nil {
+		nonce = *opts.Nonce
+	} else {
+		nonce, err = c.AccountNextIndex(sender.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cost, err := c.estimateBatchCost(sender, meta, batchCall, calls, nonce, opts.TxOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error estimating batch weight: %w", err)
+	}
+
+	chunks, err := chunkCalls(callLengths, limits, cost)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchResult{Payouts: make([]PayoutResult, len(payouts))}
+	for i, p := range payouts {
+		result.Payouts[i] = PayoutResult{Payout: p, Chunk: -1, Err: errPayoutNotAttempted}
+	}
+
+	for n, chunk := range chunks {
+		call, err := NewDynamicCall(meta, batchCall, CallArgs{"calls": calls[chunk.Start:chunk.End]})
+		if err != nil {
+			return result, fmt.Errorf("error building chunk %d: %w", n, err)
+		}
+
+		txOpts := opts.TxOptions
+		chunkNonce := nonce
+		txOpts.Nonce = &chunkNonce
+		signed, err := c.SignCall(sender, call, txOpts)
+		if err != nil {
+			return result, fmt.Errorf("error signing chunk %d: %w", n, err)
+		}
+		chunk.ExtrinsicHash, err = types.GetHash(signed.Extrinsic)
+		if err != nil {
+			return result, err
+		}
+		chunk.Fee, err = c.EstimateFee(signed.Extrinsic)
+		if err != nil {
+			return result, fmt.Errorf("error estimating fee for chunk %d: %w", n, err)
+		}
+		fmt.Printf("chunk %d: %d payouts, estimated fee (including tip): %s\n", n, chunk.End-chunk.Start, chunk.Fee.Total)
+
+		receipt, err := c.SubmitAndTrack(signed.Extrinsic, opts.Track)
+		if err != nil {
+			return result, fmt.Errorf("chunk %d: %w", n, err)
+		}
+		nonce++
+		chunk.BlockHash = receipt.BlockHash
+		chunk.Receipt = receipt
+		fmt.Printf("chunk %d included in block %#x\n", n, chunk.BlockHash)
+		result.Chunks = append(result.Chunks, chunk)
+
+		outcomes := batchOutcomesFromEvents(receipt.Events, receipt.ExtrinsicIndex, opts.Mode, payouts[chunk.Start:chunk.End])
+		for i, outcome := range outcomes {
+			r := &result.Payouts[chunk.Start+i]
+			r.Chunk = n
+			r.ExtrinsicHash = chunk.ExtrinsicHash
+			r.BlockHash = chunk.BlockHash
+			r.Success = outcome == nil
+			r.Err = outcome
+		}
+	}
+	return result, nil
+}
+
+// blockWeights is frame_system::limits::BlockWeights, up to the weights of the normal dispatch class - the first
+// of per_class.
+type blockWeights struct {
+	BaseBlock Weight
+	MaxBlock  Weight
+	Normal    weightsPerClass
+}
+
+type weightsPerClass struct {
+	BaseExtrinsic Weight
+	MaxExtrinsic  *Weight
+	MaxTotal      *Weight
+	Reserved      *Weight
+}
+
+// decodeBlockWeights decodes System.BlockWeights with the runtime's Weight layout, V1 or V2 - see decodeWeight.
+func decodeBlockWeights(raw []byte, v2 bool) (blockWeights, error) {
+	decoder := scale.NewDecoder(bytes.NewReader(raw))
+	optional := func() (*Weight, error) {
+		some, err := decoder.ReadOneByte()
+		if err != nil || some == 0 {
+			return nil, err
+		}
+		w, err := decodeWeight(decoder, v2)
+		if err != nil {
+			return nil, err
+		}
+		return &w, nil
+	}
+
+	var weights blockWeights
+	var err error
+	if weights.BaseBlock, err = decodeWeight(decoder, v2); err != nil {
+		return blockWeights{}, err
+	}
+	if weights.MaxBlock, err = decodeWeight(decoder, v2); err != nil {
+		return blockWeights{}, err
+	}
+	if weights.Normal.BaseExtrinsic, err = decodeWeight(decoder, v2); err != nil {
+		return blockWeights{}, err
+	}
+	if weights.Normal.MaxExtrinsic, err = optional(); err != nil {
+		return blockWeights{}, err
+	}
+	if weights.Normal.MaxTotal, err = optional(); err != nil {
+		return blockWeights{}, err
+	}
+	if weights.Normal.Reserved, err = optional(); err != nil {
+		return blockWeights{}, err
+	}
+	return weights, nil
+}
+
+// blockLength is frame_system::limits::BlockLength.
+type blockLength struct {
+	Max struct {
+		Normal      types.U32
+		Operational types.U32
+		Mandatory   types.U32
+	}
+}
+
+// batchLimitsFromMetadata reads the limits on a normal class extrinsic from the System and Utility constants,
+// scaled down by batchLimitPercent.
+func batchLimitsFromMetadata(meta *types.Metadata) (batchLimits, error) {
+	r, err := newTypeRegistry(meta)
+	if err != nil {
+		return batchLimits{}, err
+	}
+
+	raw, err := r.constant("System", "BlockWeights")
+	if err != nil {
+		return batchLimits{}, err
+	}
+	weights, err := decodeBlockWeights(raw, r.hasWeightV2())
+	if err != nil {
+		return batchLimits{}, fmt.Errorf("error decoding System.BlockWeights: %w", err)
+	}
+	maxWeight := weights.MaxBlock.RefTime
+	if weights.Normal.MaxExtrinsic != nil {
+		maxWeight = weights.Normal.MaxExtrinsic.RefTime
+	} else if weights.Normal.MaxTotal != nil {
+		maxWeight = weights.Normal.MaxTotal.RefTime
+	}
+
+	raw, err = r.constant("System", "BlockLength")
+	if err != nil {
+		return batchLimits{}, err
+	}
+	var length blockLength
+	if err := types.DecodeFromBytes(raw, &length); err != nil {
+		return batchLimits{}, fmt.Errorf("error decoding System.BlockLength: %w", err)
+	}
+
+	limits := batchLimits{
+		maxWeight: maxWeight / 100 * batchLimitPercent,
+		maxLength: uint64(length.Max.Normal) / 100 * batchLimitPercent,
+	}
+
+	// Older runtimes don't limit the number of calls in a batch.
+	if raw, err := r.constant("Utility", "batched_calls_limit"); err == nil {
+		var maxCalls types.U32
+		if err := types.DecodeFromBytes(raw, &maxCalls); err != nil {
+			return batchLimits{}, fmt.Errorf("error decoding Utility.batched_calls_limit: %w", err)
+		}
+		limits.maxCalls = uint64(maxCalls)
+	}
+	return limits, nil
+}
+
+// estimateBatchCost queries the weight of a batch of one and of two calls to derive the weight of each call and
+// of the batch itself. The length overhead - signature, era, nonce and tip - is measured from the signed
+// single call batch.
+func (c *Connection) estimateBatchCost(sender signature.KeyringPair, meta *types.Metadata, batchCall string, calls []types.Call, nonce uint64, opts TxOptions) (batchCost, error) {
+	opts.Nonce = &nonce
+	weight := func(calls []types.Call) (uint64, uint64, error) {
+		call, err := NewDynamicCall(meta, batchCall, CallArgs{"calls": calls})
+		if err != nil {
+			return 0, 0, err
+		}
+		signed, err := c.SignCall(sender, call, opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		fee, err := c.EstimateFee(signed.Extrinsic)
+		if err != nil {
+			return 0, 0, err
+		}
+		encoded, err := types.EncodeToBytes(signed.Extrinsic)
+		if err != nil {
+			return 0, 0, err
+		}
+		return fee.Weight.RefTime, uint64(len(encoded)), nil
+	}
+
+	single, length, err := weight(calls[:1])
+	if err != nil {
+		return batchCost{}, err
+	}
+	encoded, err := types.EncodeToBytes(calls[0])
+	if err != nil {
+		return batchCost{}, err
+	}
+	// The compact length prefixes of the extrinsic and the call vector grow with the batch - allow for the
+	// largest encodings.
+	cost := batchCost{callWeight: single, baseLength: length - uint64(len(encoded)) + 8}
+	if len(calls) == 1 {
+		return cost, nil
+	}
+
+	double, _, err := weight(calls[:2])
+	if err != nil {
+		return batchCost{}, err
+	}
+	if double > single && double-single <= single {
+		cost.callWeight = double - single
+		cost.baseWeight = single - cost.callWeight
+	}
+	return cost, nil
+}
+
+// chunkCalls greedily groups consecutive calls into batches that stay within the limits.
+func chunkCalls(callLengths []uint64, limits batchLimits, cost batchCost) ([]BatchChunk, error) {
+	chunks := []BatchChunk{}
+	start := 0
+	weight, length := cost.baseWeight, cost.baseLength
+	for i, callLength := range callLengths {
+		fits := weight+cost.callWeight <= limits.maxWeight &&
+			length+callLength <= limits.maxLength &&
+			(limits.maxCalls == 0 || uint64(i-start) < limits.maxCalls)
+		if !fits {
+			if i == start {
+				return nil, fmt.Errorf("payout %d exceeds the limits of a single extrinsic", i)
+			}
+			chunks = append(chunks, BatchChunk{Start: start, End: i})
+			start = i
+			weight, length = cost.baseWeight, cost.baseLength
+			if weight+cost.callWeight > limits.maxWeight || length+callLength > limits.maxLength {
+				return nil, fmt.Errorf("payout %d exceeds the limits of a single extrinsic", i)
+			}
+		}
+		weight += cost.callWeight
+		length += callLength
+	}
+	return append(chunks, BatchChunk{Start: start, End: len(callLengths)}), nil
+}
+
+// batchOutcomesFromEvents maps the events emitted by the batch extrinsic at the given index to payout outcomes.
+func batchOutcomesFromEvents(events []Event, index uint32, mode BatchMode, payouts []Payout) []error {
+	events = eventsForExtrinsic(events, index)
+	outcomes := make([]error, len(payouts))
+
+	for _, e := range findEvents(events, "System.ExtrinsicFailed") {
+		err := fmt.Errorf("batch failed: %w", eventError(e))
+		for i := range outcomes {
+			outcomes[i] = err
+		}
+		return outcomes
+	}
+
+	switch mode {
+	case Batch:
+		// BatchInterrupted { index, error }
+		for _, e := range findEvents(events, "Utility.BatchInterrupted") {
+			failedAt := e.BalanceField(0)
+			if failedAt == nil {
+				continue
+			}
+			failed := int(failedAt.Int64())
+			for i := failed; i < len(outcomes); i++ {
+				outcomes[i] = fmt.Errorf("not executed - batch interrupted at payout %d", failed)
+			}
+			if failed < len(outcomes) {
+				outcomes[failed] = eventError(e)
+			}
+		}
+	case ForceBatch:
+		// Every item emits ItemCompleted or ItemFailed, in the order of the calls.
+		item := 0
+		for _, e := range events {
+			switch e.Method() {
+			case "Utility.ItemCompleted":
+				item++
+			case "Utility.ItemFailed":
+				if item < len(outcomes) {
+					outcomes[item] = eventError(e)
+				}
+				item++
+			}
+		}
+		for i := item; i < len(outcomes); i++ {
+			outcomes[i] = fmt.Errorf("no outcome found for payout")
+		}
+	}
+	return outcomes
+}
+
+// eventError returns the dispatch error carried by an event.
+func eventError(e Event) error {
+	if dispatchError := e.DispatchError(); dispatchError != nil {
+		return *dispatchError
+	}
+	return fmt.Errorf("%s without a dispatch error", e.Method())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchLimitsFromMetadata(t *testing.T) {
+	limits, err := batchLimitsFromMetadata(testMetadata(t))
+	assert.NoError(t, err)
+	assert.NotZero(t, limits.maxWeight)
+	assert.NotZero(t, limits.maxLength)
+	assert.NotZero(t, limits.maxCalls)
+}
+
+func TestDecodeBlockWeights(t *testing.T) {
+	// BlockWeights with max_extrinsic set and reserved unset, followed by the other dispatch classes.
+	encode := func(weight func(uint64) interface{}) []byte {
+		var raw []byte
+		for _, v := range []interface{}{
+			weight(5000000), weight(2000000000000), weight(100000),
+			types.NewBool(true), weight(1479000000000),
+			types.NewBool(true), weight(1500000000000),
+			types.NewBool(false),
+			weight(100000), types.NewBool(false),
+		} {
+			b, err := types.EncodeToBytes(v)
+			assert.NoError(t, err)
+			raw = append(raw, b...)
+		}
+		return raw
+	}
+
+	v1 := encode(func(n uint64) interface{} { return types.NewU64(n) })
+	weights, err := decodeBlockWeights(v1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, Weight{RefTime: 2000000000000}, weights.MaxBlock)
+	assert.Equal(t, &Weight{RefTime: 1479000000000}, weights.Normal.MaxExtrinsic)
+	assert.Equal(t, &Weight{RefTime: 1500000000000}, weights.Normal.MaxTotal)
+	assert.Nil(t, weights.Normal.Reserved)
+
+	// Weight V2 encodes ref_time and proof_size as compacts - here a proof size of a tenth of the ref time.
+	v2 := encode(func(n uint64) interface{} {
+		return struct{ RefTime, ProofSize types.UCompact }{types.NewUCompactFromUInt(n), types.NewUCompactFromUInt(n / 10)}
+	})
+	weights, err = decodeBlockWeights(v2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, Weight{RefTime: 2000000000000, ProofSize: 200000000000}, weights.MaxBlock)
+	assert.Equal(t, &Weight{RefTime: 1479000000000, ProofSize: 147900000000}, weights.Normal.MaxExtrinsic)
+	assert.Equal(t, &Weight{RefTime: 1500000000000, ProofSize: 150000000000}, weights.Normal.MaxTotal)
+	assert.Nil(t, weights.Normal.Reserved)
+}
+
+func TestChunkCalls(t *testing.T) {
+	lengths := []uint64{40, 40, 40, 40, 40}
+	cost := batchCost{baseWeight: 10, callWeight: 100, baseLength: 100}
+
+	chunks, err := chunkCalls(lengths, batchLimits{maxWeight: 1000, maxLength: 1000}, cost)
+	assert.NoError(t, err)
+	assert.Equal(t, []BatchChunk{{Start: 0, End: 5}}, chunks)
+
+	// Weight allows two calls per batch.
+	chunks, err = chunkCalls(lengths, batchLimits{maxWeight: 210, maxLength: 1000}, cost)
+	assert.NoError(t, err)
+	assert.Equal(t, []BatchChunk{{Start: 0, End: 2}, {Start: 2, End: 4}, {Start: 4, End: 5}}, chunks)
+
+	// Length allows three calls per batch.
+	chunks, err = chunkCalls(lengths, batchLimits{maxWeight: 1000, maxLength: 220}, cost)
+	assert.NoError(t, err)
+	assert.Equal(t, []BatchChunk{{Start: 0, End: 3}, {Start: 3, End: 5}}, chunks)
+
+	chunks, err = chunkCalls(lengths, batchLimits{maxWeight: 1000, maxLength: 1000, maxCalls: 4}, cost)
+	assert.NoError(t, err)
+	assert.Equal(t, []BatchChunk{{Start: 0, End: 4}, {Start: 4, End: 5}}, chunks)
+
+	_, err = chunkCalls(lengths, batchLimits{maxWeight: 50, maxLength: 1000}, cost)
+	assert.Error(t, err)
+}
+
+func TestBatchOutcomesFromEvents(t *testing.T) {
+	bob, err := types.HexDecodeString(BobPubkey)
+	assert.NoError(t, err)
+	payouts := []Payout{{To: BobPubkey, Amount: 1}, {To: BobPubkey, Amount: 2}, {To: BobPubkey, Amount: 3}}
+	moduleError := DispatchError{Kind: "Module", ModuleIndex: 5, ErrorIndex: 2, Pallet: "Balances", Name: "InsufficientBalance"}
+
+	outcomes := batchOutcomesFromEvents(nil, 2, BatchAll, payouts)
+	assert.Equal(t, []error{nil, nil, nil}, outcomes)
+
+	events := []Event{testEvent(2, "System.ExtrinsicFailed", moduleError)}
+	for _, err := range batchOutcomesFromEvents(events, 2, BatchAll, payouts) {
+		assert.Error(t, err)
+	}
+
+	events = []Event{
+		testEvent(3, "Utility.BatchInterrupted", uint64(0), moduleError),
+		testEvent(2, "Utility.BatchInterrupted", uint64(1), moduleError),
+	}
+	outcomes = batchOutcomesFromEvents(events, 2, Batch, payouts)
+	assert.NoError(t, outcomes[0])
+	assert.EqualError(t, outcomes[1], "Balances.InsufficientBalance")
+	assert.Error(t, outcomes[2])
+
+	events = []Event{
+		testEvent(2, "Balances.Transfer", bob, bob, big.NewInt(1)),
+		testEvent(2, "Utility.ItemCompleted"),
+		testEvent(3, "Utility.ItemCompleted"),
+		testEvent(2, "Utility.ItemFailed", moduleError),
+		testEvent(2, "Balances.Transfer", bob, bob, big.NewInt(3)),
+		testEvent(2, "Utility.ItemCompleted"),
+	}
+	outcomes = batchOutcomesFromEvents(events, 2, ForceBatch, payouts)
+	assert.NoError(t, outcomes[0])
+	assert.EqualError(t, outcomes[1], "Balances.InsufficientBalance")
+	assert.NoError(t, outcomes[2])
+
+	outcomes = batchOutcomesFromEvents(events[:2], 2, ForceBatch, payouts)
+	assert.NoError(t, outcomes[0])
+	assert.Error(t, outcomes[1])
+}
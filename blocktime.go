@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// maxCachedBlockTimes bounds the block times cached by a Connection. Each BlockAtTime search reads about 30 blocks.
+const maxCachedBlockTimes = 10000
+
+// ErrNoBlockAtTime is returned by BlockAtTime when no finalized block has a timestamp at or after the time.
+var ErrNoBlockAtTime = errors.New("no finalized block at or after time")
+
+// BlockTime is a block and the time set by its Timestamp.set inherent.
+type BlockTime struct {
+	BlockRef
+	Time time.Time `json:"time"`
+}
+
+func (b BlockTime) String() string {
+	return fmt.Sprintf("%s at %s", b.BlockRef, b.Time.UTC().Format(time.RFC3339Nano))
+}
+
+// blockTimeCache holds the hashes and timestamps of finalized blocks by number. Finalized blocks don't change, so
+// entries never go stale.
+type blockTimeCache struct {
+	mu     sync.Mutex
+	blocks map[uint64]BlockTime
+}
+
+func (b *blockTimeCache) get(number uint64) (BlockTime, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	block, ok := b.blocks[number]
+	return block, ok
+}
+
+func (b *blockTimeCache) put(block BlockTime) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.blocks == nil || len(b.blocks) >= maxCachedBlockTimes {
+		b.blocks = map[uint64]BlockTime{}
+	}
+	b.blocks[block.Number] = block
+}
+
+// BlockAtTime returns the first finalized block with a timestamp at or after t, e.g. the block whose state is the
+// balance at the end of a month is the block before BlockAtTime of the first second of the next. It binary
+// searches the block numbers up to the finalized head, reading the Timestamp.set inherent in the body of each block
+// tried. Pruned nodes keep block bodies but not old state, so blocks of any age can be searched. The blocks read
+// are cached on the Connection, so searches for nearby times are cheap.
+func (c *Connection) BlockAtTime(t time.Time) (BlockTime, error) {
+	_, head, err := c.FinalizedHead()
+	if err != nil {
+		return BlockTime{}, err
+	}
+	// The call index is taken from the latest metadata, since the metadata of old blocks is read from their state.
+	// Runtime upgrades keep the index of the Timestamp pallet.
+	meta, err := c.getLatestMetadata()
+	if err != nil {
+		return BlockTime{}, fmt.Errorf("can't get meta for api: %w", err)
+	}
+	callIndex, err := meta.FindCallIndex("Timestamp.set")
+	if err != nil {
+		return BlockTime{}, err
+	}
+	return searchBlockTime(t, head, func(number uint64) (BlockTime, error) {
+		return c.blockTime(callIndex, number)
+	})
+}
+
+// blockTime returns the hash and timestamp of a finalized block, from the cache if it has been read before.
+func (c *Connection) blockTime(callIndex types.CallIndex, number uint64) (BlockTime, error) {
+	if block, ok := c.blockTimes.get(number); ok {
+		return block, nil
+	}
+	hash, err := c.Api.RPC.Chain.GetBlockHash(number)
+	if err != nil {
+		return BlockTime{}, fmt.Errorf("GetBlockHash failed for block %d: %w", number, err)
+	}
+	signedBlock, err := c.GetBlockByHash(hash)
+	if err != nil {
+		return BlockTime{}, fmt.Errorf("error getting block %d: %w", number, err)
+	}
+	timestamp, err := blockTimestamp(signedBlock, callIndex)
+	if err != nil {
+		return BlockTime{}, fmt.Errorf("error getting timestamp of block %d: %w", number, err)
+	}
+	block := BlockTime{BlockRef: BlockRef{Number: number, Hash: hash}, Time: *timestamp}
+	c.blockTimes.put(block)
+	return block, nil
+}
+
+// blockTimestamp returns the time set by the Timestamp.set inherent of a block, given the call's index. The
+// genesis block has no inherent and the zero Unix time.
+func blockTimestamp(block *types.SignedBlock, callIndex types.CallIndex) (*time.Time, error) {
+	var millis uint64
+	for _, extrinsic := range block.Block.Extrinsics {
+		if extrinsic.Method.CallIndex != callIndex {
+			continue
+		}
+		now, err := scale.NewDecoder(bytes.NewReader(extrinsic.Method.Args)).DecodeUintCompact()
+		if err != nil {
+			return nil, err
+		}
+		millis = now.Uint64()
+		break
+	}
+	timestamp := time.UnixMilli(int64(millis))
+	return &timestamp, nil
+}
+
+// searchBlockTime returns the first of blocks 0 to head with a time at or after t. Block times never decrease.
+func searchBlockTime(t time.Time, head uint64, blockTime func(number uint64) (BlockTime, error)) (BlockTime, error) {
+	last, err := blockTime(head)
+	if err != nil {
+		return BlockTime{}, err
+	}
+	if last.Time.Before(t) {
+		return BlockTime{}, fmt.Errorf("%w %s: finalized head %s", ErrNoBlockAtTime, t.UTC().Format(time.RFC3339), last)
+	}
+
+	found := last
+	low, high := uint64(0), head
+	for low < high {
+		mid := low + (high-low)/2
+		block, err := blockTime(mid)
+		if err != nil {
+			return BlockTime{}, err
+		}
+		if block.Time.Before(t) {
+			low = mid + 1
+		} else {
+			found, high = block, mid
+		}
+	}
+	return found, nil
+}
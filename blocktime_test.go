@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchBlockTime(t *testing.T) {
+	// Block 0 is genesis, with no timestamp; blocks 1 to 100 are six seconds apart, with block 50 missing its slot.
+	start := time.Date(2026, 9, 30, 23, 50, 0, 0, time.UTC)
+	times := map[uint64]time.Time{0: time.UnixMilli(0)}
+	for n := uint64(1); n <= 100; n++ {
+		slot := n
+		if n >= 50 {
+			slot++
+		}
+		times[n] = start.Add(time.Duration(slot) * 6 * time.Second)
+	}
+	reads := 0
+	blockTime := func(number uint64) (BlockTime, error) {
+		reads++
+		return BlockTime{BlockRef: BlockRef{Number: number, Hash: types.Hash{byte(number)}}, Time: times[number]}, nil
+	}
+	search := func(at time.Time) uint64 {
+		block, err := searchBlockTime(at, 100, blockTime)
+		assert.NoError(t, err)
+		assert.Equal(t, times[block.Number], block.Time)
+		return block.Number
+	}
+
+	assert.Equal(t, uint64(10), search(start.Add(60*time.Second)))
+	assert.Equal(t, uint64(11), search(start.Add(60*time.Second+time.Millisecond)))
+	// In the missed slot.
+	assert.Equal(t, uint64(50), search(start.Add(300*time.Second)))
+	assert.Equal(t, uint64(0), search(time.UnixMilli(0)))
+	assert.Equal(t, uint64(1), search(time.UnixMilli(1)))
+	assert.Equal(t, uint64(100), search(times[100]))
+	assert.Less(t, reads, 6*10)
+
+	_, err := searchBlockTime(times[100].Add(time.Millisecond), 100, blockTime)
+	assert.True(t, errors.Is(err, ErrNoBlockAtTime))
+
+	failing := errors.New("connection lost")
+	_, err = searchBlockTime(start, 100, func(number uint64) (BlockTime, error) { return BlockTime{}, failing })
+	assert.Equal(t, failing, err)
+}
+
+func TestBlockTimeCache(t *testing.T) {
+	var cache blockTimeCache
+	_, ok := cache.get(1)
+	assert.False(t, ok)
+	block := BlockTime{BlockRef: BlockRef{Number: 1}, Time: time.UnixMilli(6000)}
+	cache.put(block)
+	cached, ok := cache.get(1)
+	assert.True(t, ok)
+	assert.Equal(t, block, cached)
+}
+
+func TestBlockTimestamp(t *testing.T) {
+	meta := testMetadata(t)
+	callIndex, err := meta.FindCallIndex("Timestamp.set")
+	assert.NoError(t, err)
+	set, err := types.NewCall(meta, "Timestamp.set", types.NewUCompactFromUInt(1790812800000))
+	assert.NoError(t, err)
+	remark, err := types.NewCall(meta, "System.remark", []byte{1})
+	assert.NoError(t, err)
+
+	block := &types.SignedBlock{}
+	block.Block.Extrinsics = []types.Extrinsic{types.NewExtrinsic(remark), types.NewExtrinsic(set)}
+	timestamp, err := blockTimestamp(block, callIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, time.UnixMilli(1790812800000), *timestamp)
+
+	// Genesis has no inherent.
+	timestamp, err = blockTimestamp(&types.SignedBlock{}, callIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, time.UnixMilli(0), *timestamp)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+// transferCalls are the Balances calls that move funds, with the argument naming the destination.
+var transferCalls = map[string]bool{
+	"Balances.transfer":             true,
+	"Balances.transfer_keep_alive":  true,
+	"Balances.transfer_allow_death": true,
+	"Balances.transfer_all":         true,
+	"Balances.force_transfer":       true,
+}
+
+// CallTransfer is a balance transfer found in the call tree of an extrinsic.
+type CallTransfer struct {
+	// Path locates the transfer in the call tree, from the top-level call down to the transfer itself, e.g.
+	// "Proxy.proxy/call/Utility.batch_all/calls[1]/Balances.transfer_keep_alive".
+	Path string
+	// Call is the transfer call, e.g. "Balances.transfer_keep_alive".
+	Call string
+	// From is the account the funds are sent from - the signer, or the account that a proxy, multisig, sudo or
+	// derivative call dispatches as. Nil if the transfer is dispatched with a root origin and has no source.
+	From []byte
+	To   []byte
+	// Amount is nil for Balances.transfer_all, which sends the whole transferable balance.
+	Amount *big.Int
+}
+
+// ExtractTransfers decodes the call of an extrinsic with the metadata and returns every balance transfer in it,
+// including those nested in Utility batches, proxy, multisig and sudo calls.
+func ExtractTransfers(meta *types.Metadata, extrinsic *types.Extrinsic) ([]CallTransfer, error) {
+	call, err := DecodeCall(meta, extrinsic.Method)
+	if err != nil {
+		return nil, err
+	}
+	var origin []byte
+	if extrinsic.IsSigned() {
+		origin, _ = signerAccount(extrinsic.Signature.Signer)
+	}
+	return callTransfers(call, origin)
+}
+
+// callTransfers returns every balance transfer in a decoded call dispatched from the origin account.
+func callTransfers(call *DecodedCall, origin []byte) ([]CallTransfer, error) {
+	transfers := []CallTransfer{}
+	if err := walkCall(call, call.Method(), origin, &transfers); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// walkCall records the call if it is a transfer, and walks the calls nested in its arguments with the origin that
+// they are dispatched with.
+func walkCall(call *DecodedCall, path string, origin []byte, transfers *[]CallTransfer) error {
+	method := call.Method()
+	if transferCalls[method] {
+		transfer, err := newCallTransfer(call, path, origin)
+		if err != nil {
+			return err
+		}
+		*transfers = append(*transfers, *transfer)
+		return nil
+	}
+
+	nestedOrigin, err := dispatchOrigin(call, origin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	names := make([]string, 0, len(call.Args))
+	for name := range call.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := walkValue(call.Args[name], path+"/"+name, nestedOrigin, transfers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkValue finds the calls in a decoded argument - directly, in a list such as the calls of a batch, or wrapped
+// as in WrapperKeepOpaque<Call>.
+func walkValue(value interface{}, path string, origin []byte, transfers *[]CallTransfer) error {
+	switch v := value.(type) {
+	case *DecodedCall:
+		return walkCall(v, path+"/"+v.Method(), origin, transfers)
+	case []interface{}:
+		calls := isCallList(v)
+		for i, elem := range v {
+			elemPath := path
+			if calls {
+				elemPath = fmt.Sprintf("%s[%d]", path, i)
+			}
+			if err := walkValue(elem, elemPath, origin, transfers); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := walkValue(v[name], path, origin, transfers); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isCallList reports whether the value is a list of calls, such as the calls of a Utility batch.
+func isCallList(values []interface{}) bool {
+	if len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		if _, ok := v.(*DecodedCall); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func newCallTransfer(call *DecodedCall, path string, origin []byte) (*CallTransfer, error) {
+	to, err := accountFromValue(call.Args["dest"])
+	if err != nil {
+		return nil, fmt.Errorf("%s dest: %w", path, err)
+	}
+	transfer := &CallTransfer{Path: path, Call: call.Method(), From: origin, To: to}
+	if call.Name == "force_transfer" {
+		if transfer.From, err = accountFromValue(call.Args["source"]); err != nil {
+			return nil, fmt.Errorf("%s source: %w", path, err)
+		}
+	}
+	if call.Name != "transfer_all" {
+		amount, ok := call.Args["value"].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected value %v", path, call.Args["value"])
+		}
+		transfer.Amount = amount
+	}
+	return transfer, nil
+}
+
+// dispatchOrigin returns the account that the calls nested in call are dispatched as.
+func dispatchOrigin(call *DecodedCall, origin []byte) ([]byte, error) {
+	switch call.Method() {
+	case "Proxy.proxy", "Proxy.proxy_announced":
+		return accountFromValue(call.Args["real"])
+	case "Sudo.sudo_as":
+		return accountFromValue(call.Args["who"])
+	case "Sudo.sudo", "Sudo.sudo_unchecked_weight":
+		// Dispatched with a root origin.
+		return nil, nil
+	case "Multisig.as_multi", "Multisig.as_multi_threshold_1":
+		threshold := uint64(1)
+		if call.Name == "as_multi" {
+			t, ok := call.Args["threshold"].(uint64)
+			if !ok {
+				return nil, fmt.Errorf("unexpected threshold %v", call.Args["threshold"])
+			}
+			threshold = t
+		}
+		others, ok := call.Args["other_signatories"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected other_signatories %v", call.Args["other_signatories"])
+		}
+		signatories := [][]byte{}
+		if origin != nil {
+			signatories = append(signatories, origin)
+		}
+		for _, other := range others {
+			account, err := accountFromValue(other)
+			if err != nil {
+				return nil, err
+			}
+			signatories = append(signatories, account)
+		}
+		return multisigAccountID(signatories, uint16(threshold)), nil
+	case "Utility.as_derivative":
+		index, ok := call.Args["index"].(uint64)
+		if !ok || origin == nil {
+			return nil, fmt.Errorf("unexpected derivative index %v", call.Args["index"])
+		}
+		return derivativeAccountID(origin, uint16(index)), nil
+	}
+	return origin, nil
+}
+
+// accountFromValue returns the account ID of a decoded AccountId32 or MultiAddress::Id.
+func accountFromValue(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		if len(v) == 32 {
+			return v, nil
+		}
+	case map[string]interface{}:
+		if id, ok := v["Id"]; ok {
+			return accountFromValue(id)
+		}
+		for variant := range v {
+			return nil, fmt.Errorf("unsupported address type %s", variant)
+		}
+	}
+	return nil, fmt.Errorf("unsupported account %v", value)
+}
+
+// multisigAccountID derives the account of a multisig from its signatories and threshold, as
+// pallet_multisig::Pallet::multi_account_id does.
+func multisigAccountID(signatories [][]byte, threshold uint16) []byte {
+	sort.Slice(signatories, func(i, j int) bool {
+		return bytes.Compare(signatories[i], signatories[j]) < 0
+	})
+	data := []byte("modlpy/utilisuba")
+	length, _ := types.EncodeToBytes(types.NewUCompactFromUInt(uint64(len(signatories))))
+	data = append(data, length...)
+	for _, s := range signatories {
+		data = append(data, s...)
+	}
+	data = append(data, byte(threshold), byte(threshold>>8))
+	hash := blake2b.Sum256(data)
+	return hash[:]
+}
+
+// derivativeAccountID derives the account that Utility.as_derivative dispatches as, as
+// pallet_utility::Pallet::derivative_account_id does.
+func derivativeAccountID(who []byte, index uint16) []byte {
+	data := append([]byte("modlpy/utilisuba"), who...)
+	data = append(data, byte(index), byte(index>>8))
+	hash := blake2b.Sum256(data)
+	return hash[:]
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCall(t *testing.T) {
+	meta := testMetadata(t)
+	to := bytes.Repeat([]byte{0x02}, 32)
+
+	call, err := NewDynamicCall(meta, "Balances.transfer_keep_alive", CallArgs{"dest": to, "value": 12345})
+	assert.NoError(t, err)
+	decoded, err := DecodeCall(meta, call)
+	assert.NoError(t, err)
+	assert.Equal(t, "Balances.transfer_keep_alive", decoded.Method())
+	assert.Equal(t, map[string]interface{}{"Id": to}, decoded.Args["dest"])
+	assert.Equal(t, big.NewInt(12345), decoded.Args["value"])
+
+	remark, err := NewDynamicCall(meta, "System.remark", CallArgs{"remark": []byte("hello")})
+	assert.NoError(t, err)
+	batch, err := NewDynamicCall(meta, "Utility.batch", CallArgs{"calls": []types.Call{remark, call}})
+	assert.NoError(t, err)
+	decoded, err = DecodeCall(meta, batch)
+	assert.NoError(t, err)
+	calls, ok := decoded.Args["calls"].([]interface{})
+	assert.True(t, ok)
+	if assert.Len(t, calls, 2) {
+		assert.Equal(t, &DecodedCall{Pallet: "System", Name: "remark", Args: map[string]interface{}{"remark": []byte("hello")}}, calls[0])
+		assert.Equal(t, "Balances.transfer_keep_alive", calls[1].(*DecodedCall).Method())
+	}
+
+	// Trailing bytes mean that the call wasn't decoded as it was encoded.
+	call.Args = append(call.Args, 0)
+	_, err = DecodeCall(meta, call)
+	assert.Error(t, err)
+}
+
+func TestExtractTransfers(t *testing.T) {
+	meta := testMetadata(t)
+	signer := bytes.Repeat([]byte{0x01}, 32)
+	alice := bytes.Repeat([]byte{0x0a}, 32)
+	bob := bytes.Repeat([]byte{0x0b}, 32)
+	real := bytes.Repeat([]byte{0x0c}, 32)
+	cosigner := bytes.Repeat([]byte{0x0d}, 32)
+
+	signed := func(call types.Call) *types.Extrinsic {
+		extrinsic := types.NewExtrinsic(call)
+		extrinsic.Signature = types.ExtrinsicSignatureV4{
+			Signer:    types.NewMultiAddressFromAccountID(signer),
+			Signature: types.MultiSignature{IsSr25519: true},
+		}
+		extrinsic.Version |= types.ExtrinsicBitSigned
+		return &extrinsic
+	}
+	newCall := func(call string, args CallArgs) types.Call {
+		c, err := NewDynamicCall(meta, call, args)
+		assert.NoError(t, err)
+		return c
+	}
+
+	toAlice := newCall("Balances.transfer_keep_alive", CallArgs{"dest": alice, "value": 100})
+	toBob := newCall("Balances.transfer", CallArgs{"dest": bob, "value": 200})
+	remark := newCall("System.remark", CallArgs{"remark": []byte{}})
+	batch := newCall("Utility.batch_all", CallArgs{"calls": []types.Call{toAlice, remark, toBob}})
+
+	t.Run("top-level", func(t *testing.T) {
+		transfers, err := ExtractTransfers(meta, signed(toAlice))
+		assert.NoError(t, err)
+		assert.Equal(t, []CallTransfer{
+			{Path: "Balances.transfer_keep_alive", Call: "Balances.transfer_keep_alive", From: signer, To: alice, Amount: big.NewInt(100)},
+		}, transfers)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		transfers, err := ExtractTransfers(meta, signed(batch))
+		assert.NoError(t, err)
+		assert.Equal(t, []CallTransfer{
+			{Path: "Utility.batch_all/calls[0]/Balances.transfer_keep_alive", Call: "Balances.transfer_keep_alive", From: signer, To: alice, Amount: big.NewInt(100)},
+			{Path: "Utility.batch_all/calls[2]/Balances.transfer", Call: "Balances.transfer", From: signer, To: bob, Amount: big.NewInt(200)},
+		}, transfers)
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		proxy := newCall("Proxy.proxy", CallArgs{"real": real, "force_proxy_type": nil, "call": batch})
+		transfers, err := ExtractTransfers(meta, signed(proxy))
+		assert.NoError(t, err)
+		if assert.Len(t, transfers, 2) {
+			assert.Equal(t, "Proxy.proxy/call/Utility.batch_all/calls[2]/Balances.transfer", transfers[1].Path)
+			assert.Equal(t, real, transfers[0].From)
+			assert.Equal(t, bob, transfers[1].To)
+		}
+	})
+
+	t.Run("multisig", func(t *testing.T) {
+		multisig := newCall("Multisig.as_multi_threshold_1", CallArgs{"other_signatories": [][]byte{cosigner}, "call": toBob})
+		transfers, err := ExtractTransfers(meta, signed(multisig))
+		assert.NoError(t, err)
+		if assert.Len(t, transfers, 1) {
+			assert.Equal(t, "Multisig.as_multi_threshold_1/call/Balances.transfer", transfers[0].Path)
+			assert.Equal(t, multisigAccountID([][]byte{cosigner, signer}, 1), transfers[0].From)
+			assert.Equal(t, big.NewInt(200), transfers[0].Amount)
+		}
+	})
+
+	t.Run("derivative", func(t *testing.T) {
+		derivative := newCall("Utility.as_derivative", CallArgs{"index": 3, "call": toAlice})
+		transfers, err := ExtractTransfers(meta, signed(derivative))
+		assert.NoError(t, err)
+		if assert.Len(t, transfers, 1) {
+			assert.Equal(t, derivativeAccountID(signer, 3), transfers[0].From)
+		}
+	})
+
+	t.Run("no transfers", func(t *testing.T) {
+		transfers, err := ExtractTransfers(meta, signed(remark))
+		assert.NoError(t, err)
+		assert.Empty(t, transfers)
+	})
+}
+
+func TestMultisigAccountID(t *testing.T) {
+	// The order of the signatories doesn't matter.
+	a := bytes.Repeat([]byte{0x01}, 32)
+	b := bytes.Repeat([]byte{0x02}, 32)
+	assert.Equal(t, multisigAccountID([][]byte{a, b}, 2), multisigAccountID([][]byte{b, a}, 2))
+	assert.NotEqual(t, multisigAccountID([][]byte{a, b}, 2), multisigAccountID([][]byte{a, b}, 1))
+	assert.Len(t, multisigAccountID([][]byte{a, b}, 2), 32)
+}
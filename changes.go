@@ -9,38 +9,12 @@ import (
 
 // GetStorageHistoryForID runs a storage query for the provided identity (public key represented as a hexadecimal
 // string). Returns a slice of StorageChangeSet objects which comprise block hash and a slice of key-value
-// representation of changes for this account in the given block.
+// representation of changes for this account in the given block. The query is made in windows of
+// DefaultHistoryWindow blocks - see GetStorageHistory for control over the windows and progress.
 // NOTE: Must be run against an ARCHIVAL node - a full node is insufficient since it does not retain a full
-// block history.
+// block history. A full node fails with a *StatePrunedError.
 func (c *Connection) GetStorageHistoryForID(ID string, checkpoint uint64) (changeData []types.StorageChangeSet, err error) {
-	account, err := types.HexDecodeString(ID)
-	if err != nil {
-		return
-	}
-
-	meta, err := c.Api.RPC.State.GetMetadataLatest()
-	if err != nil {
-		err = fmt.Errorf("can't get meta for api: %w", err)
-		return
-	}
-
-	storageKey, err := types.CreateStorageKey(meta, "System", "Account", account, nil)
-	if err != nil {
-		err = fmt.Errorf("can't create storage key to query balance for account %s: %w", ID, err)
-		return
-	}
-
-	startBlockHash, err := c.Api.RPC.Chain.GetBlockHash(checkpoint)
-	if err != nil {
-		err = fmt.Errorf("GetBlockHash failed for account %s: %w", ID, err)
-		return
-	}
-
-	changeData, err = c.Api.RPC.State.QueryStorageLatest([]types.StorageKey{storageKey}, startBlockHash)
-	if err != nil {
-		err = fmt.Errorf("QueryStorageLatest failed for account %s: %w", ID, err)
-	}
-	return
+	return c.GetStorageHistory(ID, checkpoint, StorageHistoryOptions{})
 }
 
 // ChangedBlockHashes returns a slice of block hashes for blocks in which the System.Account balance of the
@@ -53,7 +27,8 @@ func (c *Connection) ChangedBlockHashes(ID string, checkpoint uint64) (blockHash
 	fmt.Println("len: ", len(changes))
 
 	for _, change := range changes {
-		blockHashes = append(blockHashes, change.Block[:])
+		hash := change.Block
+		blockHashes = append(blockHashes, hash[:])
 	}
 	return
 }
@@ -77,28 +52,59 @@ func (c *Connection) DecodeAccountInfo(rawBytes []byte) (accountInfo AccountInfo
 	return
 }
 
+// ChangeData is the balance of an account in a block where its System.Account storage changed.
 type ChangeData struct {
-	blockHash         []byte
-	publicKey         []byte
-	ID                string
-	amountAtThisBlock big.Int //types.U128
+	BlockHash []byte
+	PublicKey []byte
+	ID        string
+	// AmountAtThisBlock is the free balance after the block, zero if the account was reaped.
+	AmountAtThisBlock big.Int //types.U128
+	// Reserved is the reserved balance after the block.
+	Reserved big.Int
 }
 
 // GetChangeData --
 func (c *Connection) GetChangeData(ID string, checkpoint uint64) (changeDataCollection []ChangeData, err error) {
+	publicKey, err := types.HexDecodeString(ID)
+	if err != nil {
+		return
+	}
+
 	changes, err := c.GetStorageHistoryForID(ID, checkpoint)
 	if err != nil {
 		return
 	}
 
+	return c.changeData(ID, publicKey, changes), nil
+}
+
+// changeData reads the balances from the change sets of an account's System.Account storage.
+func (c *Connection) changeData(ID string, publicKey []byte, changes []types.StorageChangeSet) []ChangeData {
+	changeDataCollection := []ChangeData{}
 	for _, change := range changes {
-		amount := c.DecodeAccountInfo(change.Changes[0].StorageData).Data.Free
+		// A reaped account has no storage, and so a zero balance.
+		var accountInfo AccountInfo
+		if len(change.Changes) > 0 && change.Changes[0].HasStorageData {
+			accountInfo = c.DecodeAccountInfo(change.Changes[0].StorageData)
+		}
+		// change is reused by each iteration, so its hash is copied.
+		hash := change.Block
 		res := ChangeData{
-			blockHash:         change.Block[:],
+			BlockHash:         hash[:],
+			PublicKey:         publicKey,
 			ID:                ID,
-			amountAtThisBlock: *amount.Int,
+			AmountAtThisBlock: *u128Int(accountInfo.Data.Free),
+			Reserved:          *u128Int(accountInfo.Data.Reserved),
 		}
 		changeDataCollection = append(changeDataCollection, res)
 	}
-	return
+	return changeDataCollection
+}
+
+// u128Int returns the value of a U128, treating the zero value as zero.
+func u128Int(n types.U128) *big.Int {
+	if n.Int == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(n.Int)
 }
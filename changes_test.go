@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"testing"
 
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,3 +32,42 @@ func TestChangedBlockHashes(t *testing.T) {
 
 	}
 }
+
+// testAccountChange is a change set for block with the System.Account storage of an account with the given free
+// and reserved balances. A negative free balance leaves the account reaped, without storage.
+func testAccountChange(t *testing.T, block byte, free, reserved int64) types.StorageChangeSet {
+	change := types.KeyValueOption{StorageKey: types.StorageKey{1}}
+	if free >= 0 {
+		var info AccountInfo
+		info.Data.Free = types.NewU128(*big.NewInt(free))
+		info.Data.Reserved = types.NewU128(*big.NewInt(reserved))
+		info.Data.MiscFrozen = types.NewU128(*big.NewInt(0))
+		info.Data.FreeFrozen = types.NewU128(*big.NewInt(0))
+		data, err := types.EncodeToBytes(info)
+		assert.NoError(t, err)
+		change.HasStorageData, change.StorageData = true, data
+	}
+	return types.StorageChangeSet{Block: types.Hash{block}, Changes: []types.KeyValueOption{change}}
+}
+
+func TestChangeData(t *testing.T) {
+	c := &Connection{}
+	publicKey := []byte{0xaa}
+	changes := []types.StorageChangeSet{
+		testAccountChange(t, 1, 1000, 10),
+		testAccountChange(t, 2, 400, 0),
+		testAccountChange(t, 3, -1, 0),
+	}
+
+	data := c.changeData("aa", publicKey, changes)
+	if assert.Len(t, data, 3) {
+		// Each change keeps the hash of its own block.
+		assert.Equal(t, []byte{1}, data[0].BlockHash[:1])
+		assert.Equal(t, []byte{2}, data[1].BlockHash[:1])
+		assert.Equal(t, []byte{3}, data[2].BlockHash[:1])
+		assert.Equal(t, "1000", data[0].AmountAtThisBlock.String())
+		assert.Equal(t, "10", data[0].Reserved.String())
+		assert.Equal(t, "400", data[1].AmountAtThisBlock.String())
+		assert.Equal(t, "0", data[2].AmountAtThisBlock.String())
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a polka-connect subcommand, run as `polka-connect <name> [-endpoint url] args...`.
+type command struct {
+	usage string
+	run   func(c *Connection, args []string) (interface{}, error)
+}
+
+var commands = map[string]command{
+	"describe-block": {
+		usage: "<block hash | block number>",
+		run: func(c *Connection, args []string) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("expected one block hash or number")
+			}
+			return c.DescribeBlock(args[0])
+		},
+	},
+	"decode-extrinsic": {
+		usage: "<extrinsic hex> [block hash | block number]",
+		run: func(c *Connection, args []string) (interface{}, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, fmt.Errorf("expected an extrinsic and optionally a block")
+			}
+			block := ""
+			if len(args) == 2 {
+				block = args[1]
+			}
+			return c.DecodeExtrinsic(args[0], block)
+		},
+	},
+}
+
+// runCommand runs a subcommand and prints its result as indented JSON.
+func runCommand(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %s\n%s", name, commandUsage())
+	}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	endpoint := flags.String("endpoint", "", "node RPC URL (default the gsrpc config RPC URL)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: polka-connect %s [-endpoint url] %s\n", name, cmd.usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	c, err := NewConnection(*endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	result, err := cmd.run(c, flags.Args())
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func commandUsage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{"commands:"}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  %s %s", name, commands[name].usage))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -32,6 +32,8 @@ type AccountInfo struct {
 
 type Connection struct {
 	Api *gsrpc.SubstrateAPI
+	// blockTimes caches the blocks read by BlockAtTime.
+	blockTimes blockTimeCache
 }
 
 // NewDefaultConnection provides a GSRPC API connection to a Substrate node using the default address.
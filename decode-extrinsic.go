@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/vedhavyas/go-subkey"
+	"golang.org/x/crypto/blake2b"
+)
+
+// DecodedExtrinsic is an extrinsic decoded from its SCALE encoding with the runtime metadata, showing exactly what
+// was signed. Accounts are SS58 addresses and other byte values 0x-prefixed hex, as in DescribeBlock.
+type DecodedExtrinsic struct {
+	// Hash is the hex blake2-256 hash of the extrinsic, as reported in blocks and by author_submitExtrinsic.
+	Hash    string `json:"hash"`
+	Length  int    `json:"length"`
+	Version uint8  `json:"version"`
+	Signed  bool   `json:"signed"`
+	// The fields below, up to Method, are only set for signed extrinsics.
+	Signer string `json:"signer,omitempty"`
+	// SignatureScheme is the MultiSignature variant, e.g. "Sr25519", "Ed25519" or "Ecdsa".
+	SignatureScheme string          `json:"signature_scheme,omitempty"`
+	Signature       string          `json:"signature,omitempty"`
+	Era             *EraDescription `json:"era,omitempty"`
+	Nonce           *big.Int        `json:"nonce,omitempty"`
+	Tip             *big.Int        `json:"tip,omitempty"`
+	// SignedExtensions are the runtime's signed extensions in signing order, with the values encoded in the
+	// extrinsic. Extensions that add nothing to the extrinsic itself, such as CheckGenesis, have no value.
+	SignedExtensions []SignedExtensionValue `json:"signed_extensions,omitempty"`
+	Method           string                 `json:"method"`
+	Call             *DecodedCall           `json:"call"`
+
+	// call is the call with its values as decoded, before they are described for display, and signer the
+	// account ID of the signer, if it signed with one - what callTransfers needs to find the transfers.
+	call   *DecodedCall
+	signer []byte
+}
+
+// SignedExtensionValue is the value of a signed extension encoded in an extrinsic.
+type SignedExtensionValue struct {
+	Identifier string      `json:"identifier"`
+	Value      interface{} `json:"value,omitempty"`
+}
+
+func (d DecodedExtrinsic) String() string {
+	return fmt.Sprintf("Hash: %s\nVersion: %d\nSigned: %t\nSigner: %s\nSignatureScheme: %s\nNonce: %s\nTip: %s\nMethod: %s\n",
+		d.Hash,
+		d.Version,
+		d.Signed,
+		d.Signer,
+		d.SignatureScheme,
+		d.Nonce,
+		d.Tip,
+		d.Method,
+	)
+}
+
+// DecodeExtrinsic decodes a signed or unsigned extrinsic given as hex, with or without its length prefix - e.g. from
+// a log, author_pendingExtrinsics or a Transaction built by GenTransaction. It is decoded with the metadata of
+// block, a hash or number as for DescribeBlock, or of the best block if block is empty. The era's birth and death
+// are worked out relative to the same block, which should be within the extrinsic's validity window, e.g. the
+// block it was included in.
+func (c *Connection) DecodeExtrinsic(extrinsicHex string, block string) (*DecodedExtrinsic, error) {
+	data, err := types.HexDecodeString(extrinsicHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extrinsic hex: %w", err)
+	}
+
+	var blockHash types.Hash
+	if block == "" {
+		if blockHash, err = c.Api.RPC.Chain.GetBlockHashLatest(); err != nil {
+			return nil, fmt.Errorf("error getting latest block hash: %w", err)
+		}
+	} else if blockHash, err = c.blockHash(block); err != nil {
+		return nil, err
+	}
+	header, err := c.Api.RPC.Chain.GetHeader(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting header for block %#x: %w", blockHash, err)
+	}
+	meta, err := c.getMetadata(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := c.SS58Prefix()
+	if err != nil {
+		return nil, err
+	}
+	return decodeExtrinsic(meta, data, uint64(header.Number), prefix)
+}
+
+// decodeExtrinsic decodes a version 4 extrinsic with V14 metadata. The address, signature and signed extensions
+// are decoded with the types the runtime declares for them, so extrinsics of chains with other signed extensions
+// than gsrpc's ExtrinsicSignatureV4 decode too.
+func decodeExtrinsic(meta *types.Metadata, data []byte, current uint64, prefix uint8) (*DecodedExtrinsic, error) {
+	r, err := newTypeRegistry(meta)
+	if err != nil {
+		return nil, err
+	}
+	data, encoded, err := withLengthPrefix(data)
+	if err != nil {
+		return nil, err
+	}
+	hash := blake2b.Sum256(encoded)
+
+	decoder := newInputDecoder(data)
+	version, err := decoder.ReadOneByte()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding extrinsic version: %w", err)
+	}
+	d := &DecodedExtrinsic{
+		Hash:    hex.EncodeToString(hash[:]),
+		Length:  len(data),
+		Version: version &^ types.ExtrinsicBitSigned,
+		Signed:  version&types.ExtrinsicBitSigned != 0,
+	}
+	if d.Version != 4 {
+		return nil, fmt.Errorf("unsupported extrinsic version %d", d.Version)
+	}
+
+	if d.Signed {
+		if err := r.decodeExtrinsicSignature(decoder, d, current, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	call, err := r.decodeCall(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding call: %w", err)
+	}
+	if _, err := decoder.ReadOneByte(); err == nil {
+		return nil, fmt.Errorf("extrinsic has unexpected trailing bytes after call %s", call.Method())
+	}
+	d.Method = call.Method()
+	d.Call = describeValue(call, prefix).(*DecodedCall)
+	d.call = call
+	return d, nil
+}
+
+// decodeExtrinsicSignature decodes the signer, signature and signed extensions of a signed extrinsic.
+func (r *typeRegistry) decodeExtrinsicSignature(decoder *inputDecoder, d *DecodedExtrinsic, current uint64, prefix uint8) error {
+	addressType, ok := r.extrinsicParam("Address")
+	if !ok {
+		return fmt.Errorf("metadata has no extrinsic Address type")
+	}
+	signatureType, ok := r.extrinsicParam("Signature")
+	if !ok {
+		return fmt.Errorf("metadata has no extrinsic Signature type")
+	}
+
+	address, err := r.decodeValue(decoder, addressType)
+	if err != nil {
+		return fmt.Errorf("error decoding signer: %w", err)
+	}
+	// MultiAddress::Id, or a plain AccountId on chains without MultiAddress.
+	if id, ok := address.(map[string]interface{}); ok && id["Id"] != nil {
+		address = id["Id"]
+	}
+	if account, ok := address.([]byte); ok && len(account) == 32 {
+		if d.Signer, err = subkey.SS58Address(account, prefix); err != nil {
+			return err
+		}
+		d.signer = account
+	} else {
+		d.Signer = fmt.Sprint(describeValue(address, prefix))
+	}
+
+	signature, err := r.decodeValue(decoder, signatureType)
+	if err != nil {
+		return fmt.Errorf("error decoding signature: %w", err)
+	}
+	switch s := signature.(type) {
+	case map[string]interface{}:
+		for scheme, value := range s {
+			d.SignatureScheme = scheme
+			d.Signature = fmt.Sprint(describeValue(value, prefix))
+		}
+	default:
+		d.Signature = fmt.Sprint(describeValue(s, prefix))
+	}
+
+	d.SignedExtensions = []SignedExtensionValue{}
+	for _, extension := range r.meta.Extrinsic.SignedExtensions {
+		identifier := string(extension.Identifier)
+		var value interface{}
+		switch identifier {
+		case "CheckMortality", "CheckEra":
+			var era types.ExtrinsicEra
+			if err := decoder.Decode(&era); err != nil {
+				return fmt.Errorf("error decoding signed extension %s: %w", identifier, err)
+			}
+			if d.Era, err = describeEra(era, current); err != nil {
+				return err
+			}
+			value = d.Era
+		default:
+			if value, err = r.decodeValue(decoder, extension.Type); err != nil {
+				return fmt.Errorf("error decoding signed extension %s: %w", identifier, err)
+			}
+			if fields, ok := value.(map[string]interface{}); ok && len(fields) == 0 {
+				value = nil
+			}
+		}
+
+		switch {
+		case identifier == "CheckNonce":
+			d.Nonce, _ = value.(*big.Int)
+		case strings.HasPrefix(identifier, "Charge"):
+			// ChargeTransactionPayment is the tip; ChargeAssetTxPayment { tip, asset_id }.
+			if fields, ok := value.(map[string]interface{}); ok {
+				d.Tip, _ = fields["tip"].(*big.Int)
+			} else {
+				d.Tip, _ = value.(*big.Int)
+			}
+		}
+		d.SignedExtensions = append(d.SignedExtensions, SignedExtensionValue{Identifier: identifier, Value: describeValue(value, prefix)})
+	}
+	return nil
+}
+
+// withLengthPrefix returns an extrinsic without and with its compact length prefix, given either. The prefix is
+// taken to be present if it matches the length of the rest of the data.
+func withLengthPrefix(data []byte) (extrinsic, encoded []byte, err error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("empty extrinsic")
+	}
+	reader := bytes.NewReader(data)
+	length, err := scale.NewDecoder(reader).DecodeUintCompact()
+	if err == nil && length.IsUint64() && length.Uint64() == uint64(reader.Len()) {
+		return data[len(data)-reader.Len():], data, nil
+	}
+	encoded, err = types.EncodeToBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, encoded, nil
+}
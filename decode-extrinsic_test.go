@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeExtrinsic(t *testing.T) {
+	meta := testMetadata(t)
+	bob, err := types.HexDecodeString(BobPubkey)
+	assert.NoError(t, err)
+	alice, err := types.HexDecodeString(AlicePubkey)
+	assert.NoError(t, err)
+
+	transfer, err := types.NewCall(meta, "Balances.transfer_keep_alive", types.NewMultiAddressFromAccountID(alice), types.NewUCompactFromUInt(4200000000))
+	assert.NoError(t, err)
+	batch, err := NewDynamicCall(meta, "Utility.batch_all", CallArgs{"calls": []types.Call{transfer}})
+	assert.NoError(t, err)
+
+	extrinsic := types.NewExtrinsic(batch)
+	extrinsic.Version |= types.ExtrinsicBitSigned
+	extrinsic.Signature = types.ExtrinsicSignatureV4{
+		Signer:    types.NewMultiAddressFromAccountID(bob),
+		Signature: types.MultiSignature{IsSr25519: true, AsSr25519: types.NewSignature(bytes.Repeat([]byte{7}, 64))},
+		Era:       NewMortalEra(100, 64),
+		Nonce:     types.NewUCompactFromUInt(12),
+		Tip:       types.NewUCompactFromUInt(5),
+	}
+	encoded, err := types.EncodeToBytes(extrinsic)
+	assert.NoError(t, err)
+	hash, err := types.GetHash(extrinsic)
+	assert.NoError(t, err)
+
+	d, err := decodeExtrinsic(meta, encoded, 120, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(hash[:]), d.Hash)
+	assert.Equal(t, uint8(4), d.Version)
+	assert.True(t, d.Signed)
+	assert.Equal(t, receiverAddress, d.Signer)
+	assert.Equal(t, "Sr25519", d.SignatureScheme)
+	assert.Equal(t, "0x"+hex.EncodeToString(bytes.Repeat([]byte{7}, 64)), d.Signature)
+	assert.Equal(t, &EraDescription{Period: 64, Phase: 36, Birth: 100, Death: 164}, d.Era)
+	assert.Equal(t, big.NewInt(12), d.Nonce)
+	assert.Equal(t, big.NewInt(5), d.Tip)
+	identifiers := []string{}
+	for _, extension := range d.SignedExtensions {
+		identifiers = append(identifiers, extension.Identifier)
+	}
+	assert.Equal(t, []string{"CheckSpecVersion", "CheckTxVersion", "CheckGenesis", "CheckMortality", "CheckNonce", "CheckWeight", "ChargeTransactionPayment", "PrevalidateAttests"}, identifiers)
+	assert.Nil(t, d.SignedExtensions[0].Value)
+
+	assert.Equal(t, "Utility.batch_all", d.Method)
+	calls := d.Call.Args["calls"].([]interface{})
+	if assert.Len(t, calls, 1) {
+		call := calls[0].(*DecodedCall)
+		assert.Equal(t, "Balances.transfer_keep_alive", call.Method())
+		assert.Equal(t, map[string]interface{}{"Id": "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"}, call.Args["dest"])
+		assert.Equal(t, big.NewInt(4200000000), call.Args["value"])
+	}
+
+	// Without the length prefix.
+	unprefixed, err := decodeExtrinsic(meta, encoded[2:], 120, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, d, unprefixed)
+
+	unsigned, err := types.EncodeToBytes(types.NewExtrinsic(transfer))
+	assert.NoError(t, err)
+	d, err = decodeExtrinsic(meta, unsigned, 120, 0)
+	assert.NoError(t, err)
+	assert.False(t, d.Signed)
+	assert.Empty(t, d.Signer)
+	assert.Nil(t, d.Era)
+	assert.Equal(t, "Balances.transfer_keep_alive", d.Method)
+
+	_, err = decodeExtrinsic(meta, append(encoded, 0), 120, 0)
+	assert.Error(t, err)
+	_, err = decodeExtrinsic(meta, []byte{3}, 120, 0)
+	assert.Error(t, err)
+
+	// A System.remark whose length, 2^64-1, is more than the input holds.
+	remark, err := types.NewCall(meta, "System.remark", []byte{})
+	assert.NoError(t, err)
+	index := remark.CallIndex
+	hostile := []byte{4, index.SectionIndex, index.MethodIndex, 0x13, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	assert.NotPanics(t, func() {
+		_, err = decodeExtrinsic(meta, hostile, 120, 0)
+	})
+	assert.Error(t, err)
+	// A length that fits in an int but not in the input.
+	hostile = []byte{4, index.SectionIndex, index.MethodIndex, 0x0b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	assert.NotPanics(t, func() {
+		_, err = decodeExtrinsic(meta, hostile, 120, 0)
+	})
+	assert.Error(t, err)
+}
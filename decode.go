@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// DecodedCall is a call decoded using the runtime metadata. Calls nested in its arguments, such as the calls of
+// a Utility.batch, are decoded as *DecodedCall too.
+type DecodedCall struct {
+	Pallet string `json:"pallet"`
+	Name   string `json:"name"`
+	// Args maps argument names to their decoded values - see decodeValue.
+	Args map[string]interface{} `json:"args"`
+}
+
+// Method returns the call in the form "Pallet.call_name".
+func (c *DecodedCall) Method() string {
+	return c.Pallet + "." + c.Name
+}
+
+// DecodeCall decodes the arguments of a call using V14 metadata.
+func DecodeCall(meta *types.Metadata, call types.Call) (*DecodedCall, error) {
+	r, err := newTypeRegistry(meta)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := types.EncodeToBytes(call)
+	if err != nil {
+		return nil, err
+	}
+	decoder := newInputDecoder(encoded)
+	decoded, err := r.decodeCall(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.ReadOneByte(); err == nil {
+		return nil, fmt.Errorf("call %s has unexpected trailing bytes", decoded.Method())
+	}
+	return decoded, nil
+}
+
+// inputDecoder is a scale.Decoder over a byte slice that knows how many bytes remain, so that lengths read from
+// the input can be checked before anything is allocated for them.
+type inputDecoder struct {
+	*scale.Decoder
+	input *bytes.Reader
+}
+
+func newInputDecoder(data []byte) *inputDecoder {
+	input := bytes.NewReader(data)
+	return &inputDecoder{Decoder: scale.NewDecoder(input), input: input}
+}
+
+// decodeLength decodes the compact length of a sequence or string. A length greater than the number of bytes
+// remaining can't be valid and is an error.
+func (d *inputDecoder) decodeLength() (int, error) {
+	n, err := d.DecodeUintCompact()
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsUint64() || n.Uint64() > uint64(d.input.Len()) {
+		return 0, fmt.Errorf("length %s exceeds the %d bytes remaining", n, d.input.Len())
+	}
+	return int(n.Uint64()), nil
+}
+
+// callTypeID returns the ID of the runtime's outer Call enum - the Call parameter of the extrinsic type.
+func (r *typeRegistry) callTypeID() (types.Si1LookupTypeID, bool) {
+	return r.extrinsicParam("Call")
+}
+
+// extrinsicParam returns the ID of a type parameter of the runtime's extrinsic type - Address, Call, Signature or
+// Extra.
+func (r *typeRegistry) extrinsicParam(name string) (types.Si1LookupTypeID, bool) {
+	extrinsic, err := r.lookup(r.meta.Extrinsic.Type)
+	if err != nil {
+		return types.Si1LookupTypeID{}, false
+	}
+	for _, param := range extrinsic.Params {
+		if string(param.Name) == name && param.HasType {
+			return param.Type, true
+		}
+	}
+	return types.Si1LookupTypeID{}, false
+}
+
+// decodeCall decodes a call - the pallet index, the call index and the call arguments.
+func (r *typeRegistry) decodeCall(decoder *inputDecoder) (*DecodedCall, error) {
+	palletIndex, err := decoder.ReadOneByte()
+	if err != nil {
+		return nil, err
+	}
+	callIndex, err := decoder.ReadOneByte()
+	if err != nil {
+		return nil, err
+	}
+	pallet, err := r.palletByIndex(palletIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !pallet.HasCalls {
+		return nil, fmt.Errorf("pallet %s has no calls", pallet.Name)
+	}
+	variant, err := r.variantByIndex(pallet.Calls.Type, callIndex)
+	if err != nil {
+		return nil, fmt.Errorf("call %d of pallet %s: %w", callIndex, pallet.Name, err)
+	}
+
+	call := &DecodedCall{Pallet: string(pallet.Name), Name: string(variant.Name), Args: map[string]interface{}{}}
+	for _, field := range variant.Fields {
+		value, err := r.decodeValue(decoder, field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding argument %s of %s: %w", field.Name, call.Method(), err)
+		}
+		call.Args[string(field.Name)] = value
+	}
+	return call, nil
+}
+
+// decodeValue decodes a value of the given type into plain Go values:
+//
+//   - structs with named fields become map[string]interface{}, tuple structs []interface{}, and wrappers with a
+//     single unnamed field, such as AccountId32([u8; 32]), the value of that field
+//   - enum variants without fields become the variant name, other variants map[string]interface{}{name: fields}
+//   - Option becomes nil or the value, the runtime's Call enum a *DecodedCall, and sp_runtime::DispatchError a
+//     DispatchError
+//   - Vec<u8> and [u8; N] become []byte, other sequences, arrays and tuples []interface{}
+//   - u8 to u64 become uint64, i8 to i64 int64, wider integers and compact integers *big.Int
+func (r *typeRegistry) decodeValue(decoder *inputDecoder, id types.Si1LookupTypeID) (interface{}, error) {
+	if callType, ok := r.callTypeID(); ok && callType.Int64() == id.Int64() {
+		return r.decodeCall(decoder)
+	}
+
+	typ, err := r.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if typeName(typ) == "DispatchError" && len(typ.Path) > 0 && string(typ.Path[0]) == "sp_runtime" {
+		return r.decodeDispatchError(decoder)
+	}
+
+	def := typ.Def
+	switch {
+	case def.IsComposite:
+		fields := def.Composite.Fields
+		if len(fields) == 1 && !fields[0].HasName {
+			return r.decodeValue(decoder, fields[0].Type)
+		}
+		return r.decodeFields(decoder, fields)
+	case def.IsVariant:
+		return r.decodeVariant(decoder, typ)
+	case def.IsSequence:
+		n, err := decoder.decodeLength()
+		if err != nil {
+			return nil, err
+		}
+		return r.decodeElements(decoder, def.Sequence.Type, n)
+	case def.IsArray:
+		if uint64(def.Array.Len) > uint64(decoder.input.Len()) {
+			return nil, fmt.Errorf("array length %d exceeds the %d bytes remaining", def.Array.Len, decoder.input.Len())
+		}
+		return r.decodeElements(decoder, def.Array.Type, int(def.Array.Len))
+	case def.IsTuple:
+		values := make([]interface{}, len(def.Tuple))
+		for i, elem := range def.Tuple {
+			if values[i], err = r.decodeValue(decoder, elem); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case def.IsPrimitive:
+		return decodePrimitive(decoder, def.Primitive.Si0TypeDefPrimitive)
+	case def.IsCompact:
+		n, err := decoder.DecodeUintCompact()
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("decoding of type %d (%s) is not supported", id.Int64(), typeName(typ))
+}
+
+// decodeFields decodes the fields of a struct or enum variant - into a map if they are named, otherwise a slice.
+func (r *typeRegistry) decodeFields(decoder *inputDecoder, fields []types.Si1Field) (interface{}, error) {
+	if len(fields) > 0 && !fields[0].HasName {
+		values := make([]interface{}, len(fields))
+		for i, field := range fields {
+			value, err := r.decodeValue(decoder, field.Type)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, err := r.decodeValue(decoder, field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		values[string(field.Name)] = value
+	}
+	return values, nil
+}
+
+func (r *typeRegistry) decodeVariant(decoder *inputDecoder, typ *types.Si1Type) (interface{}, error) {
+	index, err := decoder.ReadOneByte()
+	if err != nil {
+		return nil, err
+	}
+	var variant *types.Si1Variant
+	for i := range typ.Def.Variant.Variants {
+		if uint8(typ.Def.Variant.Variants[i].Index) == index {
+			variant = &typ.Def.Variant.Variants[i]
+			break
+		}
+	}
+	if variant == nil {
+		return nil, fmt.Errorf("variant %d not found for %s", index, typeName(typ))
+	}
+
+	if typeName(typ) == "Option" {
+		if len(variant.Fields) == 0 {
+			return nil, nil
+		}
+		return r.decodeValue(decoder, variant.Fields[0].Type)
+	}
+
+	if len(variant.Fields) == 0 {
+		return string(variant.Name), nil
+	}
+	var value interface{}
+	if len(variant.Fields) == 1 && !variant.Fields[0].HasName {
+		value, err = r.decodeValue(decoder, variant.Fields[0].Type)
+	} else {
+		value, err = r.decodeFields(decoder, variant.Fields)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("variant %s: %w", variant.Name, err)
+	}
+	return map[string]interface{}{string(variant.Name): value}, nil
+}
+
+// decodeElements decodes n elements of a sequence or array. Bytes are returned as a []byte.
+func (r *typeRegistry) decodeElements(decoder *inputDecoder, elem types.Si1LookupTypeID, n int) (interface{}, error) {
+	if r.isByte(elem) {
+		b := make([]byte, n)
+		if n == 0 {
+			return b, nil
+		}
+		if err := decoder.Read(b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	values := make([]interface{}, n)
+	for i := range values {
+		value, err := r.decodeValue(decoder, elem)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func decodePrimitive(decoder *inputDecoder, primitive types.Si0TypeDefPrimitive) (interface{}, error) {
+	switch primitive {
+	case types.IsBool:
+		var b bool
+		err := decoder.Decode(&b)
+		return b, err
+	case types.IsStr:
+		n, err := decoder.decodeLength()
+		if err != nil || n == 0 {
+			return "", err
+		}
+		b := make([]byte, n)
+		err = decoder.Read(b)
+		return string(b), err
+	case types.IsChar:
+		var c types.U32
+		err := decoder.Decode(&c)
+		return string(rune(c)), err
+	}
+
+	width, ok := primitiveSizes[primitive]
+	if !ok {
+		return nil, fmt.Errorf("unknown primitive %d", primitive)
+	}
+	le := make([]byte, width.size)
+	if err := decoder.Read(le); err != nil {
+		return nil, err
+	}
+	be := make([]byte, width.size)
+	for i, b := range le {
+		be[width.size-1-i] = b
+	}
+	n := new(big.Int).SetBytes(be)
+	if width.signed && be[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*width.size)))
+	}
+
+	switch {
+	case width.size > 8:
+		return n, nil
+	case width.signed:
+		return n.Int64(), nil
+	}
+	return n.Uint64(), nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// WatchSet is a set of watched accounts. It is safe for concurrent use, so accounts can be added while a
+// DepositEngine is running.
+type WatchSet struct {
+	mu       sync.RWMutex
+	accounts map[types.AccountID]bool
+}
+
+func NewWatchSet() *WatchSet {
+	return &WatchSet{accounts: map[types.AccountID]bool{}}
+}
+
+// LoadWatchSet reads a file of SS58 addresses, one per line. Blank lines and lines starting with # are skipped.
+func LoadWatchSet(path string) (*WatchSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	w := NewWatchSet()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		address := strings.TrimSpace(scanner.Text())
+		if address == "" || strings.HasPrefix(address, "#") {
+			continue
+		}
+		if err := w.Add(address); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Add watches the account with the given SS58 address.
+func (w *WatchSet) Add(address string) error {
+	pubKey, err := PublicKeyFromAddress(address)
+	if err != nil {
+		return err
+	}
+	return w.AddPublicKey(pubKey)
+}
+
+// AddPublicKey watches the account with the given public key.
+func (w *WatchSet) AddPublicKey(pubKey []byte) error {
+	if len(pubKey) != len(types.AccountID{}) {
+		return fmt.Errorf("invalid public key length %d", len(pubKey))
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.accounts[types.NewAccountID(pubKey)] = true
+	return nil
+}
+
+// Remove stops watching the account with the given SS58 address.
+func (w *WatchSet) Remove(address string) error {
+	pubKey, err := PublicKeyFromAddress(address)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.accounts, types.NewAccountID(pubKey))
+	return nil
+}
+
+// Contains reports whether the account with the given public key is watched.
+func (w *WatchSet) Contains(pubKey []byte) bool {
+	if len(pubKey) != len(types.AccountID{}) {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.accounts[types.NewAccountID(pubKey)]
+}
+
+func (w *WatchSet) Len() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.accounts)
+}
+
+// DepositKey identifies a deposit - the Balances.Transfer event that credited it.
+type DepositKey struct {
+	BlockHash      types.Hash `json:"block_hash"`
+	ExtrinsicIndex uint32     `json:"extrinsic_index"`
+	EventIndex     uint32     `json:"event_index"`
+}
+
+func (k DepositKey) String() string {
+	return fmt.Sprintf("%#x-%d-%d", k.BlockHash, k.ExtrinsicIndex, k.EventIndex)
+}
+
+// DepositKeyOf returns the key of a deposit built by BuildTxEventFromBlock or a DepositEngine.
+func DepositKeyOf(tx *TxEvent) (DepositKey, error) {
+	hash, err := types.NewHashFromHexString("0x" + tx.BlockHash)
+	if err != nil {
+		return DepositKey{}, fmt.Errorf("invalid block hash %s: %w", tx.BlockHash, err)
+	}
+	if len(tx.EventIndices) == 0 {
+		return DepositKey{}, fmt.Errorf("transaction %s has no events", tx.Hash)
+	}
+	return DepositKey{BlockHash: hash, ExtrinsicIndex: uint32(tx.TransactionIndex), EventIndex: tx.EventIndices[0]}, nil
+}
+
+type DepositOptions struct {
+	// Follower sets where following the chain starts and the checkpoint file. Unfinalized is set from
+	// Confirmations.
+	Follower FollowerOptions
+	// Confirmations is the number of blocks, counting the including block, on the best chain required before a
+	// deposit is emitted. Zero emits deposits once their block is finalized. A deposit is always emitted once its
+	// block is finalized.
+	Confirmations uint64
+	// DeliveredFile records the deposits emitted that may be seen again after a restart, so that none is emitted
+	// twice. If empty, the record is only kept in memory.
+	DeliveredFile string
+}
+
+// DepositEngine follows the chain and emits a TxEvent for every transfer to a watched account. Each block is
+// fetched and decoded once however many accounts are watched, and each deposit is emitted once, keyed by
+// DepositKey.
+type DepositEngine struct {
+	// OnDeposit is called for each deposit once it has the required confirmations. An error stops the engine, and
+	// the deposit is emitted again when it is restarted.
+	OnDeposit func(*TxEvent) error
+	// OnRetracted is called for an emitted deposit whose block was retracted by a reorg before it was finalized.
+	// The deposit may be emitted again if its transaction is included in another block.
+	OnRetracted func(*TxEvent) error
+
+	opts     DepositOptions
+	follower *Follower
+	txEvents func(block *types.SignedBlock, blockHash types.Hash) ([]*TxEvent, error)
+
+	// pending are the unfinalized blocks delivered by the follower and their deposits, in ascending order.
+	pending []depositBlock
+	// best is the number of the best block delivered by the follower.
+	best uint64
+	// delivered maps the deposits emitted to the number of their block.
+	delivered map[DepositKey]uint64
+}
+
+type depositBlock struct {
+	BlockRef
+	txEvents []*TxEvent
+}
+
+type deliveredDeposit struct {
+	Key    DepositKey `json:"key"`
+	Number uint64     `json:"number"`
+}
+
+func (c *Connection) NewDepositEngine(watched *WatchSet, opts DepositOptions) *DepositEngine {
+	opts.Follower.Unfinalized = opts.Confirmations > 0
+	e := &DepositEngine{
+		opts:      opts,
+		follower:  c.NewFollower(opts.Follower),
+		delivered: map[DepositKey]uint64{},
+	}
+	e.txEvents = func(block *types.SignedBlock, blockHash types.Hash) ([]*TxEvent, error) {
+		return c.blockTxEvents(block, blockHash, watched)
+	}
+	e.follower.OnBlock = e.onBlock
+	e.follower.OnFinalized = e.onFinalized
+	e.follower.OnReorg = e.onReorg
+	return e
+}
+
+// Run emits deposits until stop is closed or an error occurs.
+func (e *DepositEngine) Run(stop <-chan struct{}) error {
+	if err := e.loadDelivered(); err != nil {
+		return err
+	}
+	return e.follower.Run(stop)
+}
+
+func (e *DepositEngine) onBlock(b FollowedBlock) error {
+	found, err := e.txEvents(b.Block, b.Hash)
+	if err != nil {
+		return fmt.Errorf("error building deposits for block %v: %w", b.BlockRef, err)
+	}
+	// Transfers in failed extrinsics credit nothing.
+	txEvents := []*TxEvent{}
+	for _, txEvent := range found {
+		if txEvent.Success {
+			txEvents = append(txEvents, txEvent)
+		}
+	}
+
+	if b.Finalized {
+		if b.Number > e.best {
+			e.best = b.Number
+		}
+		return e.finalize(depositBlock{BlockRef: b.BlockRef, txEvents: txEvents})
+	}
+
+	e.best = b.Number
+	e.pending = append(e.pending, depositBlock{BlockRef: b.BlockRef, txEvents: txEvents})
+	for _, pending := range e.pending {
+		if e.best-pending.Number+1 < e.opts.Confirmations {
+			break
+		}
+		for _, txEvent := range pending.txEvents {
+			txEvent.Confirmations = e.best - pending.Number
+			if err := e.emit(txEvent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *DepositEngine) onFinalized(b BlockRef) error {
+	if len(e.pending) == 0 || e.pending[0].BlockRef != b {
+		return fmt.Errorf("finalized block %v was not delivered", b)
+	}
+	pending := e.pending[0]
+	e.pending = e.pending[1:]
+	return e.finalize(pending)
+}
+
+// finalize emits the deposits of a finalized block that haven't been emitted, and forgets the deposits emitted
+// from blocks that can no longer be delivered again.
+func (e *DepositEngine) finalize(b depositBlock) error {
+	for _, txEvent := range b.txEvents {
+		txEvent.Finalized = true
+		txEvent.Confirmations = e.best - b.Number
+		if e.follower.finalized > b.Number {
+			txEvent.FinalizedConfirmations = e.follower.finalized - b.Number
+		}
+		if err := e.emit(txEvent); err != nil {
+			return err
+		}
+	}
+	// The follower saves the checkpoint after the block is handled, so a block below this one can't be
+	// delivered again.
+	pruned := false
+	for key, number := range e.delivered {
+		if number < b.Number {
+			delete(e.delivered, key)
+			pruned = true
+		}
+	}
+	if pruned {
+		return e.saveDelivered()
+	}
+	return nil
+}
+
+func (e *DepositEngine) onReorg(r Reorg) error {
+	retracted := map[BlockRef]bool{}
+	for _, b := range r.Retracted {
+		retracted[b] = true
+	}
+	kept := e.pending[:0]
+	for _, pending := range e.pending {
+		if !retracted[pending.BlockRef] {
+			kept = append(kept, pending)
+			continue
+		}
+		for _, txEvent := range pending.txEvents {
+			key, err := DepositKeyOf(txEvent)
+			if err != nil {
+				return err
+			}
+			if _, ok := e.delivered[key]; !ok {
+				continue
+			}
+			delete(e.delivered, key)
+			if err := e.saveDelivered(); err != nil {
+				return err
+			}
+			if e.OnRetracted != nil {
+				if err := e.OnRetracted(txEvent); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	e.pending = kept
+	e.best = r.Ancestor.Number
+	return nil
+}
+
+// emit calls OnDeposit for a deposit that hasn't been emitted before.
+func (e *DepositEngine) emit(txEvent *TxEvent) error {
+	key, err := DepositKeyOf(txEvent)
+	if err != nil {
+		return err
+	}
+	if _, ok := e.delivered[key]; ok {
+		return nil
+	}
+	if e.OnDeposit != nil {
+		deposit := *txEvent
+		if err := e.OnDeposit(&deposit); err != nil {
+			return fmt.Errorf("error handling deposit %v: %w", key, err)
+		}
+	}
+	e.delivered[key] = txEvent.BlockHeight
+	return e.saveDelivered()
+}
+
+func (e *DepositEngine) loadDelivered() error {
+	if e.opts.DeliveredFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.opts.DeliveredFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var deposits []deliveredDeposit
+	if err := json.Unmarshal(data, &deposits); err != nil {
+		return fmt.Errorf("invalid delivered deposits in %s: %w", e.opts.DeliveredFile, err)
+	}
+	for _, d := range deposits {
+		e.delivered[d.Key] = d.Number
+	}
+	return nil
+}
+
+func (e *DepositEngine) saveDelivered() error {
+	if e.opts.DeliveredFile == "" {
+		return nil
+	}
+	deposits := make([]deliveredDeposit, 0, len(e.delivered))
+	for key, number := range e.delivered {
+		deposits = append(deposits, deliveredDeposit{Key: key, Number: number})
+	}
+	data, err := json.MarshalIndent(deposits, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(e.opts.DeliveredFile, data); err != nil {
+		return fmt.Errorf("error saving delivered deposits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchSet(t *testing.T) {
+	bob := "5HdfAETZTH5jTeP9rSCsqfF9kqRAZUQRL9ofj8wZBq676ua4"
+	path := filepath.Join(t.TempDir(), "watched.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("# exchange accounts\n\n"+bob+"\n"), 0600))
+
+	watched, err := LoadWatchSet(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, watched.Len())
+	pubKey, err := PublicKeyFromAddress(bob)
+	assert.NoError(t, err)
+	assert.True(t, watched.Contains(pubKey))
+	assert.False(t, watched.Contains(make([]byte, 32)))
+	assert.False(t, watched.Contains(nil))
+
+	assert.NoError(t, watched.Remove(bob))
+	assert.False(t, watched.Contains(pubKey))
+	assert.Error(t, watched.Add(bob[:len(bob)-1]+"5"))
+	assert.Error(t, watched.AddPublicKey([]byte{1, 2, 3}))
+}
+
+// testDepositEngine returns an engine whose blocks contain the given deposits, and the deposits and retractions it
+// emits.
+func testDepositEngine(opts DepositOptions, deposits map[types.Hash][]*TxEvent) (*DepositEngine, *[]string) {
+	emitted := []string{}
+	e := &DepositEngine{
+		opts:      opts,
+		follower:  &Follower{},
+		delivered: map[DepositKey]uint64{},
+	}
+	e.txEvents = func(block *types.SignedBlock, blockHash types.Hash) ([]*TxEvent, error) {
+		return deposits[blockHash], nil
+	}
+	e.OnDeposit = func(tx *TxEvent) error {
+		emitted = append(emitted, "deposit "+tx.Hash)
+		return nil
+	}
+	e.OnRetracted = func(tx *TxEvent) error {
+		emitted = append(emitted, "retracted "+tx.Hash)
+		return nil
+	}
+	return e, &emitted
+}
+
+func testDeposit(block BlockRef, name string, eventIndex uint32) *TxEvent {
+	return &TxEvent{
+		BlockHash:    hex.EncodeToString(block.Hash[:]),
+		BlockHeight:  block.Number,
+		Hash:         name,
+		Success:      true,
+		EventIndices: []uint32{eventIndex, eventIndex + 1},
+	}
+}
+
+func TestDepositEngine(t *testing.T) {
+	a11 := BlockRef{Number: 11, Hash: types.NewHash([]byte("a11"))}
+	a12 := BlockRef{Number: 12, Hash: types.NewHash([]byte("a12"))}
+	a13 := BlockRef{Number: 13, Hash: types.NewHash([]byte("a13"))}
+	b12 := BlockRef{Number: 12, Hash: types.NewHash([]byte("b12"))}
+	b13 := BlockRef{Number: 13, Hash: types.NewHash([]byte("b13"))}
+	failed := testDeposit(a11, "failed", 5)
+	failed.Success = false
+	deposits := map[types.Hash][]*TxEvent{
+		a11.Hash: {testDeposit(a11, "x", 1), failed},
+		a12.Hash: {testDeposit(a12, "y", 1)},
+		b12.Hash: {testDeposit(b12, "y", 3)},
+	}
+	path := filepath.Join(t.TempDir(), "delivered.json")
+
+	e, emitted := testDepositEngine(DepositOptions{Confirmations: 2, DeliveredFile: path}, deposits)
+	assert.NoError(t, e.onBlock(FollowedBlock{BlockRef: a11}))
+	assert.Empty(t, *emitted)
+	assert.NoError(t, e.onBlock(FollowedBlock{BlockRef: a12}))
+	assert.Equal(t, []string{"deposit x"}, *emitted)
+	assert.NoError(t, e.onBlock(FollowedBlock{BlockRef: a13}))
+	// a12 is retracted after its deposit was emitted, and the transaction is included again in b12.
+	assert.NoError(t, e.onReorg(Reorg{Ancestor: a11, Retracted: []BlockRef{a12, a13}, Enacted: []BlockRef{b12, b13}}))
+	assert.NoError(t, e.onBlock(FollowedBlock{BlockRef: b12}))
+	assert.Len(t, *emitted, 3)
+	assert.NoError(t, e.onBlock(FollowedBlock{BlockRef: b13}))
+	assert.NoError(t, e.onFinalized(a11))
+	assert.NoError(t, e.onFinalized(b12))
+	assert.Equal(t, []string{
+		"deposit x",
+		"deposit y",
+		"retracted y",
+		"deposit y",
+	}, *emitted)
+	assert.Error(t, e.onFinalized(a12))
+
+	// After a restart, blocks after the checkpoint are delivered again, but their deposits are not emitted again.
+	restarted, emitted := testDepositEngine(DepositOptions{Confirmations: 2, DeliveredFile: path}, deposits)
+	assert.NoError(t, restarted.loadDelivered())
+	assert.NoError(t, restarted.onBlock(FollowedBlock{BlockRef: b12, Finalized: true}))
+	assert.Empty(t, *emitted)
+	// Deposits below the finalized block can't be delivered again, so are forgotten.
+	assert.Len(t, restarted.delivered, 1)
+}
+
+func TestDepositEngineFinalized(t *testing.T) {
+	a11 := BlockRef{Number: 11, Hash: types.NewHash([]byte("a11"))}
+	deposits := map[types.Hash][]*TxEvent{a11.Hash: {testDeposit(a11, "x", 1), testDeposit(a11, "z", 4)}}
+
+	e, emitted := testDepositEngine(DepositOptions{}, deposits)
+	e.follower.finalized = 14
+	assert.NoError(t, e.onBlock(FollowedBlock{BlockRef: a11, Finalized: true}))
+	assert.NoError(t, e.onBlock(FollowedBlock{BlockRef: a11, Finalized: true}))
+	assert.Equal(t, []string{"deposit x", "deposit z"}, *emitted)
+	assert.True(t, deposits[a11.Hash][0].Finalized)
+	assert.Equal(t, uint64(3), deposits[a11.Hash][0].FinalizedConfirmations)
+
+	key, err := DepositKeyOf(deposits[a11.Hash][1])
+	assert.NoError(t, err)
+	assert.Equal(t, DepositKey{BlockHash: a11.Hash, ExtrinsicIndex: 0, EventIndex: 4}, key)
+}
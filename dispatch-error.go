@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+)
+
+// DispatchError is sp_runtime::DispatchError, resolved against the metadata of the runtime that emitted it. It
+// implements error, so a decoded DispatchError can be returned or wrapped directly.
+//
+// The variants of DispatchError and the width of module errors have changed between runtime versions, so the
+// layout is read from the metadata when values are decoded with a typeRegistry, as events are by decodeEvents.
+// Decoded directly with a scale.Decoder, the layout of the Polkadot runtimes contemporary with GSRPC v4 is assumed
+// and module errors are not named.
+type DispatchError struct {
+	// Kind is the DispatchError variant, e.g. "Module", "BadOrigin" or "Token".
+	Kind string
+	// ModuleIndex and ErrorIndex identify a module error.
+	ModuleIndex uint8
+	ErrorIndex  uint8
+	// Pallet is the name of the pallet that raised a module error.
+	Pallet string
+	// Name is the name of a module error, e.g. "InsufficientBalance", or of the error nested in a token,
+	// arithmetic or transactional error, e.g. "NoFunds".
+	Name string
+	// Docs is the documentation of a module error.
+	Docs string
+}
+
+func (e DispatchError) Error() string {
+	var s string
+	switch {
+	case e.Kind == "Module" && e.Pallet != "" && e.Name != "":
+		s = e.Pallet + "." + e.Name
+	case e.Kind == "Module":
+		s = fmt.Sprintf("Module(index: %d, error: %d)", e.ModuleIndex, e.ErrorIndex)
+	case e.Name != "":
+		s = e.Kind + "." + e.Name
+	default:
+		s = e.Kind
+	}
+	if e.Docs != "" {
+		s += ": " + e.Docs
+	}
+	return s
+}
+
+func (e *DispatchError) Decode(decoder scale.Decoder) error {
+	return legacyDispatchErrorLayout.decode(decoder, e)
+}
+
+// dispatchErrorLayout describes the encoding of DispatchError in a runtime.
+type dispatchErrorLayout struct {
+	variants map[uint8]dispatchErrorVariant
+	// registry names module errors. Nil if the layout wasn't read from metadata.
+	registry *typeRegistry
+}
+
+type dispatchErrorVariant struct {
+	name string
+	// errorWidth is the width of the error field of the Module variant - a u8 in older runtimes, [u8; 4] in
+	// newer ones. Zero for other variants.
+	errorWidth int
+	// nested holds the variant names of a nested error enum such as TokenError. Nil for variants without one.
+	nested map[uint8]string
+}
+
+// legacyDispatchErrorLayout is the layout used by the Polkadot runtimes for which GSRPC v4 was written.
+var legacyDispatchErrorLayout = &dispatchErrorLayout{
+	variants: map[uint8]dispatchErrorVariant{
+		0: {name: "Other"},
+		1: {name: "CannotLookup"},
+		2: {name: "BadOrigin"},
+		3: {name: "Module", errorWidth: 1},
+		4: {name: "ConsumerRemaining"},
+		5: {name: "NoProviders"},
+		6: {name: "Token", nested: map[uint8]string{}},
+		7: {name: "Arithmetic", nested: map[uint8]string{}},
+	},
+}
+
+// decodeDispatchError decodes a DispatchError with the layout of the registry's runtime.
+func (r *typeRegistry) decodeDispatchError(decoder *inputDecoder) (DispatchError, error) {
+	layout, err := r.dispatchErrorLayout()
+	if err != nil {
+		return DispatchError{}, err
+	}
+	var e DispatchError
+	if err := layout.decode(*decoder.Decoder, &e); err != nil {
+		return DispatchError{}, err
+	}
+	return e, nil
+}
+
+// dispatchErrorLayout reads the layout of DispatchError from the registry.
+func (r *typeRegistry) dispatchErrorLayout() (*dispatchErrorLayout, error) {
+	if r.dispatchErrors != nil {
+		return r.dispatchErrors, nil
+	}
+	typ, err := r.typeByPath("sp_runtime", "DispatchError")
+	if err != nil {
+		return nil, err
+	}
+	if !typ.Def.IsVariant {
+		return nil, fmt.Errorf("DispatchError is not an enum")
+	}
+
+	layout := &dispatchErrorLayout{variants: map[uint8]dispatchErrorVariant{}, registry: r}
+	for _, v := range typ.Def.Variant.Variants {
+		variant := dispatchErrorVariant{name: string(v.Name)}
+		fields := v.Fields
+		switch {
+		case variant.name == "Module":
+			// Module { index, error } in older runtimes, Module(ModuleError { index, error }) in newer ones.
+			if len(fields) == 1 {
+				inner, err := r.lookup(fields[0].Type)
+				if err != nil {
+					return nil, err
+				}
+				fields = inner.Def.Composite.Fields
+			}
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("unexpected DispatchError::Module layout with %d fields", len(fields))
+			}
+			variant.errorWidth, err = r.byteWidth(fields[1].Type)
+			if err != nil {
+				return nil, fmt.Errorf("DispatchError::Module error: %w", err)
+			}
+		case len(fields) == 0:
+		case len(fields) == 1:
+			nested, err := r.lookup(fields[0].Type)
+			if err != nil {
+				return nil, err
+			}
+			if !nested.Def.IsVariant {
+				return nil, fmt.Errorf("unexpected DispatchError::%s layout", variant.name)
+			}
+			variant.nested = map[uint8]string{}
+			for _, n := range nested.Def.Variant.Variants {
+				if len(n.Fields) != 0 {
+					return nil, fmt.Errorf("unexpected DispatchError::%s::%s layout", variant.name, n.Name)
+				}
+				variant.nested[uint8(n.Index)] = string(n.Name)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected DispatchError::%s layout", variant.name)
+		}
+		layout.variants[uint8(v.Index)] = variant
+	}
+	r.dispatchErrors = layout
+	return layout, nil
+}
+
+func (l *dispatchErrorLayout) decode(decoder scale.Decoder, e *DispatchError) error {
+	index, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	variant, ok := l.variants[index]
+	if !ok {
+		return fmt.Errorf("unknown DispatchError variant %d", index)
+	}
+	*e = DispatchError{Kind: variant.name}
+
+	switch {
+	case variant.errorWidth > 0:
+		if e.ModuleIndex, err = decoder.ReadOneByte(); err != nil {
+			return err
+		}
+		// Only the first byte of a [u8; 4] error identifies the error, the rest is reserved for error data.
+		errorBytes := make([]byte, variant.errorWidth)
+		if err := decoder.Read(errorBytes); err != nil {
+			return err
+		}
+		e.ErrorIndex = errorBytes[0]
+		if l.registry != nil {
+			l.registry.nameModuleError(e)
+		}
+	case variant.nested != nil:
+		nested, err := decoder.ReadOneByte()
+		if err != nil {
+			return err
+		}
+		e.Name = variant.nested[nested]
+		if e.Name == "" {
+			e.Name = fmt.Sprint(nested)
+		}
+	}
+	return nil
+}
+
+// nameModuleError sets the pallet, name and documentation of a module error. These are left empty if the
+// metadata doesn't describe the error.
+func (r *typeRegistry) nameModuleError(e *DispatchError) {
+	pallet, err := r.palletByIndex(e.ModuleIndex)
+	if err != nil {
+		return
+	}
+	e.Pallet = string(pallet.Name)
+	if !pallet.HasErrors {
+		return
+	}
+	variant, err := r.variantByIndex(pallet.Errors.Type, e.ErrorIndex)
+	if err != nil {
+		return
+	}
+	e.Name = string(variant.Name)
+	docs := make([]string, len(variant.Docs))
+	for i, doc := range variant.Docs {
+		docs[i] = strings.TrimSpace(string(doc))
+	}
+	e.Docs = strings.Join(docs, " ")
+}
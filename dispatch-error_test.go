@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatchErrorLayout(t *testing.T) {
+	r, err := newTypeRegistry(testMetadata(t))
+	assert.NoError(t, err)
+	layout, err := r.dispatchErrorLayout()
+	assert.NoError(t, err)
+	assert.Equal(t, "Module", layout.variants[3].name)
+	assert.Equal(t, 1, layout.variants[3].errorWidth)
+	assert.Equal(t, "NoFunds", layout.variants[6].nested[0])
+
+	// The layout is read from the registry once.
+	cached, err := r.dispatchErrorLayout()
+	assert.NoError(t, err)
+	assert.Same(t, layout, cached)
+}
+
+func TestDecodeDispatchError(t *testing.T) {
+	r, err := newTypeRegistry(testMetadata(t))
+	assert.NoError(t, err)
+	cases := []struct {
+		encoded  []byte
+		expected string
+	}{
+		{[]byte{2}, "BadOrigin"},
+		{[]byte{6, 0}, "Token.NoFunds"},
+		{[]byte{7, 1}, "Arithmetic.Overflow"},
+		{[]byte{3, 99, 0}, "Module(index: 99, error: 0)"},
+	}
+	for _, tc := range cases {
+		e, err := r.decodeDispatchError(newInputDecoder(tc.encoded))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, e.Error())
+	}
+
+	e, err := r.decodeDispatchError(newInputDecoder([]byte{3, 5, 2}))
+	assert.NoError(t, err)
+	assert.Equal(t, "Balances", e.Pallet)
+	assert.Equal(t, "InsufficientBalance", e.Name)
+	assert.Contains(t, e.Error(), "Balances.InsufficientBalance: ")
+
+	// Without metadata the error is decoded but not named.
+	e = DispatchError{}
+	assert.NoError(t, types.DecodeFromBytes([]byte{3, 5, 2}, &e))
+	assert.Equal(t, "Module(index: 5, error: 2)", e.Error())
+
+	assert.Error(t, types.DecodeFromBytes([]byte{42}, &e))
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// taggedTransactionQueueID is the runtime API ID of TaggedTransactionQueue - the 8 byte blake2b hash of the API
+// name.
+const taggedTransactionQueueID = "0xd2bc9897eed08f15"
+
+// transactionSourceExternal is sp_runtime::transaction_validity::TransactionSource::External - a transaction
+// received from the network or RPC.
+const transactionSourceExternal = 2
+
+// invalidTransactionReasons are the variants of sp_runtime::transaction_validity::InvalidTransaction, in index
+// order, with their descriptions.
+var invalidTransactionReasons = []struct{ name, description string }{
+	{"Call", "the call of the transaction is not expected"},
+	{"Payment", "the sender can't pay the fees, e.g. the account balance is too low"},
+	{"Future", "the transaction is not yet valid, e.g. the nonce is too high"},
+	{"Stale", "the transaction is outdated, e.g. the nonce has already been used"},
+	{"BadProof", "the signature is invalid, or the transaction was signed for another era, genesis hash or runtime version"},
+	{"AncientBirthBlock", "the block the transaction's era is anchored to is too old"},
+	{"ExhaustsResources", "the transaction would exhaust the resources of the current block"},
+	{"Custom", "a custom runtime error"},
+	{"BadMandatory", "a mandatory transaction failed to dispatch"},
+	{"MandatoryValidation", "a mandatory dispatch was sent as a transaction"},
+	{"BadSigner", "the sending address is disabled or known to be invalid"},
+}
+
+// unknownTransactionReasons are the variants of sp_runtime::transaction_validity::UnknownTransaction.
+var unknownTransactionReasons = []struct{ name, description string }{
+	{"CannotLookup", "the validity of the transaction could not be determined"},
+	{"NoUnsignedValidator", "no validator accepts this unsigned transaction"},
+	{"Custom", "a custom runtime error"},
+}
+
+// DryRunResult is the outcome of validating a signed extrinsic against the current state without submitting it.
+type DryRunResult struct {
+	// Valid is true if the transaction pool would accept the extrinsic.
+	Valid bool
+	// Reason names why an invalid extrinsic would be rejected, e.g. "Invalid.Stale" or "Invalid.Payment", and
+	// Description explains it.
+	Reason      string
+	Description string
+	// Priority, Longevity, Requires and Provides are the pool's view of a valid transaction. Longevity is the
+	// number of blocks for which the transaction remains valid.
+	Priority  uint64
+	Longevity uint64
+	Requires  [][]byte
+	Provides  [][]byte
+	// Applied is true if the extrinsic was also applied with system_dryRun. Many public nodes don't allow it.
+	Applied bool
+	// DispatchError is the error that the call would fail with once included, as found by system_dryRun. Nil if
+	// the call would succeed, or if it wasn't applied.
+	DispatchError error
+}
+
+// Err returns an error describing why the extrinsic would be rejected or fail, or nil if it would succeed.
+func (r DryRunResult) Err() error {
+	if !r.Valid {
+		return fmt.Errorf("%w: %s - %s", ErrTxInvalid, r.Reason, r.Description)
+	}
+	if r.DispatchError != nil {
+		return fmt.Errorf("call would fail: %w", r.DispatchError)
+	}
+	return nil
+}
+
+// DryRun checks a signed extrinsic against the latest block without submitting it. The transaction pool's
+// validity check, TaggedTransactionQueue_validate_transaction, is always run. If the node allows it, the extrinsic
+// is also applied with system_dryRun to find whether the call itself would fail.
+//
+// The returned error is only set if the checks could not be made - use DryRunResult.Err for the outcome.
+func (c *Connection) DryRun(extrinsic types.Extrinsic) (*DryRunResult, error) {
+	encoded, err := types.EncodeToBytes(extrinsic)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.validateTransaction(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error validating transaction: %w", err)
+	}
+	if !result.Valid {
+		return result, nil
+	}
+
+	var applyHex string
+	if err := c.Api.Client.Call(&applyHex, "system_dryRun", types.HexEncodeToString(encoded)); err != nil {
+		// system_dryRun is an unsafe RPC - the validity check alone has to do.
+		fmt.Printf("system_dryRun unavailable: %v\n", err)
+		return result, nil
+	}
+	apply, err := types.HexDecodeString(applyHex)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := c.Api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, fmt.Errorf("fetch metadata failed: %w", err)
+	}
+	if err := decodeApplyExtrinsicResult(meta, apply, result); err != nil {
+		return nil, fmt.Errorf("error decoding dry run result: %w", err)
+	}
+	return result, nil
+}
+
+// validateTransaction calls TaggedTransactionQueue_validate_transaction with the arguments expected by the
+// version of the API that the runtime implements.
+func (c *Connection) validateTransaction(encoded []byte) (*DryRunResult, error) {
+	runtimeVersion, err := c.Api.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return nil, fmt.Errorf("problem getting latest version of runtime: %w", err)
+	}
+	version := uint32(0)
+	for _, api := range runtimeVersion.APIs {
+		if api.APIID == taggedTransactionQueueID {
+			version = uint32(api.Version)
+		}
+	}
+
+	var args []byte
+	switch {
+	case version >= 3:
+		// (source, tx, block_hash)
+		best, err := c.Api.RPC.Chain.GetBlockHashLatest()
+		if err != nil {
+			return nil, fmt.Errorf("error getting latest block hash: %w", err)
+		}
+		args = append([]byte{transactionSourceExternal}, encoded...)
+		args = append(args, best[:]...)
+	case version == 2:
+		// (source, tx)
+		args = append([]byte{transactionSourceExternal}, encoded...)
+	default:
+		args = encoded
+	}
+
+	var validityHex string
+	if err := c.Api.Client.Call(&validityHex, "state_call", "TaggedTransactionQueue_validate_transaction", types.HexEncodeToString(args)); err != nil {
+		return nil, fmt.Errorf("TaggedTransactionQueue_validate_transaction: %w", err)
+	}
+	validity, err := types.HexDecodeString(validityHex)
+	if err != nil {
+		return nil, err
+	}
+	result := &DryRunResult{}
+	if err := decodeTransactionValidity(validity, result); err != nil {
+		return nil, fmt.Errorf("error decoding transaction validity: %w", err)
+	}
+	return result, nil
+}
+
+// decodeTransactionValidity decodes TransactionValidity = Result<ValidTransaction, TransactionValidityError>.
+func decodeTransactionValidity(data []byte, result *DryRunResult) error {
+	decoder := scale.NewDecoder(bytes.NewReader(data))
+	isErr, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	if isErr == 1 {
+		return decodeTransactionValidityError(decoder, result)
+	}
+
+	// ValidTransaction { priority, requires, provides, longevity, propagate }
+	var valid struct {
+		Priority  types.U64
+		Requires  [][]byte
+		Provides  [][]byte
+		Longevity types.U64
+		Propagate bool
+	}
+	if err := decoder.Decode(&valid); err != nil {
+		return err
+	}
+	result.Valid = true
+	result.Priority = uint64(valid.Priority)
+	result.Longevity = uint64(valid.Longevity)
+	result.Requires = valid.Requires
+	result.Provides = valid.Provides
+	return nil
+}
+
+// decodeTransactionValidityError decodes TransactionValidityError = Invalid(InvalidTransaction) |
+// Unknown(UnknownTransaction). Custom errors carry a u8 code.
+func decodeTransactionValidityError(decoder *scale.Decoder, result *DryRunResult) error {
+	kind, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	reasons, prefix := invalidTransactionReasons, "Invalid"
+	if kind == 1 {
+		reasons, prefix = unknownTransactionReasons, "Unknown"
+	}
+	index, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	result.Valid = false
+	if int(index) >= len(reasons) {
+		result.Reason = fmt.Sprintf("%s(%d)", prefix, index)
+		return nil
+	}
+	result.Reason = prefix + "." + reasons[index].name
+	result.Description = reasons[index].description
+	if reasons[index].name == "Custom" {
+		code, err := decoder.ReadOneByte()
+		if err != nil {
+			return err
+		}
+		result.Reason = fmt.Sprintf("%s(%d)", result.Reason, code)
+	}
+	return nil
+}
+
+// decodeApplyExtrinsicResult decodes the result of system_dryRun:
+// ApplyExtrinsicResult = Result<Result<(), DispatchError>, TransactionValidityError>.
+func decodeApplyExtrinsicResult(meta *types.Metadata, data []byte, result *DryRunResult) error {
+	decoder := newInputDecoder(data)
+	isErr, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	result.Applied = true
+	if isErr == 1 {
+		return decodeTransactionValidityError(decoder.Decoder, result)
+	}
+
+	failed, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	if failed == 0 {
+		return nil
+	}
+	r, err := newTypeRegistry(meta)
+	if err != nil {
+		return err
+	}
+	dispatchError, err := r.decodeDispatchError(decoder)
+	if err != nil {
+		return err
+	}
+	result.DispatchError = dispatchError
+	return nil
+}
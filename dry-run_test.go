@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestDecodeTransactionValidity(t *testing.T) {
+	// Ok(ValidTransaction { priority: 1000, requires: [], provides: [[0xaa, 0xbb]], longevity: 64, propagate: true })
+	valid := []byte{0}
+	valid = append(valid, 0xe8, 0x03, 0, 0, 0, 0, 0, 0)
+	valid = append(valid, 0)
+	valid = append(valid, 4, 8, 0xaa, 0xbb)
+	valid = append(valid, 64, 0, 0, 0, 0, 0, 0, 0)
+	valid = append(valid, 1)
+
+	result := DryRunResult{}
+	assert.NoError(t, decodeTransactionValidity(valid, &result))
+	assert.True(t, result.Valid)
+	assert.Equal(t, uint64(1000), result.Priority)
+	assert.Equal(t, uint64(64), result.Longevity)
+	assert.Equal(t, [][]byte{{0xaa, 0xbb}}, result.Provides)
+	assert.NoError(t, result.Err())
+
+	cases := []struct {
+		encoded []byte
+		reason  string
+	}{
+		{[]byte{1, 0, 3}, "Invalid.Stale"},
+		{[]byte{1, 0, 1}, "Invalid.Payment"},
+		{[]byte{1, 0, 7, 42}, "Invalid.Custom(42)"},
+		{[]byte{1, 1, 0}, "Unknown.CannotLookup"},
+	}
+	for _, tc := range cases {
+		result := DryRunResult{}
+		assert.NoError(t, decodeTransactionValidity(tc.encoded, &result))
+		assert.False(t, result.Valid)
+		assert.Equal(t, tc.reason, result.Reason)
+		assert.ErrorIs(t, result.Err(), ErrTxInvalid)
+	}
+}
+
+func TestDecodeApplyExtrinsicResult(t *testing.T) {
+	meta := testMetadata(t)
+
+	result := DryRunResult{Valid: true}
+	assert.NoError(t, decodeApplyExtrinsicResult(meta, []byte{0, 0}, &result))
+	assert.True(t, result.Applied)
+	assert.NoError(t, result.Err())
+
+	result = DryRunResult{Valid: true}
+	assert.NoError(t, decodeApplyExtrinsicResult(meta, []byte{0, 1, 3, 5, 2}, &result))
+	assert.Error(t, result.Err())
+	dispatchError, ok := result.DispatchError.(DispatchError)
+	assert.True(t, ok)
+	assert.Equal(t, "InsufficientBalance", dispatchError.Name)
+
+	result = DryRunResult{Valid: true}
+	assert.NoError(t, decodeApplyExtrinsicResult(meta, []byte{1, 0, 4}, &result))
+	assert.False(t, result.Valid)
+	assert.Equal(t, "Invalid.BadProof", result.Reason)
+}
+
+func TestTaggedTransactionQueueID(t *testing.T) {
+	h, err := blake2b.New(8, nil)
+	assert.NoError(t, err)
+	h.Write([]byte("TaggedTransactionQueue"))
+	assert.Equal(t, taggedTransactionQueueID, types.HexEncodeToString(h.Sum(nil)))
+}
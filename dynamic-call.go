@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// CallArgs holds the arguments for a dynamically built call, keyed by the argument names given in the metadata
+// (e.g. "dest" and "value" for Balances.transfer).
+//
+// Values are plain Go values:
+//   - numbers: any Go integer type, *big.Int, a decimal string or a gsrpc integer type such as types.U128
+//   - accounts: an SS58 address, a hex encoded public key, []byte or types.AccountID
+//   - byte sequences and arrays: []byte or a hex string
+//   - structs: map[string]interface{} keyed by field name, or []interface{} for unnamed fields
+//   - enums: the variant name as a string if the variant has no fields, otherwise a single entry
+//     map[string]interface{} of variant name to the variant fields
+//   - sequences and tuples: any slice
+//
+// Values that already implement SCALE encoding (e.g. types.MultiAddress, types.Call) are written as-is.
+type CallArgs map[string]interface{}
+
+// NewDynamicCall builds a call such as "Balances.transfer_keep_alive" by encoding the supplied arguments
+// against the type definitions in the V14 metadata registry. Unlike NewCall, the caller does not need to know
+// the exact SCALE types required by the call.
+func NewDynamicCall(meta *types.Metadata, call string, args CallArgs) (types.Call, error) {
+	registry, err := newTypeRegistry(meta)
+	if err != nil {
+		return types.Call{}, err
+	}
+
+	pallet, variant, err := registry.callVariant(call)
+	if err != nil {
+		return types.Call{}, err
+	}
+
+	buf := bytes.Buffer{}
+	encoder := scale.NewEncoder(&buf)
+	used := 0
+	for i, field := range variant.Fields {
+		name := string(field.Name)
+		if !field.HasName {
+			name = fmt.Sprintf("%d", i)
+		}
+		value, ok := args[name]
+		if !ok {
+			return types.Call{}, fmt.Errorf("missing argument %s for call %s", name, call)
+		}
+		used++
+		if err := registry.encodeValue(encoder, field.Type, value); err != nil {
+			return types.Call{}, fmt.Errorf("problem encoding argument %s for call %s: %w", name, call, err)
+		}
+	}
+	if used != len(args) {
+		return types.Call{}, fmt.Errorf("call %s takes arguments %s", call, fieldNames(variant.Fields))
+	}
+
+	callIndex := types.CallIndex{SectionIndex: uint8(pallet.Index), MethodIndex: uint8(variant.Index)}
+	return types.Call{CallIndex: callIndex, Args: buf.Bytes()}, nil
+}
+
+// NewDynamicCall builds a call using the latest metadata of the connected node.
+func (c *Connection) NewDynamicCall(call string, args CallArgs) (types.Call, error) {
+	meta, err := c.getLatestMetadata()
+	if err != nil {
+		return types.Call{}, fmt.Errorf("fetch metadata failed: %w", err)
+	}
+	return NewDynamicCall(meta, call, args)
+}
+
+func (r *typeRegistry) encodeValue(encoder *scale.Encoder, id types.Si1LookupTypeID, value interface{}) error {
+	typ, err := r.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	def := typ.Def
+	switch {
+	case def.IsComposite:
+		return r.encodeComposite(encoder, typ, value)
+	case def.IsVariant:
+		return r.encodeVariant(encoder, typ, value)
+	case def.IsSequence:
+		return r.encodeSequence(encoder, def.Sequence.Type, value)
+	case def.IsArray:
+		return r.encodeArray(encoder, def.Array, value)
+	case def.IsTuple:
+		return r.encodeTuple(encoder, def.Tuple, value)
+	case def.IsPrimitive:
+		return encodePrimitive(encoder, def.Primitive.Si0TypeDefPrimitive, value)
+	case def.IsCompact:
+		n, err := toBigInt(value)
+		if err != nil {
+			return err
+		}
+		return encoder.EncodeUintCompact(*n)
+	}
+	return fmt.Errorf("encoding of type %d (%s) is not supported", id.Int64(), typeName(typ))
+}
+
+func (r *typeRegistry) encodeComposite(encoder *scale.Encoder, typ *types.Si1Type, value interface{}) error {
+	if isEncodeable(value) {
+		return encoder.Encode(value)
+	}
+
+	fields := typ.Def.Composite.Fields
+
+	// Wrapper types such as AccountId32([u8; 32]) or Perbill(u32) take the value of their single field.
+	if len(fields) == 1 {
+		if m, ok := value.(map[string]interface{}); ok && fields[0].HasName {
+			if v, ok := m[string(fields[0].Name)]; ok {
+				value = v
+			}
+		}
+		return r.encodeValue(encoder, fields[0].Type, value)
+	}
+	return r.encodeFields(encoder, fields, value)
+}
+
+// encodeFields encodes the fields of a struct or enum variant. Named fields are taken from a map, unnamed fields
+// from a slice.
+func (r *typeRegistry) encodeFields(encoder *scale.Encoder, fields []types.Si1Field, value interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if m, ok := value.(map[string]interface{}); ok {
+		if len(m) != len(fields) {
+			return fmt.Errorf("expected fields %s, got %d values", fieldNames(fields), len(m))
+		}
+		for _, field := range fields {
+			v, ok := m[string(field.Name)]
+			if !ok {
+				return fmt.Errorf("missing field %s", field.Name)
+			}
+			if err := r.encodeValue(encoder, field.Type, v); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+		return nil
+	}
+
+	if len(fields) == 1 {
+		return r.encodeValue(encoder, fields[0].Type, value)
+	}
+
+	values, ok := toSlice(value)
+	if !ok || len(values) != len(fields) {
+		return fmt.Errorf("expected %d fields, got %T", len(fields), value)
+	}
+	for i, field := range fields {
+		if err := r.encodeValue(encoder, field.Type, values[i]); err != nil {
+			return fmt.Errorf("field %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *typeRegistry) encodeVariant(encoder *scale.Encoder, typ *types.Si1Type, value interface{}) error {
+	// Calls built elsewhere (e.g. by NewCall or NewDynamicCall) can be nested as-is, e.g. in Utility.batch.
+	if call, ok := value.(types.Call); ok {
+		return encoder.Encode(call)
+	}
+	if isEncodeable(value) {
+		return encoder.Encode(value)
+	}
+
+	variants := typ.Def.Variant.Variants
+	find := func(name string) *types.Si1Variant {
+		for i := range variants {
+			if string(variants[i].Name) == name {
+				return &variants[i]
+			}
+		}
+		return nil
+	}
+
+	var variant *types.Si1Variant
+	var fields interface{}
+	switch v := value.(type) {
+	case string:
+		variant = find(v)
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for name, f := range v {
+				variant = find(name)
+				fields = f
+			}
+		}
+	}
+
+	switch {
+	case variant != nil:
+	case typeName(typ) == "MultiAddress":
+		// A plain account reference for a MultiAddress is taken to mean the Id variant.
+		variant = find("Id")
+		fields = value
+	case typeName(typ) == "Option" && value == nil:
+		variant = find("None")
+	case typeName(typ) == "Option":
+		variant = find("Some")
+		fields = value
+	}
+
+	if variant == nil {
+		return fmt.Errorf("no matching variant of %s for value %v", typeName(typ), value)
+	}
+
+	if err := encoder.PushByte(uint8(variant.Index)); err != nil {
+		return err
+	}
+	if err := r.encodeFields(encoder, variant.Fields, fields); err != nil {
+		return fmt.Errorf("variant %s: %w", variant.Name, err)
+	}
+	return nil
+}
+
+func (r *typeRegistry) encodeSequence(encoder *scale.Encoder, elem types.Si1LookupTypeID, value interface{}) error {
+	if r.isByte(elem) {
+		b, err := toBytes(value)
+		if err != nil {
+			return err
+		}
+		return encoder.Encode(b)
+	}
+
+	values, ok := toSlice(value)
+	if !ok {
+		return fmt.Errorf("expected a slice, got %T", value)
+	}
+	if err := encoder.EncodeUintCompact(*big.NewInt(int64(len(values)))); err != nil {
+		return err
+	}
+	for i, v := range values {
+		if err := r.encodeValue(encoder, elem, v); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *typeRegistry) encodeArray(encoder *scale.Encoder, array types.Si1TypeDefArray, value interface{}) error {
+	length := int(array.Len)
+	if r.isByte(array.Type) {
+		b, err := toBytes(value)
+		if err != nil {
+			return err
+		}
+		if len(b) != length {
+			return fmt.Errorf("expected %d bytes, got %d", length, len(b))
+		}
+		return encoder.Write(b)
+	}
+
+	values, ok := toSlice(value)
+	if !ok || len(values) != length {
+		return fmt.Errorf("expected an array of length %d, got %T", length, value)
+	}
+	for i, v := range values {
+		if err := r.encodeValue(encoder, array.Type, v); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *typeRegistry) encodeTuple(encoder *scale.Encoder, tuple types.Si1TypeDefTuple, value interface{}) error {
+	if len(tuple) == 0 {
+		return nil
+	}
+	values, ok := toSlice(value)
+	if !ok || len(values) != len(tuple) {
+		return fmt.Errorf("expected a tuple of length %d, got %T", len(tuple), value)
+	}
+	for i, id := range tuple {
+		if err := r.encodeValue(encoder, id, values[i]); err != nil {
+			return fmt.Errorf("tuple element %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// isByte reports whether the type is a u8 primitive - sequences and arrays of these are treated as bytes.
+func (r *typeRegistry) isByte(id types.Si1LookupTypeID) bool {
+	typ, err := r.lookup(id)
+	if err != nil {
+		return false
+	}
+	return typ.Def.IsPrimitive && typ.Def.Primitive.Si0TypeDefPrimitive == types.IsU8
+}
+
+// primitiveSizes maps fixed width integer primitives to their size in bytes and signedness.
+var primitiveSizes = map[types.Si0TypeDefPrimitive]struct {
+	size   int
+	signed bool
+}{
+	types.IsU8:   {1, false},
+	types.IsU16:  {2, false},
+	types.IsU32:  {4, false},
+	types.IsU64:  {8, false},
+	types.IsU128: {16, false},
+	types.IsU256: {32, false},
+	types.IsI8:   {1, true},
+	types.IsI16:  {2, true},
+	types.IsI32:  {4, true},
+	types.IsI64:  {8, true},
+	types.IsI128: {16, true},
+	types.IsI256: {32, true},
+}
+
+func encodePrimitive(encoder *scale.Encoder, primitive types.Si0TypeDefPrimitive, value interface{}) error {
+	switch primitive {
+	case types.IsBool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected bool, got %T", value)
+		}
+		return encoder.Encode(b)
+	case types.IsStr:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", value)
+		}
+		return encoder.Encode(types.NewText(s))
+	case types.IsChar:
+		s, ok := value.(string)
+		if !ok || len([]rune(s)) != 1 {
+			return fmt.Errorf("expected a single character, got %v", value)
+		}
+		return encoder.Encode(uint32([]rune(s)[0]))
+	}
+
+	width, ok := primitiveSizes[primitive]
+	if !ok {
+		return fmt.Errorf("unknown primitive %d", primitive)
+	}
+	n, err := toBigInt(value)
+	if err != nil {
+		return err
+	}
+	b, err := fixedWidthLE(n, width.size, width.signed)
+	if err != nil {
+		return err
+	}
+	return encoder.Write(b)
+}
+
+// fixedWidthLE returns the little endian, two's complement representation of n in size bytes.
+func fixedWidthLE(n *big.Int, size int, signed bool) ([]byte, error) {
+	bits := uint(size * 8)
+	max := new(big.Int).Lsh(big.NewInt(1), bits)
+	min := big.NewInt(0)
+	if signed {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("value %s out of range for %d byte integer", n, size)
+	}
+
+	v := new(big.Int).Set(n)
+	if v.Sign() < 0 {
+		v.Add(v, new(big.Int).Lsh(big.NewInt(1), bits))
+	}
+	b := make([]byte, size)
+	v.FillBytes(b)
+	scale.Reverse(b)
+	return b, nil
+}
+
+// toBigInt converts the supported Go representations of a number into a big.Int.
+func toBigInt(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case big.Int:
+		return &v, nil
+	case types.U128:
+		return v.Int, nil
+	case types.UCompact:
+		n := big.Int(v)
+		return &n, nil
+	case string:
+		n, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return nil, fmt.Errorf("can't parse %s as an integer", v)
+		}
+		return n, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("expected an integer, got %v", v)
+		}
+		return big.NewInt(int64(v)), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), nil
+	}
+	return nil, fmt.Errorf("expected a number, got %T", value)
+}
+
+// toBytes converts []byte, fixed size byte arrays (e.g. types.AccountID), hex strings and SS58 addresses into bytes.
+func toBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if strings.HasPrefix(v, "0x") {
+			return types.HexDecodeString(v)
+		}
+		return PublicKeyFromAddress(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b, nil
+	}
+	return nil, fmt.Errorf("expected bytes, a hex string or an address, got %T", value)
+}
+
+// toSlice converts any slice or array into a []interface{}.
+func toSlice(value interface{}) ([]interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, true
+}
+
+func isEncodeable(value interface{}) bool {
+	_, ok := value.(scale.Encodeable)
+	return ok
+}
+
+func fieldNames(fields []types.Si1Field) string {
+	names := []string{}
+	for _, field := range fields {
+		names = append(names, string(field.Name))
+	}
+	return strings.Join(names, ", ")
+}
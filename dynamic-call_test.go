@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testMetadata decodes the Polkadot V14 metadata bundled with GSRPC, so that metadata-driven code can be
+// tested without a connection to a node.
+func testMetadata(t *testing.T) *types.Metadata {
+	t.Helper()
+	var meta types.Metadata
+	err := types.DecodeFromHexString(types.MetadataV14Data, &meta)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	return &meta
+}
+
+func TestNewDynamicCall(t *testing.T) {
+	meta := testMetadata(t)
+	bob, err := types.HexDecodeString(BobPubkey)
+	assert.NoError(t, err)
+
+	expected, err := types.NewCall(meta, "Balances.transfer", types.NewMultiAddressFromAccountID(bob), types.NewUCompactFromUInt(4200000000))
+	assert.NoError(t, err)
+
+	cases := []CallArgs{
+		{"dest": "14E5nqKAp3oAJcmzgZhUD2RcptBeUBScxKHgJKU4HPNcKVf3", "value": 4200000000},
+		{"dest": BobPubkey, "value": "4200000000"},
+		{"dest": map[string]interface{}{"Id": bob}, "value": uint64(4200000000)},
+		{"dest": types.NewMultiAddressFromAccountID(bob), "value": types.NewUCompactFromUInt(4200000000)},
+	}
+	for _, args := range cases {
+		call, err := NewDynamicCall(meta, "Balances.transfer", args)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, call)
+	}
+}
+
+func TestNewDynamicCallNested(t *testing.T) {
+	meta := testMetadata(t)
+
+	transfer, err := NewDynamicCall(meta, "Balances.transfer_keep_alive", CallArgs{"dest": BobPubkey, "value": 1})
+	assert.NoError(t, err)
+	remark, err := NewDynamicCall(meta, "System.remark", CallArgs{"remark": []byte("hello")})
+	assert.NoError(t, err)
+
+	batch, err := NewDynamicCall(meta, "Utility.batch_all", CallArgs{"calls": []types.Call{transfer, remark}})
+	assert.NoError(t, err)
+
+	expected, err := types.NewCall(meta, "Utility.batch_all", []types.Call{transfer, remark})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, batch)
+}
+
+func TestNewDynamicCallErrors(t *testing.T) {
+	meta := testMetadata(t)
+
+	_, err := NewDynamicCall(meta, "Balances.transfer", CallArgs{"dest": BobPubkey})
+	assert.Error(t, err)
+
+	_, err = NewDynamicCall(meta, "Balances.transfer", CallArgs{"dest": BobPubkey, "value": 1, "other": 2})
+	assert.Error(t, err)
+
+	_, err = NewDynamicCall(meta, "Balances.transfer", CallArgs{"dest": BobPubkey, "value": -1})
+	assert.Error(t, err)
+
+	_, err = NewDynamicCall(meta, "Balances.nope", CallArgs{})
+	assert.Error(t, err)
+}
+
+func TestFixedWidthLE(t *testing.T) {
+	b, err := fixedWidthLE(big.NewInt(258), 4, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 1, 0, 0}, b)
+
+	b, err = fixedWidthLE(big.NewInt(-1), 2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff}, b)
+
+	_, err = fixedWidthLE(big.NewInt(256), 1, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// DefaultMortalPeriod is the validity window, in blocks, applied to transactions unless the caller specifies
+// otherwise. At 6 second block times this is a little over 6 minutes.
+const DefaultMortalPeriod uint64 = 64
+
+const (
+	minEraPeriod uint64 = 4
+	maxEraPeriod uint64 = 1 << 16
+)
+
+// NewMortalEra returns a mortal era that starts at (or shortly before) block `current` and is valid for `period`
+// blocks. The period is rounded up to a power of two in the range [4, 65536] and, for periods larger than 4096,
+// the phase is quantized - exactly as the runtime does.
+// See: https://github.com/paritytech/substrate/blob/master/primitives/runtime/src/generic/era.rs
+func NewMortalEra(current, period uint64) types.ExtrinsicEra {
+	switch {
+	case period <= minEraPeriod:
+		period = minEraPeriod
+	case period >= maxEraPeriod:
+		period = maxEraPeriod
+	default:
+		period = 1 << bits.Len64(period-1)
+	}
+
+	phase := current % period
+	quantizeFactor := eraQuantizeFactor(period)
+	quantizedPhase := phase / quantizeFactor * quantizeFactor
+
+	encoded := uint16(bits.TrailingZeros64(period) - 1)
+	if encoded < 1 {
+		encoded = 1
+	}
+	if encoded > 15 {
+		encoded = 15
+	}
+	encoded |= uint16(quantizedPhase/quantizeFactor) << 4
+
+	return types.ExtrinsicEra{
+		IsMortalEra: true,
+		AsMortalEra: types.MortalEra{First: byte(encoded), Second: byte(encoded >> 8)},
+	}
+}
+
+// EraPeriod returns the period and phase of a mortal era.
+func EraPeriod(era types.ExtrinsicEra) (period, phase uint64, err error) {
+	if !era.IsMortalEra {
+		return 0, 0, fmt.Errorf("era is immortal")
+	}
+	encoded := uint64(era.AsMortalEra.First) | uint64(era.AsMortalEra.Second)<<8
+	period = 2 << (encoded % (1 << 4))
+	phase = (encoded >> 4) * eraQuantizeFactor(period)
+	if period < minEraPeriod || phase >= period {
+		return 0, 0, fmt.Errorf("invalid mortal era %#x", encoded)
+	}
+	return period, phase, nil
+}
+
+// EraBirth returns the first block in which a transaction with the given era is valid, where `current` is the
+// block the era was created against (or any block within the validity window). Immortal eras are born at genesis.
+func EraBirth(era types.ExtrinsicEra, current uint64) (uint64, error) {
+	if !era.IsMortalEra {
+		return 0, nil
+	}
+	period, phase, err := EraPeriod(era)
+	if err != nil {
+		return 0, err
+	}
+	if current < phase {
+		current = phase
+	}
+	return (current-phase)/period*period + phase, nil
+}
+
+// EraDeath returns the first block in which a transaction with the given era is no longer valid. Zero is
+// returned for an immortal era.
+func EraDeath(era types.ExtrinsicEra, current uint64) (uint64, error) {
+	if !era.IsMortalEra {
+		return 0, nil
+	}
+	period, _, err := EraPeriod(era)
+	if err != nil {
+		return 0, err
+	}
+	birth, err := EraBirth(era, current)
+	if err != nil {
+		return 0, err
+	}
+	return birth + period, nil
+}
+
+func eraQuantizeFactor(period uint64) uint64 {
+	if factor := period >> 12; factor > 1 {
+		return factor
+	}
+	return 1
+}
+
+// mortality determines the era and the checkpoint block that a transaction should be signed against. Mortal
+// transactions are anchored to the latest finalized block, so that the checkpoint can't be retracted; immortal
+// transactions use the genesis hash. The returned expiry is the first block at which the transaction is no
+// longer valid, or zero for an immortal transaction.
+func (c *Connection) mortality(opts TxOptions) (era types.ExtrinsicEra, checkpoint types.Hash, expiresAt uint64, err error) {
+	if opts.Immortal {
+		checkpoint, err = c.Api.RPC.Chain.GetBlockHash(0)
+		if err != nil {
+			err = fmt.Errorf("failed to get genesis hash: %w", err)
+		}
+		return types.ExtrinsicEra{IsImmortalEra: true}, checkpoint, 0, err
+	}
+
+	period := opts.Period
+	if period == 0 {
+		period = DefaultMortalPeriod
+	}
+
+	finalizedHash, err := c.Api.RPC.Chain.GetFinalizedHead()
+	if err != nil {
+		err = fmt.Errorf("failed to get finalized head: %w", err)
+		return
+	}
+	header, err := c.Api.RPC.Chain.GetHeader(finalizedHash)
+	if err != nil {
+		err = fmt.Errorf("failed to get header for finalized block %#x: %w", finalizedHash, err)
+		return
+	}
+	current := uint64(header.Number)
+
+	era = NewMortalEra(current, period)
+	birth, err := EraBirth(era, current)
+	if err != nil {
+		return
+	}
+	expiresAt, err = EraDeath(era, current)
+	if err != nil {
+		return
+	}
+
+	// For large periods the phase is quantized, so the era may begin a few blocks before the finalized head.
+	checkpoint = finalizedHash
+	if birth != current {
+		checkpoint, err = c.Api.RPC.Chain.GetBlockHash(birth)
+		if err != nil {
+			err = fmt.Errorf("failed to get checkpoint block hash at height %d: %w", birth, err)
+			return
+		}
+	}
+	return era, checkpoint, expiresAt, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMortalEra(t *testing.T) {
+	// Test vectors from the Substrate era implementation.
+	cases := []struct {
+		current, period uint64
+		encoded         types.MortalEra
+		period2, phase  uint64
+	}{
+		{42, 64, types.MortalEra{First: 0xa5, Second: 0x02}, 64, 42},
+		{20000, 32768, types.MortalEra{First: 0x4e, Second: 0x9c}, 32768, 20000},
+		{10, 1, types.MortalEra{First: 0x21, Second: 0x00}, 4, 2},
+		{100, 50, types.MortalEra{First: 0x45, Second: 0x02}, 64, 36},
+	}
+
+	for _, tc := range cases {
+		era := NewMortalEra(tc.current, tc.period)
+		assert.True(t, era.IsMortalEra)
+		assert.Equal(t, tc.encoded, era.AsMortalEra)
+
+		period, phase, err := EraPeriod(era)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.period2, period)
+		assert.Equal(t, tc.phase, phase)
+
+		// Round trip through SCALE.
+		var decoded types.ExtrinsicEra
+		assert.NoError(t, types.DecodeFromBytes([]byte{era.AsMortalEra.First, era.AsMortalEra.Second}, &decoded))
+		assert.Equal(t, era, decoded)
+	}
+}
+
+func TestEraBirthAndDeath(t *testing.T) {
+	era := NewMortalEra(1000, 64)
+	birth, err := EraBirth(era, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000), birth)
+
+	// Any block within the window yields the same birth.
+	birth, err = EraBirth(era, 1063)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000), birth)
+
+	death, err := EraDeath(era, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1064), death)
+
+	// Quantized phase - the era begins before the current block.
+	era = NewMortalEra(100003, 65536)
+	birth, err = EraBirth(era, 100003)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100000), birth)
+
+	immortal := types.ExtrinsicEra{IsImmortalEra: true}
+	death, err = EraDeath(immortal, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), death)
+}
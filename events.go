@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// Event is an event from System.Events, decoded with the metadata of the runtime that emitted it. Unlike
+// gsrpc's EventRecords, no Go type is needed for the event, so events of any pallet in any runtime decode.
+type Event struct {
+	// Index is the position of the event in the block's events.
+	Index  uint32      `json:"index"`
+	Pallet string      `json:"pallet"`
+	Name   string      `json:"name"`
+	Phase  types.Phase `json:"phase"`
+	// Fields are the event's fields in declaration order. Older runtimes don't name event fields, so fields are
+	// best looked up by position - the order has been stable as names were added.
+	Fields []EventField `json:"fields"`
+	Topics []types.Hash `json:"topics"`
+}
+
+// EventField is a field of an event. Value is decoded as described by decodeValue, except that dispatch errors
+// are decoded as DispatchError.
+type EventField struct {
+	// Name is empty for runtimes that don't name event fields.
+	Name string `json:"name,omitempty"`
+	// TypeName is the field's type as written in the runtime, e.g. "T::AccountId".
+	TypeName string      `json:"type_name,omitempty"`
+	Value    interface{} `json:"value"`
+}
+
+// Method returns the event in the form "Pallet.Name", e.g. "Balances.Transfer".
+func (e Event) Method() string {
+	return e.Pallet + "." + e.Name
+}
+
+// ExtrinsicIndex returns the index of the extrinsic that emitted the event. ok is false for events emitted
+// during block initialization or finalization.
+func (e Event) ExtrinsicIndex() (index uint32, ok bool) {
+	return e.Phase.AsApplyExtrinsic, e.Phase.IsApplyExtrinsic
+}
+
+// Field returns the value of the field at the given position, or nil if there isn't one.
+func (e Event) Field(i int) interface{} {
+	if i < 0 || i >= len(e.Fields) {
+		return nil
+	}
+	return e.Fields[i].Value
+}
+
+// AccountField returns the account ID in the field at the given position, or nil if it isn't an account.
+func (e Event) AccountField(i int) []byte {
+	account, err := accountFromValue(e.Field(i))
+	if err != nil {
+		return nil
+	}
+	return account
+}
+
+// BalanceField returns the integer in the field at the given position, or nil if it isn't an integer.
+func (e Event) BalanceField(i int) *big.Int {
+	switch v := e.Field(i).(type) {
+	case *big.Int:
+		return v
+	case uint64:
+		return new(big.Int).SetUint64(v)
+	case int64:
+		return big.NewInt(v)
+	}
+	return nil
+}
+
+// DispatchError returns the first dispatch error in the event's fields, or nil if there isn't one.
+func (e Event) DispatchError() *DispatchError {
+	for _, field := range e.Fields {
+		if err, ok := field.Value.(DispatchError); ok {
+			return &err
+		}
+	}
+	return nil
+}
+
+func (e Event) String() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		value := field.Value
+		if b, ok := value.([]byte); ok {
+			value = fmt.Sprintf("%#x", b)
+		}
+		if field.Name != "" {
+			fields[i] = fmt.Sprintf("%s: %v", field.Name, value)
+		} else {
+			fields[i] = fmt.Sprint(value)
+		}
+	}
+	phase := "Initialization"
+	switch {
+	case e.Phase.IsApplyExtrinsic:
+		phase = fmt.Sprintf("ApplyExtrinsic(%d)", e.Phase.AsApplyExtrinsic)
+	case e.Phase.IsFinalization:
+		phase = "Finalization"
+	}
+	return fmt.Sprintf("%d %s %s(%s)", e.Index, phase, e.Method(), strings.Join(fields, ", "))
+}
+
+// GetEvents fetches the events emitted in the given block and decodes them with the block's metadata.
+func (c *Connection) GetEvents(blockHash types.Hash) ([]Event, error) {
+	meta, err := c.getMetadata(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting metadata for block %#x: %w", blockHash, err)
+	}
+	return c.getBlockEvents(blockHash, meta)
+}
+
+// getBlockEvents fetches the events emitted in the given block and decodes them with meta, which must be the
+// metadata of the block's runtime.
+func (c *Connection) getBlockEvents(blockHash types.Hash, meta *types.Metadata) ([]Event, error) {
+	key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating storage key: %w", err)
+	}
+
+	raw, err := c.Api.RPC.State.GetStorageRaw(key, blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting raw storage for events in %#x: %w", blockHash, err)
+	}
+
+	events, err := decodeEvents(meta, *raw)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding events in block %#x: %w", blockHash, err)
+	}
+	return events, nil
+}
+
+// decodeEvents decodes System.Events storage - Vec<EventRecord { phase, event, topics }>. Each event is
+// identified by its pallet and variant index and its fields decoded from the type registry. If an event can't
+// be decoded, the events before it are returned with the error, since the events after it can't be located.
+func decodeEvents(meta *types.Metadata, data []byte) ([]Event, error) {
+	r, err := newTypeRegistry(meta)
+	if err != nil {
+		return nil, err
+	}
+	decoder := newInputDecoder(data)
+	n, err := decoder.decodeLength()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding number of events: %w", err)
+	}
+
+	events := make([]Event, 0, n)
+	for i := uint32(0); i < uint32(n); i++ {
+		event := Event{Index: i}
+		if err := decoder.Decode(&event.Phase); err != nil {
+			return events, fmt.Errorf("error decoding phase of event %d: %w", i, err)
+		}
+		if err := r.decodeEvent(decoder, &event); err != nil {
+			return events, fmt.Errorf("error decoding event %d: %w", i, err)
+		}
+		if err := decoder.Decode(&event.Topics); err != nil {
+			return events, fmt.Errorf("error decoding topics of event %d: %w", i, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// decodeEvent decodes the pallet index, event index and fields of an event.
+func (r *typeRegistry) decodeEvent(decoder *inputDecoder, event *Event) error {
+	palletIndex, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	eventIndex, err := decoder.ReadOneByte()
+	if err != nil {
+		return err
+	}
+	pallet, err := r.palletByIndex(palletIndex)
+	if err != nil {
+		return err
+	}
+	if !pallet.HasEvents {
+		return fmt.Errorf("pallet %s has no events", pallet.Name)
+	}
+	variant, err := r.variantByIndex(pallet.Events.Type, eventIndex)
+	if err != nil {
+		return fmt.Errorf("event %d of pallet %s: %w", eventIndex, pallet.Name, err)
+	}
+
+	event.Pallet, event.Name = string(pallet.Name), string(variant.Name)
+	event.Fields = make([]EventField, len(variant.Fields))
+	for i, field := range variant.Fields {
+		value, err := r.decodeValue(decoder, field.Type)
+		if err != nil {
+			return fmt.Errorf("error decoding field %d of %s: %w", i, event.Method(), err)
+		}
+		event.Fields[i] = EventField{Name: string(field.Name), TypeName: string(field.TypeName), Value: value}
+	}
+	return nil
+}
+
+// eventsForExtrinsic returns the events that were emitted while applying the extrinsic at the given index.
+func eventsForExtrinsic(events []Event, index uint32) []Event {
+	filtered := []Event{}
+	for _, e := range events {
+		if i, ok := e.ExtrinsicIndex(); ok && i == index {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// findEvents returns the events with the given method, e.g. "Balances.Transfer".
+func findEvents(events []Event, method string) []Event {
+	found := []Event{}
+	for _, e := range events {
+		if e.Method() == method {
+			found = append(found, e)
+		}
+	}
+	return found
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testEvent builds an event emitted by the extrinsic at the given index, with unnamed fields.
+func testEvent(extrinsic uint32, method string, values ...interface{}) Event {
+	s := strings.Split(method, ".")
+	e := Event{
+		Pallet: s[0],
+		Name:   s[1],
+		Phase:  types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: extrinsic},
+	}
+	for _, v := range values {
+		e.Fields = append(e.Fields, EventField{Value: v})
+	}
+	return e
+}
+
+func TestDecodeEvents(t *testing.T) {
+	meta := testMetadata(t)
+	from := bytes.Repeat([]byte{0x01}, 32)
+	to := bytes.Repeat([]byte{0x02}, 32)
+
+	raw := []byte{12} // three events
+	raw = append(raw,
+		0, 1, 0, 0, 0, // Phase::ApplyExtrinsic(1)
+		5, 2, // Balances.Transfer
+	)
+	raw = append(raw, from...)
+	raw = append(raw, to...)
+	raw = append(raw, 0x40, 0x42, 0x0f, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0) // 1000000
+	raw = append(raw,
+		0,             // no topics
+		0, 2, 0, 0, 0, // Phase::ApplyExtrinsic(2)
+		0, 1, // System.ExtrinsicFailed
+		3, 5, 2, // Module error Balances.InsufficientBalance
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // DispatchInfo - weight, class, pays fee
+		0,    // no topics
+		1,    // Phase::Finalization
+		0, 2, // System.CodeUpdated
+		4, // one topic
+	)
+	raw = append(raw, bytes.Repeat([]byte{0xff}, 32)...)
+
+	events, err := decodeEvents(meta, raw)
+	assert.NoError(t, err)
+	if !assert.Len(t, events, 3) {
+		return
+	}
+
+	transfer := events[0]
+	assert.Equal(t, "Balances.Transfer", transfer.Method())
+	assert.Equal(t, from, transfer.AccountField(0))
+	assert.Equal(t, to, transfer.AccountField(1))
+	assert.Equal(t, big.NewInt(1000000), transfer.BalanceField(2))
+	assert.Equal(t, "T::Balance", transfer.Fields[2].TypeName)
+	index, ok := transfer.ExtrinsicIndex()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), index)
+
+	failed := events[1]
+	assert.Equal(t, uint32(1), failed.Index)
+	if assert.NotNil(t, failed.DispatchError()) {
+		assert.Equal(t, "InsufficientBalance", failed.DispatchError().Name)
+	}
+	assert.Contains(t, eventError(failed).Error(), "Balances.InsufficientBalance")
+
+	updated := events[2]
+	assert.Equal(t, "System.CodeUpdated", updated.Method())
+	_, ok = updated.ExtrinsicIndex()
+	assert.False(t, ok)
+	assert.Equal(t, []types.Hash{types.NewHash(bytes.Repeat([]byte{0xff}, 32))}, updated.Topics)
+
+	// An event that isn't in the metadata stops decoding, but the events before it are returned.
+	raw[0] = 16
+	raw = append(raw, 0, 0, 0, 0, 0, 250, 0)
+	events, err = decodeEvents(meta, raw)
+	assert.Error(t, err)
+	assert.Len(t, events, 3)
+}
+
+func TestEventsForExtrinsic(t *testing.T) {
+	events := []Event{
+		testEvent(0, "System.ExtrinsicSuccess"),
+		testEvent(1, "Balances.Transfer"),
+		testEvent(1, "System.ExtrinsicSuccess"),
+		testEvent(2, "System.ExtrinsicFailed"),
+		{Pallet: "Balances", Name: "Transfer", Phase: types.Phase{IsFinalization: true}},
+	}
+
+	filtered := eventsForExtrinsic(events, 1)
+	if assert.Len(t, filtered, 2) {
+		assert.Equal(t, "Balances.Transfer", filtered[0].Method())
+		assert.Equal(t, "System.ExtrinsicSuccess", filtered[1].Method())
+	}
+
+	filtered = eventsForExtrinsic(events, 2)
+	assert.Len(t, findEvents(filtered, "System.ExtrinsicFailed"), 1)
+	assert.Empty(t, findEvents(filtered, "System.ExtrinsicSuccess"))
+}
@@ -4,18 +4,18 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"math/big"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 )
 
 const (
-	fromPrivKeyHexstring          = "0xb1b862df61c87139ed6d491b99a0a275fe69fd68b9765a4a442badb2cf2e8358" // 5GppzBbkybS2xtWCeq1W3uLhqEQUk5ZYDjtfMjTAUDbvYboo Csknk
-	fromAddress                   = "5GppzBbkybS2xtWCeq1W3uLhqEQUk5ZYDjtfMjTAUDbvYboo"                   // Csknk
-	localRecipient                = "0x8eaf04151687736326c9fea17e25fc5287613693c912909cb226aa4794f26a48" // 14E5nqKAp3oAJcmzgZhUD2RcptBeUBScxKHgJKU4HPNcKVf3
-	westendRecipientPubkey        = "0x725b16b586c386cf524b067a0449eeef5efc20585f46fe1783db79f1c7cca101" // 5EeeNhoYmB8QKRJ1ffimtb5trLP3bG7gyc6B1cNcnBQCPXH2
-	csknkTest2                    = "0xc526d8efca9e85fdce82c6ee694b9690e16c5552b9d276dbe7fe43f7607d4c09" // 5GXCq8BcEzNrmqQN5avx3wARnMBcRyMJzJ5BeT9WrDukpErh"
-	inclusionFee           uint64 = 0.0156 * 1e12
+	fromPrivKeyHexstring   = "0xb1b862df61c87139ed6d491b99a0a275fe69fd68b9765a4a442badb2cf2e8358" // 5GppzBbkybS2xtWCeq1W3uLhqEQUk5ZYDjtfMjTAUDbvYboo Csknk
+	fromAddress            = "5GppzBbkybS2xtWCeq1W3uLhqEQUk5ZYDjtfMjTAUDbvYboo"                   // Csknk
+	localRecipient         = "0x8eaf04151687736326c9fea17e25fc5287613693c912909cb226aa4794f26a48" // 14E5nqKAp3oAJcmzgZhUD2RcptBeUBScxKHgJKU4HPNcKVf3
+	westendRecipientPubkey = "0x725b16b586c386cf524b067a0449eeef5efc20585f46fe1783db79f1c7cca101" // 5EeeNhoYmB8QKRJ1ffimtb5trLP3bG7gyc6B1cNcnBQCPXH2
+	csknkTest2             = "0xc526d8efca9e85fdce82c6ee694b9690e16c5552b9d276dbe7fe43f7607d4c09" // 5GXCq8BcEzNrmqQN5avx3wARnMBcRyMJzJ5BeT9WrDukpErh"
 )
 
 func ex1() {
@@ -37,15 +37,6 @@ func ex1() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	//	var availableBalance float64 = 0.1607
-	var maxSpendable uint64 = availableBalance.Uint64() - inclusionFee
-	//	amount := types.NewUCompactFromUInt(westendToBase(maxSpendable))
-	amount := types.NewUCompactFromUInt(maxSpendable)
-
-	meta, err := api.RPC.State.GetMetadataLatest()
-	if err != nil {
-		panic(err)
-	}
 
 	networkID := uint8(0)
 	fromKey, err := signature.KeyringPairFromSecret(fromPrivKey, networkID)
@@ -54,67 +45,63 @@ func ex1() {
 		panic(err)
 	}
 
-	// Get the nonce for Alice
-	to, err := types.NewMultiAddressFromHexAccountID(toPubKeyHexstring)
+	meta, err := api.RPC.State.GetMetadataLatest()
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 
-	// c, err := types.NewCall(meta, "Balances.transfer", to, amount)
-	c, err := NewCall(BalanceTransferCallIndex, to, amount)
+	// Estimate the fee with a transfer of the full balance - the encoded amount can only be shorter for the
+	// final transfer, so this won't underestimate the length fee.
+	estimateCall, err := transferCall(meta, toPubKeyHexstring, availableBalance.Int, KeepAlive)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
+	fee, err := nc.estimateCallFee(fromKey.PublicKey, estimateCall, TxOptions{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("estimated fee: %s\n", fee.Total)
 
-	// Create the extrinsic
-	ext := types.NewExtrinsic(c)
+	// Sending the whole balance less fees would take the sender below the existential deposit, so the transfer
+	// would either reap the account or be rejected. Keep the existential deposit behind and send the rest.
+	ed, err := existentialDeposit(meta)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+	//	var availableBalance float64 = 0.1607
+	spendable := new(big.Int).Sub(availableBalance.Int, fee.Total)
+	spendable.Sub(spendable, ed)
+	if spendable.Sign() <= 0 {
+		log.Fatalf("balance %s does not cover the fee %s and existential deposit %s", availableBalance, fee.Total, ed)
 	}
+	//	amount := types.NewUCompactFromUInt(westendToBase(maxSpendable))
+	amount := types.NewUCompact(spendable)
 
-	genesisHash, err := api.RPC.Chain.GetBlockHash(0)
+	// Get the nonce for Alice
+	to, err := types.NewMultiAddressFromHexAccountID(toPubKeyHexstring)
 	if err != nil {
 		panic(err)
 	}
 
-	rv, err := api.RPC.State.GetRuntimeVersionLatest()
+	// c, err := types.NewCall(meta, "Balances.transfer", to, amount)
+	c, err := transferCall(meta, toPubKeyHexstring, spendable, KeepAlive)
 	if err != nil {
 		panic(err)
 	}
 
-	// Build a key that will be used to fetch account balance for the sending account
-	key, err := types.CreateStorageKey(meta, "System", "Account", fromKey.PublicKey, nil)
+	// Create the extrinsic
+	ext := types.NewExtrinsic(c)
 	if err != nil {
-		fmt.Printf("failed to create storage key: %v", err)
-		return
+		panic(err)
 	}
 
-	// NOTE: decoding data into gsrpc/types.AccountInfo will provide wrong result.
-	var accountInfo types.AccountInfo
-	//	_, err = api.RPC.State.GetStorageLatest(key, &accountInfo)
-	//	if err != nil {
-	//		fmt.Printf("problem getting accountInfo: %v", err)
-	//		return
-	//	}
-	// Sender's account info - put data into accountInfo
-	ok, err := api.RPC.State.GetStorageLatest(key, &accountInfo)
-	if err != nil || !ok {
-		fmt.Printf("problem getting accountInfo: %v", err)
+	// Mortal transaction, valid for DefaultMortalPeriod blocks from the latest finalized block.
+	o, expiresAt, err := nc.signatureOptions(fromKey.PublicKey, TxOptions{})
+	if err != nil {
+		fmt.Printf("problem setting signature options: %v", err)
 		return
 	}
-
-	// Underlying data type for the nonce is uint32 - keep it as this and let callers cast it if required.
-	nonce := uint32(accountInfo.Nonce)
-
-	o := types.SignatureOptions{
-		BlockHash:          genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(nonce)),
-		SpecVersion:        rv.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: rv.TransactionVersion,
-	}
+	nonce := o.Nonce.Int64()
 
 	fmt.Printf("Sending %v from %#x to %#x with nonce %d\n", amount, fromKey.PublicKey, to.AsID, nonce)
 
@@ -131,17 +118,12 @@ func ex1() {
 		return
 	}
 
-	era := o.Era
-	if !o.Era.IsMortalEra {
-		era = types.ExtrinsicEra{IsImmortalEra: true}
-	}
-
 	// Signer must be in MultiAddress format
 	signerPubKey := types.NewMultiAddressFromAccountID(fromKey.PublicKey)
 	fullSignature := types.ExtrinsicSignatureV4{
 		Signer:    signerPubKey,
 		Signature: types.MultiSignature{IsSr25519: true, AsSr25519: signature},
-		Era:       era,
+		Era:       payload.Era,
 		Nonce:     o.Nonce,
 		Tip:       o.Tip,
 	}
@@ -164,6 +146,7 @@ func ex1() {
 	}
 
 	fmt.Printf("%+v\n", tx.Hex())
+	fmt.Printf("expires at block %d\n", expiresAt)
 	// // Do the transfer and track the actual status
 	// sub, err := api.RPC.Author.SubmitAndWatchExtrinsic(ext)
 	// if err != nil {
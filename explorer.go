@@ -0,0 +1,460 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/vedhavyas/go-subkey"
+)
+
+// BlockDescription is a block with its extrinsics and events decoded, for use as a local block explorer. Hashes
+// are hex as in TxEvent, accounts SS58 addresses in the chain's format, and other byte values 0x-prefixed hex.
+type BlockDescription struct {
+	Number         uint64    `json:"number"`
+	Hash           string    `json:"hash"`
+	ParentHash     string    `json:"parent_hash"`
+	StateRoot      string    `json:"state_root"`
+	ExtrinsicsRoot string    `json:"extrinsics_root"`
+	Finalized      bool      `json:"finalized"`
+	TimeStamp      time.Time `json:"timeStamp"`
+	// Author is the validator that produced the block, found from the BABE or Aura pre-runtime digest. Empty for
+	// genesis and for chains using other consensus engines.
+	Author     string                 `json:"author,omitempty"`
+	Digest     []DigestLog            `json:"digest"`
+	Extrinsics []ExtrinsicDescription `json:"extrinsics"`
+	// Events are the events emitted outside extrinsics, during block initialization and finalization.
+	Events []Event `json:"events"`
+}
+
+func (b BlockDescription) String() string {
+	return fmt.Sprintf("Number: %d\nHash: %s\nParentHash: %s\nFinalized: %t\nTimestamp: %s\nAuthor: %s\nExtrinsics: %d\nEvents: %d\n",
+		b.Number,
+		b.Hash,
+		b.ParentHash,
+		b.Finalized,
+		b.TimeStamp.String(),
+		b.Author,
+		len(b.Extrinsics),
+		len(b.Events),
+	)
+}
+
+// DigestLog is an item of a block header's digest.
+type DigestLog struct {
+	// Type is one of PreRuntime, Consensus, Seal, ChangesTrieRoot, ChangesTrieSignal and Other.
+	Type string `json:"type"`
+	// Engine is the consensus engine, e.g. "BABE", "FRNK" (GRANDPA) or "aura".
+	Engine string `json:"engine,omitempty"`
+	Data   string `json:"data"`
+}
+
+// ExtrinsicDescription is an extrinsic of a block with its call decoded and the events it emitted.
+type ExtrinsicDescription struct {
+	Index  int    `json:"index"`
+	Hash   string `json:"hash"`
+	Signed bool   `json:"signed"`
+	// Signer, Nonce, Tip and Era are only set for signed extrinsics.
+	Signer string          `json:"signer,omitempty"`
+	Nonce  *big.Int        `json:"nonce,omitempty"`
+	Tip    *big.Int        `json:"tip,omitempty"`
+	Era    *EraDescription `json:"era,omitempty"`
+	Method string          `json:"method"`
+	Call   *DecodedCall    `json:"call,omitempty"`
+	// CallError is set instead of Call if the call could not be decoded with the block's metadata.
+	CallError string `json:"call_error,omitempty"`
+	Success   bool   `json:"success"`
+	// Error is the dispatch error of a failed extrinsic.
+	Error string `json:"error,omitempty"`
+	// Fee is the fee paid, including the tip, as found by extrinsicFee.
+	Fee    *big.Int `json:"fee,omitempty"`
+	Events []Event  `json:"events"`
+}
+
+// EraDescription is the validity window of a signed extrinsic. Death is the first block in which a mortal
+// extrinsic is no longer valid.
+type EraDescription struct {
+	Immortal bool   `json:"immortal"`
+	Period   uint64 `json:"period,omitempty"`
+	Phase    uint64 `json:"phase,omitempty"`
+	Birth    uint64 `json:"birth,omitempty"`
+	Death    uint64 `json:"death,omitempty"`
+}
+
+// DescribeBlock decodes the block with the given hash (0x-prefixed hex) or number - header, author, timestamp,
+// digest, and every extrinsic with its call, signer, fee, outcome and events. Each block is decoded with the
+// metadata of its own runtime.
+func (c *Connection) DescribeBlock(block string) (*BlockDescription, error) {
+	hash, err := c.blockHash(block)
+	if err != nil {
+		return nil, err
+	}
+	return c.describeBlock(hash)
+}
+
+// blockHash returns the hash of a block given as a 0x-prefixed hash or a number.
+func (c *Connection) blockHash(block string) (types.Hash, error) {
+	if strings.HasPrefix(block, "0x") {
+		hash, err := types.NewHashFromHexString(block)
+		if err != nil {
+			return types.Hash{}, fmt.Errorf("invalid block hash %s: %w", block, err)
+		}
+		return hash, nil
+	}
+	number, err := strconv.ParseUint(block, 10, 64)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("invalid block %s: want a 0x-prefixed hash or a number", block)
+	}
+	hash, err := c.Api.RPC.Chain.GetBlockHash(number)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("GetBlockHash failed for block %d: %w", number, err)
+	}
+	return hash, nil
+}
+
+func (c *Connection) describeBlock(blockHash types.Hash) (*BlockDescription, error) {
+	block, err := c.GetBlockByHash(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting block for hash %#x: %w", blockHash, err)
+	}
+	meta, err := c.getMetadata(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	events, err := c.getBlockEvents(blockHash, meta)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := c.GetBlockTimestamp(block, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	status, err := c.BlockStatus(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := c.SS58Prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	header := block.Block.Header
+	description := &BlockDescription{
+		Number:         uint64(header.Number),
+		Hash:           hex.EncodeToString(blockHash[:]),
+		ParentHash:     hex.EncodeToString(header.ParentHash[:]),
+		StateRoot:      hex.EncodeToString(header.StateRoot[:]),
+		ExtrinsicsRoot: hex.EncodeToString(header.ExtrinsicsRoot[:]),
+		Finalized:      status.Finalized,
+		TimeStamp:      *timestamp,
+		Digest:         digestLogs(header.Digest),
+		Extrinsics:     []ExtrinsicDescription{},
+		Events:         []Event{},
+	}
+	if header.Number > 0 {
+		author, err := c.blockAuthor(meta, blockHash, header)
+		if err != nil {
+			return nil, err
+		}
+		if author != nil {
+			if description.Author, err = subkey.SS58Address(author, prefix); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i, extrinsic := range block.Block.Extrinsics {
+		extrinsicDescription, err := describeExtrinsic(meta, extrinsic, i, uint64(header.Number), events, prefix)
+		if err != nil {
+			return nil, err
+		}
+		description.Extrinsics = append(description.Extrinsics, extrinsicDescription)
+	}
+	for _, e := range events {
+		if _, ok := e.ExtrinsicIndex(); !ok {
+			description.Events = append(description.Events, describeEvent(e, prefix))
+		}
+	}
+	return description, nil
+}
+
+// describeExtrinsic decodes an extrinsic of block number, given the events of the block.
+func describeExtrinsic(meta *types.Metadata, extrinsic types.Extrinsic, index int, number uint64, events []Event, prefix uint8) (ExtrinsicDescription, error) {
+	hash, err := types.GetHash(extrinsic)
+	if err != nil {
+		return ExtrinsicDescription{}, fmt.Errorf("problem getting extrinsic hash: %w", err)
+	}
+	d := ExtrinsicDescription{
+		Index:  index,
+		Hash:   hex.EncodeToString(hash[:]),
+		Signed: extrinsic.IsSigned(),
+		Events: []Event{},
+	}
+
+	if call, err := DecodeCall(meta, extrinsic.Method); err != nil {
+		d.CallError = err.Error()
+	} else {
+		d.Method = call.Method()
+		d.Call = describeValue(call, prefix).(*DecodedCall)
+	}
+
+	extrinsicEvents := eventsForExtrinsic(events, uint32(index))
+	for _, e := range extrinsicEvents {
+		d.Events = append(d.Events, describeEvent(e, prefix))
+		switch e.Method() {
+		case "System.ExtrinsicSuccess":
+			d.Success = true
+		case "System.ExtrinsicFailed":
+			if dispatchError := e.DispatchError(); dispatchError != nil {
+				d.Error = dispatchError.Error()
+			}
+		}
+	}
+
+	if !d.Signed {
+		return d, nil
+	}
+	signature := extrinsic.Signature
+	if d.Signer, err = multiAddressString(signature.Signer, prefix); err != nil {
+		return d, err
+	}
+	d.Nonce = new(big.Int).Set((*big.Int)(&signature.Nonce))
+	d.Tip = new(big.Int).Set((*big.Int)(&signature.Tip))
+	if d.Era, err = describeEra(signature.Era, number); err != nil {
+		return d, err
+	}
+	if signer, err := signerAccount(signature.Signer); err == nil {
+		d.Fee = extrinsicFee(extrinsicEvents, signer)
+	}
+	return d, nil
+}
+
+// describeEra returns the validity window of an era, relative to a block within it.
+func describeEra(era types.ExtrinsicEra, current uint64) (*EraDescription, error) {
+	if !era.IsMortalEra {
+		return &EraDescription{Immortal: true}, nil
+	}
+	period, phase, err := EraPeriod(era)
+	if err != nil {
+		return nil, err
+	}
+	birth, err := EraBirth(era, current)
+	if err != nil {
+		return nil, err
+	}
+	return &EraDescription{Period: period, Phase: phase, Birth: birth, Death: birth + period}, nil
+}
+
+// multiAddressString returns an SS58 address for MultiAddress::Id and Address32, and hex for the other kinds.
+func multiAddressString(address types.MultiAddress, prefix uint8) (string, error) {
+	switch {
+	case address.IsID:
+		return subkey.SS58Address(address.AsID[:], prefix)
+	case address.IsAddress32:
+		return subkey.SS58Address(address.AsAddress32[:], prefix)
+	case address.IsIndex:
+		return fmt.Sprintf("index %d", address.AsIndex), nil
+	case address.IsRaw:
+		return fmt.Sprintf("%#x", address.AsRaw), nil
+	case address.IsAddress20:
+		return fmt.Sprintf("%#x", address.AsAddress20), nil
+	}
+	return "", fmt.Errorf("unknown address kind")
+}
+
+// describeEvent returns a copy of an event with its field values made readable as JSON.
+func describeEvent(e Event, prefix uint8) Event {
+	fields := make([]EventField, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field
+		if account, ok := field.Value.([]byte); ok && len(account) == 32 && strings.Contains(field.TypeName, "AccountId") {
+			if address, err := subkey.SS58Address(account, prefix); err == nil {
+				fields[i].Value = address
+				continue
+			}
+		}
+		fields[i].Value = describeValue(field.Value, prefix)
+	}
+	e.Fields = fields
+	return e
+}
+
+// describeValue makes a value decoded by decodeValue readable as JSON. Byte values become 0x-prefixed hex rather
+// than base64, and the accounts of MultiAddress::Id values SS58 addresses. Other accounts in calls can't be told
+// apart from 32-byte hashes, so are left as hex. Dispatch errors become their message.
+func describeValue(value interface{}, prefix uint8) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return fmt.Sprintf("%#x", v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, elem := range v {
+			values[i] = describeValue(elem, prefix)
+		}
+		return values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for name, elem := range v {
+			if account, ok := elem.([]byte); ok && name == "Id" && len(v) == 1 && len(account) == 32 {
+				if address, err := subkey.SS58Address(account, prefix); err == nil {
+					values[name] = address
+					continue
+				}
+			}
+			values[name] = describeValue(elem, prefix)
+		}
+		return values
+	case *DecodedCall:
+		return &DecodedCall{Pallet: v.Pallet, Name: v.Name, Args: describeValue(v.Args, prefix).(map[string]interface{})}
+	case DispatchError:
+		return v.Error()
+	}
+	return value
+}
+
+// digestLogs describes the items of a header digest.
+func digestLogs(digest types.Digest) []DigestLog {
+	logs := []DigestLog{}
+	for _, item := range digest {
+		var log DigestLog
+		switch {
+		case item.IsPreRuntime:
+			log = DigestLog{Type: "PreRuntime", Engine: engineName(item.AsPreRuntime.ConsensusEngineID), Data: fmt.Sprintf("%#x", []byte(item.AsPreRuntime.Bytes))}
+		case item.IsConsensus:
+			log = DigestLog{Type: "Consensus", Engine: engineName(item.AsConsensus.ConsensusEngineID), Data: fmt.Sprintf("%#x", []byte(item.AsConsensus.Bytes))}
+		case item.IsSeal:
+			log = DigestLog{Type: "Seal", Engine: engineName(item.AsSeal.ConsensusEngineID), Data: fmt.Sprintf("%#x", []byte(item.AsSeal.Bytes))}
+		case item.IsChangesTrieRoot:
+			log = DigestLog{Type: "ChangesTrieRoot", Data: fmt.Sprintf("%#x", item.AsChangesTrieRoot[:])}
+		case item.IsChangesTrieSignal:
+			log = DigestLog{Type: "ChangesTrieSignal", Data: fmt.Sprintf("%v", item.AsChangesTrieSignal)}
+		case item.IsOther:
+			log = DigestLog{Type: "Other", Data: fmt.Sprintf("%#x", []byte(item.AsOther))}
+		}
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// engineName returns a consensus engine ID as its four characters.
+func engineName(id types.ConsensusEngineID) string {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(id))
+	return string(b)
+}
+
+// blockAuthor returns the account of the validator that produced a block, or nil if the block has no BABE or
+// Aura pre-runtime digest. The digest indexes the authority set of its consensus engine: Babe.Authorities as of
+// the block, since an epoch change is enacted as the block is initialised, or Aura.Authorities as of the parent,
+// against which Aura blocks are verified. The authority key is mapped to its validator with Session.KeyOwner; on
+// chains without a session pallet the key is the account.
+func (c *Connection) blockAuthor(meta *types.Metadata, blockHash types.Hash, header types.Header) ([]byte, error) {
+	var authorities [][32]byte
+	var keyType string
+	switch consensusEngine(header.Digest) {
+	case "BABE":
+		key, err := types.CreateStorageKey(meta, "Babe", "Authorities", nil, nil)
+		if err != nil {
+			return nil, nil
+		}
+		// Vec<(AuthorityId, BabeAuthorityWeight)>
+		var weighted []struct {
+			Key    [32]byte
+			Weight types.U64
+		}
+		if _, err := c.Api.RPC.State.GetStorage(key, &weighted, blockHash); err != nil {
+			return nil, fmt.Errorf("error getting BABE authorities at block %#x: %w", blockHash, err)
+		}
+		for _, authority := range weighted {
+			authorities = append(authorities, authority.Key)
+		}
+		keyType = "babe"
+	case "aura":
+		key, err := types.CreateStorageKey(meta, "Aura", "Authorities", nil, nil)
+		if err != nil {
+			return nil, nil
+		}
+		if _, err := c.Api.RPC.State.GetStorage(key, &authorities, header.ParentHash); err != nil {
+			return nil, fmt.Errorf("error getting Aura authorities at block %#x: %w", header.ParentHash, err)
+		}
+		keyType = "aura"
+	default:
+		return nil, nil
+	}
+	index, ok := authorIndex(header.Digest, len(authorities))
+	if !ok {
+		return nil, nil
+	}
+	return c.keyOwner(meta, keyType, authorities[index][:], blockHash)
+}
+
+// keyOwner returns the validator that registered a session key of the given key type, e.g. "babe", or the key
+// itself if the chain has no session pallet or the key has no owner.
+func (c *Connection) keyOwner(meta *types.Metadata, keyType string, sessionKey []byte, blockHash types.Hash) ([]byte, error) {
+	// KeyOwner is keyed by (KeyTypeId, Vec<u8>).
+	arg, err := types.EncodeToBytes(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := types.CreateStorageKey(meta, "Session", "KeyOwner", append([]byte(keyType), arg...))
+	if err != nil {
+		return sessionKey, nil
+	}
+	var owner types.AccountID
+	ok, err := c.Api.RPC.State.GetStorage(key, &owner, blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting owner of session key %#x: %w", sessionKey, err)
+	}
+	if !ok {
+		return sessionKey, nil
+	}
+	return owner[:], nil
+}
+
+// consensusEngine returns the engine of the first BABE or Aura pre-runtime digest, "BABE" or "aura", or "" if
+// there is none.
+func consensusEngine(digest types.Digest) string {
+	for _, item := range digest {
+		if !item.IsPreRuntime {
+			continue
+		}
+		if engine := engineName(item.AsPreRuntime.ConsensusEngineID); engine == "BABE" || engine == "aura" {
+			return engine
+		}
+	}
+	return ""
+}
+
+// authorIndex finds the index of the block author in an authority set of the given size from the pre-runtime
+// digest. A BABE pre-digest is an enum of Primary, SecondaryPlain and SecondaryVRF, each starting with the u32
+// authority index. An Aura pre-digest is the u64 slot, whose author is slot modulo the number of authorities.
+func authorIndex(digest types.Digest, authorities int) (int, bool) {
+	if authorities == 0 {
+		return 0, false
+	}
+	for _, item := range digest {
+		if !item.IsPreRuntime {
+			continue
+		}
+		data := item.AsPreRuntime.Bytes
+		switch engineName(item.AsPreRuntime.ConsensusEngineID) {
+		case "BABE":
+			if len(data) < 5 {
+				return 0, false
+			}
+			index := int(binary.LittleEndian.Uint32(data[1:5]))
+			return index, index < authorities
+		case "aura":
+			if len(data) < 8 {
+				return 0, false
+			}
+			return int(binary.LittleEndian.Uint64(data[:8]) % uint64(authorities)), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeExtrinsic(t *testing.T) {
+	meta := testMetadata(t)
+	bob, err := types.HexDecodeString(BobPubkey)
+	assert.NoError(t, err)
+	alice, err := types.HexDecodeString(AlicePubkey)
+	assert.NoError(t, err)
+
+	call, err := types.NewCall(meta, "Balances.transfer", types.NewMultiAddressFromAccountID(alice), types.NewUCompactFromUInt(1000))
+	assert.NoError(t, err)
+	extrinsic := types.NewExtrinsic(call)
+	extrinsic.Version |= types.ExtrinsicBitSigned
+	extrinsic.Signature.Signer = types.NewMultiAddressFromAccountID(bob)
+	extrinsic.Signature.Signature = types.MultiSignature{IsSr25519: true}
+	extrinsic.Signature.Era = NewMortalEra(100, 64)
+	extrinsic.Signature.Nonce = types.NewUCompactFromUInt(7)
+	extrinsic.Signature.Tip = types.NewUCompactFromUInt(10)
+
+	transfer := testEvent(1, "Balances.Transfer", bob, alice, big.NewInt(1000))
+	transfer.Fields[0].TypeName = "T::AccountId"
+	events := []Event{
+		testEvent(0, "System.ExtrinsicSuccess"),
+		transfer,
+		testEvent(1, "TransactionPayment.TransactionFeePaid", bob, big.NewInt(150), big.NewInt(10)),
+		testEvent(1, "System.ExtrinsicSuccess"),
+	}
+
+	d, err := describeExtrinsic(meta, extrinsic, 1, 120, events, 0)
+	assert.NoError(t, err)
+	assert.True(t, d.Signed)
+	assert.True(t, d.Success)
+	assert.Equal(t, receiverAddress, d.Signer)
+	assert.Equal(t, big.NewInt(7), d.Nonce)
+	assert.Equal(t, big.NewInt(10), d.Tip)
+	assert.Equal(t, big.NewInt(150), d.Fee)
+	assert.Equal(t, &EraDescription{Period: 64, Phase: 36, Birth: 100, Death: 164}, d.Era)
+	assert.Equal(t, "Balances.transfer", d.Method)
+	if assert.NotNil(t, d.Call) {
+		assert.Equal(t, map[string]interface{}{"Id": "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"}, d.Call.Args["dest"])
+	}
+	if assert.Len(t, d.Events, 3) {
+		// Typed accounts become addresses; other byte values hex.
+		assert.Equal(t, receiverAddress, d.Events[0].Field(0))
+		assert.Equal(t, "0xd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d", d.Events[0].Field(1))
+	}
+	// The block's events are left as they were.
+	assert.Equal(t, bob, events[1].Field(0))
+	_, err = json.Marshal(d)
+	assert.NoError(t, err)
+
+	inherent, err := describeExtrinsic(meta, types.Extrinsic{Version: types.ExtrinsicVersion4, Method: call}, 0, 120, events, 0)
+	assert.NoError(t, err)
+	assert.False(t, inherent.Signed)
+	assert.True(t, inherent.Success)
+	assert.Empty(t, inherent.Signer)
+	assert.Nil(t, inherent.Era)
+	assert.Nil(t, inherent.Fee)
+
+	// The fee is found for an Address32 signer too.
+	var address32 [32]byte
+	copy(address32[:], bob)
+	extrinsic.Signature.Signer = types.MultiAddress{IsAddress32: true, AsAddress32: address32}
+	d, err = describeExtrinsic(meta, extrinsic, 1, 120, events, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(150), d.Fee)
+}
+
+func TestAuthorIndex(t *testing.T) {
+	engine := func(name string) types.ConsensusEngineID {
+		return types.ConsensusEngineID(uint32(name[0]) | uint32(name[1])<<8 | uint32(name[2])<<16 | uint32(name[3])<<24)
+	}
+	babe := types.Digest{
+		{IsPreRuntime: true, AsPreRuntime: types.PreRuntime{ConsensusEngineID: engine("BABE"), Bytes: []byte{2, 3, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8}}},
+		{IsSeal: true, AsSeal: types.Seal{ConsensusEngineID: engine("BABE"), Bytes: bytes.Repeat([]byte{9}, 64)}},
+	}
+	index, ok := authorIndex(babe, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 3, index)
+	_, ok = authorIndex(babe, 3)
+	assert.False(t, ok)
+
+	aura := types.Digest{{IsPreRuntime: true, AsPreRuntime: types.PreRuntime{ConsensusEngineID: engine("aura"), Bytes: []byte{11, 0, 0, 0, 0, 0, 0, 0}}}}
+	index, ok = authorIndex(aura, 4)
+	assert.True(t, ok)
+	assert.Equal(t, 3, index)
+	_, ok = authorIndex(types.Digest{}, 4)
+	assert.False(t, ok)
+
+	assert.Equal(t, "BABE", consensusEngine(babe))
+	assert.Equal(t, "aura", consensusEngine(aura))
+	assert.Equal(t, "", consensusEngine(types.Digest{babe[1]}))
+
+	logs := digestLogs(babe)
+	assert.Equal(t, "PreRuntime", logs[0].Type)
+	assert.Equal(t, "BABE", logs[0].Engine)
+	assert.Equal(t, "0x02030000000102030405060708", logs[0].Data)
+	assert.Equal(t, "Seal", logs[1].Type)
+}
@@ -2,10 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
-	"golang.org/x/crypto/blake2b"
 )
 
 type Transaction *types.Extrinsic
@@ -32,71 +32,60 @@ func NewCall(c types.CallIndex, args ...interface{}) (types.Call, error) {
 	return types.Call{CallIndex: c, Args: a}, nil
 }
 
-func (c *Connection) NewExtrinsic(sender signature.KeyringPair, to string, amount uint64) (*types.Extrinsic, error) {
+// TxOptions controls how a transaction is built and signed.
+type TxOptions struct {
+	// Period is the number of blocks for which the transaction remains valid. Zero selects DefaultMortalPeriod.
+	Period uint64
+	// Immortal builds a transaction that never expires. A leaked immortal transaction can be replayed for as
+	// long as the nonce matches, so this should only be used where a mortal era is not an option.
+	Immortal bool
+	// Tip is an optional amount paid to the block author on top of the fee, which increases the priority of the
+	// transaction in the pool.
+	Tip uint64
+	// Nonce overrides the sender's on-chain nonce, e.g. with one allocated by a NonceManager.
+	Nonce *uint64
+}
+
+// SignedExtrinsic is a signed extrinsic along with the data needed to track its validity.
+type SignedExtrinsic struct {
+	Extrinsic types.Extrinsic
+	// Checkpoint is the hash of the block against which the transaction era is anchored.
+	Checkpoint types.Hash
+	// ExpiresAt is the first block in which the transaction is no longer valid. Zero for immortal transactions.
+	ExpiresAt uint64
+}
 
+// NewExtrinsic builds and signs a transfer extrinsic using the Balances call for opts.Mode - transfer_keep_alive
+// by default. The transfer is checked against the existential deposit before it is signed. Unless opts specifies
+// otherwise, the extrinsic is mortal and valid for DefaultMortalPeriod blocks.
+func (c *Connection) NewExtrinsic(sender signature.KeyringPair, to string, amount *big.Int, opts TransferOptions) (*SignedExtrinsic, error) {
 	meta, err := c.Api.RPC.State.GetMetadataLatest()
 	if err != nil {
 		return nil, fmt.Errorf("fetch metadata failed: %w", err)
 	}
 
-	// recipient is a MultiAddress struct which will be used to build a suitable Polkadot MultiAddress type.
-	// In our case, this will generally be a MultiAddress struct with fields set for `AsID` - containing
-	// the public key bytes and `IsID` - a boolean indicating the type of this MultiAddress.
-	recipient, err := types.NewMultiAddressFromHexAccountID(to)
-	if err != nil {
-		return nil, fmt.Errorf("recipient set: %w", err)
-	}
-
-	//	call, err := types.NewCall(meta, "Balances.transfer", recipient, types.NewUCompactFromUInt(amount))
-	call, err := NewCall(BalanceTransferCallIndex, recipient, types.NewUCompactFromUInt(amount))
+	call, err := transferCall(meta, to, amount, opts.Mode)
 	if err != nil {
 		return nil, fmt.Errorf("problem building new call: %w", err)
 	}
 
-	extrinsic := types.NewExtrinsic(call)
-
-	genesisHash, err := c.Api.RPC.Chain.GetBlockHash(0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get block hash: %w", err)
-	}
-
-	runtimeVersion, err := c.Api.RPC.State.GetRuntimeVersionLatest()
-	if err != nil {
-		return nil, fmt.Errorf("problem getting latest version of runtime: %w", err)
+	if err := c.preflightTransfer(meta, sender.PublicKey, to, amount, call, opts); err != nil {
+		return nil, err
 	}
 
 	fmt.Printf("Sending from:\nPublic key: %#x\nAddress: %s", sender.PublicKey, sender.Address)
 
-	// Build a key that will be used to fetch account balance
-	key, err := types.CreateStorageKey(meta, "System", "Account", sender.PublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("problem creating storage key: %w", err)
-	}
-
-	var senderAccountInfo types.AccountInfo
-
-	// Sender's account info
-	ok, err := c.Api.RPC.State.GetStorageLatest(key, &senderAccountInfo)
-	if err != nil || !ok {
-		return nil, fmt.Errorf("problem getting senderAccountInfo: %w", err)
-	}
-
-	fmt.Printf("Sending account data\nBalance: %v\nNonce: %v\n",
-		senderAccountInfo.Data.Free,
-		senderAccountInfo.Nonce)
+	return c.SignCall(sender, call, opts.TxOptions)
+}
 
-	// Existing on-chain nonce held against the sending account
-	nonce := uint32(senderAccountInfo.Nonce)
+// SignCall wraps the call in an extrinsic and signs it on behalf of the sender, using the sender's current
+// on-chain nonce.
+func (c *Connection) SignCall(sender signature.KeyringPair, call types.Call, opts TxOptions) (*SignedExtrinsic, error) {
+	extrinsic := types.NewExtrinsic(call)
 
-	// Set signature options.
-	o := types.SignatureOptions{
-		BlockHash:          genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(nonce)),
-		SpecVersion:        runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: runtimeVersion.TransactionVersion,
+	o, expiresAt, err := c.signatureOptions(sender.PublicKey, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Unsigned Payload - note that the entire Extrinsic is not signed, just the payload. The signature
@@ -113,18 +102,12 @@ func (c *Connection) NewExtrinsic(sender signature.KeyringPair, to string, amoun
 		return nil, fmt.Errorf("error signing payload: %w", err)
 	}
 
-	// watcher, async
-	era := o.Era
-	if !o.Era.IsMortalEra {
-		era = types.ExtrinsicEra{IsImmortalEra: true}
-	}
-
 	// Signer must be in MultiAddress format
 	signerPubKey := types.NewMultiAddressFromAccountID(sender.PublicKey)
 	fullSignature := types.ExtrinsicSignatureV4{
 		Signer:    signerPubKey,
 		Signature: types.MultiSignature{IsSr25519: true, AsSr25519: signature},
-		Era:       era,
+		Era:       payload.Era,
 		Nonce:     o.Nonce,
 		Tip:       o.Tip,
 	}
@@ -134,24 +117,87 @@ func (c *Connection) NewExtrinsic(sender signature.KeyringPair, to string, amoun
 	// mark the extrinsic as signed - extrinsic.IsSigned will now return true
 	extrinsic.Version |= types.ExtrinsicBitSigned
 
-	return &extrinsic, nil
+	return &SignedExtrinsic{
+		Extrinsic:  extrinsic,
+		Checkpoint: o.BlockHash,
+		ExpiresAt:  expiresAt,
+	}, nil
 }
 
-func signPayload(payload types.ExtrinsicPayloadV4, signer signature.KeyringPair) (types.Signature, error) {
-	// This is what must be sent to MPC network for signing.
-	bytes, err := types.EncodeToBytes(payload)
+// signatureOptions assembles the data that is signed alongside the call for a transaction sent from the
+// account with the given public key. The expiry of the transaction is also returned - see mortality().
+func (c *Connection) signatureOptions(publicKey []byte, opts TxOptions) (types.SignatureOptions, uint64, error) {
+	meta, err := c.Api.RPC.State.GetMetadataLatest()
 	if err != nil {
-		return types.Signature{}, err
+		return types.SignatureOptions{}, 0, fmt.Errorf("fetch metadata failed: %w", err)
+	}
+
+	genesisHash, err := c.Api.RPC.Chain.GetBlockHash(0)
+	if err != nil {
+		return types.SignatureOptions{}, 0, fmt.Errorf("failed to get block hash: %w", err)
+	}
+
+	runtimeVersion, err := c.Api.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return types.SignatureOptions{}, 0, fmt.Errorf("problem getting latest version of runtime: %w", err)
+	}
+
+	era, checkpoint, expiresAt, err := c.mortality(opts)
+	if err != nil {
+		return types.SignatureOptions{}, 0, fmt.Errorf("problem setting transaction era: %w", err)
 	}
 
+	nonce, err := c.accountNonce(meta, publicKey, opts)
+	if err != nil {
+		return types.SignatureOptions{}, 0, err
+	}
+
+	return types.SignatureOptions{
+		BlockHash:          checkpoint,
+		Era:                era,
+		GenesisHash:        genesisHash,
+		Nonce:              types.NewUCompactFromUInt(nonce),
+		SpecVersion:        runtimeVersion.SpecVersion,
+		Tip:                types.NewUCompactFromUInt(opts.Tip),
+		TransactionVersion: runtimeVersion.TransactionVersion,
+	}, expiresAt, nil
+}
+
+// accountNonce returns the nonce set in opts if present, otherwise the sender's nonce from chain state.
+func (c *Connection) accountNonce(meta *types.Metadata, publicKey []byte, opts TxOptions) (uint64, error) {
+	if opts.Nonce != nil {
+		return *opts.Nonce, nil
+	}
+
+	// Build a key that will be used to fetch account balance
+	key, err := types.CreateStorageKey(meta, "System", "Account", publicKey)
+	if err != nil {
+		return 0, fmt.Errorf("problem creating storage key: %w", err)
+	}
+
+	var senderAccountInfo types.AccountInfo
+
+	// Sender's account info
+	ok, err := c.Api.RPC.State.GetStorageLatest(key, &senderAccountInfo)
+	if err != nil || !ok {
+		return 0, fmt.Errorf("problem getting senderAccountInfo: %w", err)
+	}
+
+	// Existing on-chain nonce held against the sending account
+	return uint64(senderAccountInfo.Nonce), nil
+}
+
+func signPayload(payload types.ExtrinsicPayloadV4, signer signature.KeyringPair) (types.Signature, error) {
+	// This is what must be sent to MPC network for signing.
+	//
 	// Sign data with the private key under the given derivation path - for GSRPC, the signature scheme
 	// is set to sr25519. Depending on how the MPC signing works, we will probably just need to add the
 	// signature over the message bytes into a Signature struct (see below)
 	//
-	// NOTE: If data is longer than 256 bytes, hash first:
-	if len(bytes) > 256 {
-		h := blake2b.Sum256(bytes)
-		bytes = h[:]
+	// NOTE: If data is longer than 256 bytes, it is hashed first - see signingPayload.
+	bytes, err := signingPayload(payload)
+	if err != nil {
+		return types.Signature{}, err
 	}
 
 	sig, err := signature.Sign(bytes, signer.URI)
@@ -251,70 +297,38 @@ func signExtrinsic(extrinsic *(types.Extrinsic), signer signature.KeyringPair, o
 	return nil
 }
 
-func (c *Connection) GenTransaction(currency int, from, to string, amount uint64) (tx *Transaction, toBeSigned []byte, err error) {
+// GenTransaction builds an unsigned transfer transaction and returns it along with the payload bytes that must be
+// signed. As for NewExtrinsic, the Balances call is selected by opts.Mode - transfer_keep_alive by default - and
+// the transfer is checked against the existential deposit first.
+func (c *Connection) GenTransaction(currency int, from, to string, amount *big.Int, opts TransferOptions) (tx *Transaction, toBeSigned []byte, err error) {
 	meta, err := c.Api.RPC.State.GetMetadataLatest()
 	if err != nil {
 		return nil, nil, fmt.Errorf("fetch metadata failed: %w", err)
 	}
 
-	// recipient is a MultiAddress struct which will be used to build a suitable Polkadot MultiAddress type.
-	// In our case, this will generally be a MultiAddress struct with fields set for `AsID` - containing
-	// the public key bytes and `IsID` - a boolean indicating the type of this MultiAddress.
-	recipient, err := types.NewMultiAddressFromHexAccountID(to)
+	fromPubKey, err := PublicKeyFromAddress(from)
 	if err != nil {
-		return nil, nil, fmt.Errorf("recipient set: %w", err)
+		return nil, nil, err
 	}
 
-	call, err := types.NewCall(meta, "Balances.transfer", recipient, types.NewUCompactFromUInt(amount))
+	call, err := transferCall(meta, to, amount, opts.Mode)
 	if err != nil {
 		return nil, nil, fmt.Errorf("problem building new call: %w", err)
 	}
 
-	extrinsic := types.NewExtrinsic(call)
-
-	genesisHash, err := c.Api.RPC.Chain.GetBlockHash(0)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get block hash: %w", err)
+	if err := c.preflightTransfer(meta, fromPubKey, to, amount, call, opts); err != nil {
+		return nil, nil, err
 	}
 
-	runtimeVersion, err := c.Api.RPC.State.GetRuntimeVersionLatest()
-	if err != nil {
-		return nil, nil, fmt.Errorf("problem getting latest version of runtime: %w", err)
-	}
+	extrinsic := types.NewExtrinsic(call)
 
-	// Build a key that will be used to fetch account balance
-	fromPubKey, err := PublicKeyFromAddress(from)
+	// Set signature options - unless opts specifies otherwise, the transaction is mortal, valid for
+	// DefaultMortalPeriod blocks.
+	o, _, err := c.signatureOptions(fromPubKey, opts.TxOptions)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	key, err := types.CreateStorageKey(meta, "System", "Account", fromPubKey)
-	if err != nil {
-		return nil, nil, fmt.Errorf("problem creating storage key: %w", err)
-	}
-
-	var senderAccountInfo types.AccountInfo
-
-	// Sender's account info
-	ok, err := c.Api.RPC.State.GetStorageLatest(key, &senderAccountInfo)
-	if err != nil || !ok {
-		return nil, nil, fmt.Errorf("problem getting senderAccountInfo: %w", err)
-	}
-
-	// Existing on-chain nonce held against this account
-	nonce := uint32(senderAccountInfo.Nonce)
-
-	// Set signature options.
-	o := types.SignatureOptions{
-		BlockHash:          genesisHash,
-		Era:                types.ExtrinsicEra{IsMortalEra: false},
-		GenesisHash:        genesisHash,
-		Nonce:              types.NewUCompactFromUInt(uint64(nonce)),
-		SpecVersion:        runtimeVersion.SpecVersion,
-		Tip:                types.NewUCompactFromUInt(0),
-		TransactionVersion: runtimeVersion.TransactionVersion,
-	}
-
 	// Unsigned Payload
 	payload, err := createUnsignedPayload(&extrinsic, o)
 	if err != nil {
@@ -325,5 +339,14 @@ func (c *Connection) GenTransaction(currency int, from, to string, amount uint64
 		return nil, nil, err
 	}
 
-	return nil, payloadBytes, nil
+	// The signature must be attached with the same era, nonce and tip that were signed - these are set on
+	// the returned transaction so that the caller only needs to add the signer and signature.
+	extrinsic.Signature = types.ExtrinsicSignatureV4{
+		Era:   payload.Era,
+		Nonce: o.Nonce,
+		Tip:   o.Tip,
+	}
+	t := Transaction(&extrinsic)
+
+	return &t, payloadBytes, nil
 }
@@ -20,14 +20,15 @@ func TestEncodeDecodeExtrinsic(t *testing.T) {
 	}
 	//	AlicePubkey := "0xd43593c715fdd31c61141abd04a99fd6822c8558854ccde39a5684e7a56da27d" // 15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5
 	BobPubkey := "0x8eaf04151687736326c9fea17e25fc5287613693c912909cb226aa4794f26a48" // 14E5nqKAp3oAJcmzgZhUD2RcptBeUBScxKHgJKU4HPNcKVf3
-	var amount uint64 = 4200000000
+	amount := big.NewInt(4200000000)
 
 	sender, ok := signature.LoadKeyringPairFromEnv()
 	if !ok {
 		sender = signature.TestKeyringPairAlice
 	}
-	extrinsic, err := c.NewExtrinsic(sender, BobPubkey, amount)
+	signed, err := c.NewExtrinsic(sender, BobPubkey, amount, TransferOptions{})
 	assert.NoError(t, err)
+	extrinsic := &signed.Extrinsic
 
 	extrinsicString, err := types.EncodeToHexString(extrinsic)
 	if err != nil {
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// dispatchClasses are the variants of frame_support::weights::DispatchClass, in index order.
+var dispatchClasses = []string{"normal", "operational", "mandatory"}
+
+// rpcMethodNotFound is the JSON-RPC error code for a method the node does not serve.
+const rpcMethodNotFound = -32601
+
+// Weight is the weight of an extrinsic. Runtimes before Weight V2 report only the ref time, as a u64.
+type Weight struct {
+	RefTime   uint64 `json:"ref_time"`
+	ProofSize uint64 `json:"proof_size"`
+}
+
+func (w Weight) String() string {
+	return fmt.Sprintf("%d (proof size %d)", w.RefTime, w.ProofSize)
+}
+
+// UnmarshalJSON accepts a Weight V1 number or a Weight V2 {ref_time, proof_size} object, with snake or camel case
+// keys.
+func (w *Weight) UnmarshalJSON(data []byte) error {
+	var refTime uint64
+	if err := json.Unmarshal(data, &refTime); err == nil {
+		*w = Weight{RefTime: refTime}
+		return nil
+	}
+	var v2 struct {
+		RefTime        *uint64 `json:"ref_time"`
+		RefTimeCamel   *uint64 `json:"refTime"`
+		ProofSize      uint64  `json:"proof_size"`
+		ProofSizeCamel uint64  `json:"proofSize"`
+	}
+	if err := json.Unmarshal(data, &v2); err != nil {
+		return fmt.Errorf("invalid weight %s: %w", data, err)
+	}
+	switch {
+	case v2.RefTime != nil:
+		*w = Weight{RefTime: *v2.RefTime, ProofSize: v2.ProofSize}
+	case v2.RefTimeCamel != nil:
+		*w = Weight{RefTime: *v2.RefTimeCamel, ProofSize: v2.ProofSizeCamel}
+	default:
+		return fmt.Errorf("invalid weight %s", data)
+	}
+	return nil
+}
+
+// FeeEstimate is the fee that the chain would charge for including an extrinsic at the current block.
+type FeeEstimate struct {
+	Weight Weight
+	Class  string
+	// PartialFee is the inclusion fee - the sum of the base, length and adjusted weight fees. It does not
+	// include the tip.
+	PartialFee        *big.Int
+	BaseFee           *big.Int
+	LenFee            *big.Int
+	AdjustedWeightFee *big.Int
+	Tip               *big.Int
+	// Total is the amount that will be withdrawn from the sender: PartialFee + Tip.
+	Total *big.Int
+}
+
+func (f FeeEstimate) String() string {
+	return fmt.Sprintf("Weight: %s\nClass: %s\nPartialFee: %s\nBaseFee: %s\nLenFee: %s\nAdjustedWeightFee: %s\nTip: %s\nTotal: %s\n",
+		f.Weight,
+		f.Class,
+		f.PartialFee,
+		f.BaseFee,
+		f.LenFee,
+		f.AdjustedWeightFee,
+		f.Tip,
+		f.Total,
+	)
+}
+
+// feeDetails is the response of payment_queryFeeDetails. InclusionFee is null for extrinsics that don't pay fees.
+type feeDetails struct {
+	InclusionFee *struct {
+		BaseFee           balanceValue `json:"baseFee"`
+		LenFee            balanceValue `json:"lenFee"`
+		AdjustedWeightFee balanceValue `json:"adjustedWeightFee"`
+	} `json:"inclusionFee"`
+}
+
+// EstimateFee estimates the fee for a signed extrinsic using the TransactionPayment runtime API. The estimate is
+// made against the latest block - the fee actually charged may differ if the fee multiplier changes before the
+// extrinsic is included.
+func (c *Connection) EstimateFee(extrinsic types.Extrinsic) (*FeeEstimate, error) {
+	estimate := &FeeEstimate{
+		Tip:               big.NewInt(0),
+		BaseFee:           big.NewInt(0),
+		LenFee:            big.NewInt(0),
+		AdjustedWeightFee: big.NewInt(0),
+	}
+	if extrinsic.IsSigned() {
+		tip := big.Int(extrinsic.Signature.Tip)
+		estimate.Tip = &tip
+	}
+
+	extrinsicHex, err := types.EncodeToHexString(extrinsic)
+	if err != nil {
+		return nil, err
+	}
+
+	info := Fee{}
+	err = c.Api.Client.Call(&info, "payment_queryInfo", extrinsicHex)
+	if isMethodNotFound(err) {
+		// Nodes that no longer expose the payment RPCs still serve the runtime API directly.
+		if stateErr := c.estimateFeeStateCall(extrinsic, estimate); stateErr != nil {
+			return nil, fmt.Errorf("fee estimation failed: payment_queryInfo: %v, state_call: %w", err, stateErr)
+		}
+		estimate.Total = new(big.Int).Add(estimate.PartialFee, estimate.Tip)
+		return estimate, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("payment_queryInfo failed: %w", err)
+	}
+
+	estimate.Weight = info.Weight
+	estimate.Class = strings.ToLower(info.Class)
+	estimate.PartialFee, err = parseBalance(info.PartialFee)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing partial fee %s: %w", info.PartialFee, err)
+	}
+
+	details := feeDetails{}
+	err = c.Api.Client.Call(&details, "payment_queryFeeDetails", extrinsicHex)
+	if err != nil {
+		return nil, fmt.Errorf("payment_queryFeeDetails failed: %w", err)
+	}
+	if details.InclusionFee != nil {
+		estimate.BaseFee = details.InclusionFee.BaseFee.Int
+		estimate.LenFee = details.InclusionFee.LenFee.Int
+		estimate.AdjustedWeightFee = details.InclusionFee.AdjustedWeightFee.Int
+	}
+
+	estimate.Total = new(big.Int).Add(estimate.PartialFee, estimate.Tip)
+	return estimate, nil
+}
+
+// isMethodNotFound reports whether an RPC call failed because the node does not serve the method.
+func isMethodNotFound(err error) bool {
+	var rpcErr interface{ ErrorCode() int }
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode() == rpcMethodNotFound
+	}
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "method not found")
+}
+
+// estimateFeeStateCall queries TransactionPaymentApi_query_info and TransactionPaymentApi_query_fee_details via
+// state_call. Both take the SCALE encoded extrinsic followed by its encoded length as a u32.
+func (c *Connection) estimateFeeStateCall(extrinsic types.Extrinsic, estimate *FeeEstimate) error {
+	encoded, err := types.EncodeToBytes(extrinsic)
+	if err != nil {
+		return err
+	}
+	length, err := types.EncodeToBytes(types.NewU32(uint32(len(encoded))))
+	if err != nil {
+		return err
+	}
+	args := types.HexEncodeToString(append(encoded, length...))
+
+	var infoHex string
+	if err := c.Api.Client.Call(&infoHex, "state_call", "TransactionPaymentApi_query_info", args); err != nil {
+		return fmt.Errorf("TransactionPaymentApi_query_info: %w", err)
+	}
+	info, err := types.HexDecodeString(infoHex)
+	if err != nil {
+		return err
+	}
+
+	meta, err := c.getLatestMetadata()
+	if err != nil {
+		return fmt.Errorf("can't get meta for api: %w", err)
+	}
+	r, err := newTypeRegistry(meta)
+	if err != nil {
+		return err
+	}
+
+	// RuntimeDispatchInfo { weight: Weight, class: DispatchClass, partial_fee: u128 }
+	decoder := scale.NewDecoder(bytes.NewReader(info))
+	weight, err := decodeWeight(decoder, r.hasWeightV2())
+	if err != nil {
+		return fmt.Errorf("error decoding weight: %w", err)
+	}
+	var class uint8
+	var partialFee types.U128
+	if err := decoder.Decode(&class); err != nil {
+		return fmt.Errorf("error decoding dispatch class: %w", err)
+	}
+	if err := decoder.Decode(&partialFee); err != nil {
+		return fmt.Errorf("error decoding partial fee: %w", err)
+	}
+	estimate.Weight = weight
+	estimate.PartialFee = partialFee.Int
+	if int(class) < len(dispatchClasses) {
+		estimate.Class = dispatchClasses[class]
+	}
+
+	var detailsHex string
+	if err := c.Api.Client.Call(&detailsHex, "state_call", "TransactionPaymentApi_query_fee_details", args); err != nil {
+		return fmt.Errorf("TransactionPaymentApi_query_fee_details: %w", err)
+	}
+	details, err := types.HexDecodeString(detailsHex)
+	if err != nil {
+		return err
+	}
+
+	// FeeDetails { inclusion_fee: Option<InclusionFee>, tip: u128 }
+	decoder = scale.NewDecoder(bytes.NewReader(details))
+	var inclusionFee struct {
+		BaseFee           types.U128
+		LenFee            types.U128
+		AdjustedWeightFee types.U128
+	}
+	var hasInclusionFee bool
+	if err := decoder.DecodeOption(&hasInclusionFee, &inclusionFee); err != nil {
+		return fmt.Errorf("error decoding fee details: %w", err)
+	}
+	if hasInclusionFee {
+		estimate.BaseFee = inclusionFee.BaseFee.Int
+		estimate.LenFee = inclusionFee.LenFee.Int
+		estimate.AdjustedWeightFee = inclusionFee.AdjustedWeightFee.Int
+	}
+	return nil
+}
+
+// hasWeightV2 reports whether the runtime uses Weight V2, sp_weights::weight_v2::Weight.
+func (r *typeRegistry) hasWeightV2() bool {
+	_, err := r.typeByPath("sp_weights", "weight_v2", "Weight")
+	return err == nil
+}
+
+// decodeWeight decodes a SCALE encoded Weight - a u64 before Weight V2, then { ref_time: Compact<u64>,
+// proof_size: Compact<u64> }.
+func decodeWeight(decoder *scale.Decoder, v2 bool) (Weight, error) {
+	if !v2 {
+		var refTime types.U64
+		if err := decoder.Decode(&refTime); err != nil {
+			return Weight{}, err
+		}
+		return Weight{RefTime: uint64(refTime)}, nil
+	}
+	refTime, err := decoder.DecodeUintCompact()
+	if err != nil {
+		return Weight{}, err
+	}
+	proofSize, err := decoder.DecodeUintCompact()
+	if err != nil {
+		return Weight{}, err
+	}
+	return Weight{RefTime: refTime.Uint64(), ProofSize: proofSize.Uint64()}, nil
+}
+
+// balanceValue unmarshals a balance that a node may return as a JSON number, a decimal string or a hex string.
+type balanceValue struct {
+	*big.Int
+}
+
+func (b *balanceValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	n, err := parseBalance(s)
+	if err != nil {
+		return err
+	}
+	b.Int = n
+	return nil
+}
+
+// parseBalance parses a decimal or 0x prefixed hex string.
+func parseBalance(s string) (*big.Int, error) {
+	n, ok := new(big.Int), false
+	if strings.HasPrefix(s, "0x") {
+		n, ok = n.SetString(s[2:], 16)
+	} else {
+		n, ok = n.SetString(s, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %s", s)
+	}
+	return n, nil
+}
+
+// extrinsicFee finds the fee, including the tip, paid by the signer in the events of an extrinsic:
+//
+//   - Runtimes that emit TransactionPayment.TransactionFeePaid report the actual fee, tip included, directly.
+//   - Older runtimes withdraw the estimated fee before dispatch. After dispatch, the fee for unused weight is
+//     refunded to the signer and the rest deposited with the treasury and the block author. These deposits are
+//     the last events before System.ExtrinsicSuccess or System.ExtrinsicFailed and add up to the withdrawal, so
+//     they are only counted up to that amount - deposits made by the call itself come before them.
+//   - Failing that, the fee is the withdrawal less any refund among the trailing deposits.
+//
+// Nil is returned if the events don't show the fee, e.g. for extrinsics that don't pay fees.
+func extrinsicFee(events []Event, signer []byte) *big.Int {
+	// TransactionFeePaid { who, actual_fee, tip } - the actual fee includes the tip.
+	for _, e := range findEvents(events, "TransactionPayment.TransactionFeePaid") {
+		if bytes.Equal(e.AccountField(0), signer) && e.BalanceField(1) != nil {
+			return new(big.Int).Set(e.BalanceField(1))
+		}
+	}
+
+	// Withdraw { who, amount }
+	var withdrawn *big.Int
+	for _, e := range findEvents(events, "Balances.Withdraw") {
+		if bytes.Equal(e.AccountField(0), signer) && e.BalanceField(1) != nil {
+			withdrawn = e.BalanceField(1)
+			break
+		}
+	}
+	if withdrawn == nil {
+		return nil
+	}
+
+	end := len(events)
+	for end > 0 && (events[end-1].Method() == "System.ExtrinsicSuccess" || events[end-1].Method() == "System.ExtrinsicFailed") {
+		end--
+	}
+	paid, refund := big.NewInt(0), big.NewInt(0)
+	for i := end - 1; i >= 0; i-- {
+		e := events[i]
+		var amount *big.Int
+		switch e.Method() {
+		case "Treasury.Deposit":
+			// Deposit { value }
+			amount = e.BalanceField(0)
+		case "Balances.Deposit":
+			// Deposit { who, amount }
+			amount = e.BalanceField(1)
+		}
+		if amount == nil {
+			break
+		}
+		total := new(big.Int).Add(paid, refund)
+		total.Add(total, amount)
+		if total.Cmp(withdrawn) > 0 {
+			break
+		}
+		if e.Method() == "Balances.Deposit" && bytes.Equal(e.AccountField(0), signer) {
+			refund.Add(refund, amount)
+		} else {
+			paid.Add(paid, amount)
+		}
+		if total.Cmp(withdrawn) == 0 {
+			return paid
+		}
+	}
+	return new(big.Int).Sub(withdrawn, refund)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBalance(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected *big.Int
+	}{
+		{"156000000", big.NewInt(156000000)},
+		{"0x9502f900", big.NewInt(2500000000)},
+		{"0x0", big.NewInt(0)},
+	}
+	for _, tc := range cases {
+		n, err := parseBalance(tc.in)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, tc.expected.Cmp(n))
+	}
+
+	_, err := parseBalance("1.5")
+	assert.Error(t, err)
+}
+
+func TestFeeDetailsJSON(t *testing.T) {
+	raw := `{"inclusionFee":{"baseFee":"0x9502f900","lenFee":15500000,"adjustedWeightFee":"125000000"}}`
+	details := feeDetails{}
+	assert.NoError(t, json.Unmarshal([]byte(raw), &details))
+	assert.NotNil(t, details.InclusionFee)
+	assert.Equal(t, "2500000000", details.InclusionFee.BaseFee.String())
+	assert.Equal(t, "15500000", details.InclusionFee.LenFee.String())
+	assert.Equal(t, "125000000", details.InclusionFee.AdjustedWeightFee.String())
+
+	details = feeDetails{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"inclusionFee":null}`), &details))
+	assert.Nil(t, details.InclusionFee)
+}
+
+func TestExtrinsicFee(t *testing.T) {
+	signer := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	author := bytes.Repeat([]byte{3}, 32)
+
+	// Newer runtimes report the actual fee, which includes the tip.
+	events := []Event{
+		testEvent(0, "Balances.Withdraw", signer, big.NewInt(200)),
+		testEvent(0, "Balances.Transfer", signer, other, big.NewInt(1000)),
+		testEvent(0, "TransactionPayment.TransactionFeePaid", signer, big.NewInt(140), big.NewInt(10)),
+		testEvent(0, "System.ExtrinsicSuccess"),
+	}
+	assert.Equal(t, big.NewInt(140), extrinsicFee(events, signer))
+
+	// Older runtimes refund unused weight and split the fee between the treasury and the block author.
+	events = []Event{
+		testEvent(0, "Balances.Withdraw", signer, big.NewInt(200)),
+		testEvent(0, "Balances.Deposit", other, big.NewInt(1000)),
+		testEvent(0, "Balances.Transfer", signer, other, big.NewInt(1000)),
+		testEvent(0, "Balances.Deposit", signer, big.NewInt(50)),
+		testEvent(0, "Balances.Deposit", author, big.NewInt(30)),
+		testEvent(0, "Treasury.Deposit", big.NewInt(120)),
+		testEvent(0, "System.ExtrinsicSuccess"),
+	}
+	assert.Equal(t, big.NewInt(150), extrinsicFee(events, signer))
+
+	// Without the deposits, the withdrawal less the refund.
+	events = []Event{
+		testEvent(0, "Balances.Withdraw", other, big.NewInt(5)),
+		testEvent(0, "Balances.Withdraw", signer, big.NewInt(200)),
+		testEvent(0, "Balances.Deposit", signer, big.NewInt(50)),
+		testEvent(0, "System.ExtrinsicFailed", DispatchError{Kind: "BadOrigin"}),
+	}
+	assert.Equal(t, big.NewInt(150), extrinsicFee(events, signer))
+
+	// A deposit made by the call itself is not part of the fee.
+	events = []Event{
+		testEvent(0, "Balances.Withdraw", signer, big.NewInt(200)),
+		testEvent(0, "Balances.Transfer", signer, other, big.NewInt(1000)),
+		testEvent(0, "Balances.Deposit", other, big.NewInt(1000)),
+		testEvent(0, "Balances.Deposit", signer, big.NewInt(50)),
+		testEvent(0, "Treasury.Deposit", big.NewInt(150)),
+		testEvent(0, "System.ExtrinsicSuccess"),
+	}
+	assert.Equal(t, big.NewInt(150), extrinsicFee(events, signer))
+
+	// Nor is it when the runtime deposits none of the fee.
+	events = []Event{
+		testEvent(0, "Balances.Withdraw", signer, big.NewInt(200)),
+		testEvent(0, "Balances.Deposit", other, big.NewInt(1000)),
+		testEvent(0, "System.ExtrinsicSuccess"),
+	}
+	assert.Equal(t, big.NewInt(200), extrinsicFee(events, signer))
+
+	assert.Nil(t, extrinsicFee(nil, signer))
+}
+
+func TestWeightJSON(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected Weight
+	}{
+		{`125000000`, Weight{RefTime: 125000000}},
+		{`{"ref_time":125000000,"proof_size":3593}`, Weight{RefTime: 125000000, ProofSize: 3593}},
+		{`{"refTime":125000000,"proofSize":3593}`, Weight{RefTime: 125000000, ProofSize: 3593}},
+	}
+	for _, tc := range cases {
+		var w Weight
+		assert.NoError(t, json.Unmarshal([]byte(tc.in), &w))
+		assert.Equal(t, tc.expected, w)
+	}
+
+	info := Fee{}
+	raw := `{"weight":{"ref_time":125000000,"proof_size":3593},"class":"normal","partialFee":"156000000"}`
+	assert.NoError(t, json.Unmarshal([]byte(raw), &info))
+	assert.Equal(t, Weight{RefTime: 125000000, ProofSize: 3593}, info.Weight)
+
+	var w Weight
+	assert.Error(t, json.Unmarshal([]byte(`{"proof_size":3593}`), &w))
+}
+
+func TestDecodeWeight(t *testing.T) {
+	// u64 125000000
+	w, err := decodeWeight(scale.NewDecoder(bytes.NewReader([]byte{0x40, 0x59, 0x73, 0x07, 0, 0, 0, 0})), false)
+	assert.NoError(t, err)
+	assert.Equal(t, Weight{RefTime: 125000000}, w)
+
+	// Compact 125000000, Compact 3593
+	w, err = decodeWeight(scale.NewDecoder(bytes.NewReader([]byte{0x02, 0x65, 0xcd, 0x1d, 0x25, 0x38})), true)
+	assert.NoError(t, err)
+	assert.Equal(t, Weight{RefTime: 125000000, ProofSize: 3593}, w)
+}
+
+type testRPCError struct{ code int }
+
+func (e testRPCError) Error() string  { return "rpc error" }
+func (e testRPCError) ErrorCode() int { return e.code }
+
+func TestIsMethodNotFound(t *testing.T) {
+	assert.True(t, isMethodNotFound(testRPCError{code: rpcMethodNotFound}))
+	assert.True(t, isMethodNotFound(fmt.Errorf("call failed: %w", testRPCError{code: rpcMethodNotFound})))
+	assert.True(t, isMethodNotFound(errors.New("Method not found")))
+	assert.False(t, isMethodNotFound(testRPCError{code: 1010}))
+	assert.False(t, isMethodNotFound(errors.New("connection refused")))
+	assert.False(t, isMethodNotFound(nil))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// ChainHeads is a snapshot of the best and finalized heads of the chain.
+type ChainHeads struct {
+	BestHash      types.Hash
+	Best          uint64
+	FinalizedHash types.Hash
+	Finalized     uint64
+}
+
+// FinalityLag is the number of blocks on the best chain that are not yet finalized.
+func (h ChainHeads) FinalityLag() uint64 {
+	if h.Best < h.Finalized {
+		return 0
+	}
+	return h.Best - h.Finalized
+}
+
+func (h ChainHeads) String() string {
+	return fmt.Sprintf("Best: %d (%#x)\nFinalized: %d (%#x)\nFinalityLag: %d\n",
+		h.Best,
+		h.BestHash,
+		h.Finalized,
+		h.FinalizedHash,
+		h.FinalityLag(),
+	)
+}
+
+// FinalizedHead returns the hash and number of the last finalized block (chain_getFinalizedHead).
+func (c *Connection) FinalizedHead() (types.Hash, uint64, error) {
+	hash, err := c.Api.RPC.Chain.GetFinalizedHead()
+	if err != nil {
+		return types.Hash{}, 0, fmt.Errorf("error getting finalized head: %w", err)
+	}
+	header, err := c.Api.RPC.Chain.GetHeader(hash)
+	if err != nil {
+		return types.Hash{}, 0, fmt.Errorf("error getting header of finalized head %#x: %w", hash, err)
+	}
+	return hash, uint64(header.Number), nil
+}
+
+// FinalizedHeight returns the number of the last finalized block. Compare ChainHeight, which returns the number
+// of the best block.
+func (c *Connection) FinalizedHeight() (uint64, error) {
+	_, number, err := c.FinalizedHead()
+	return number, err
+}
+
+// ChainHeads returns the best and finalized heads.
+func (c *Connection) ChainHeads() (*ChainHeads, error) {
+	best, err := c.Api.RPC.Chain.GetHeaderLatest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve latest header: %w", err)
+	}
+	bestHash, err := c.Api.RPC.Chain.GetBlockHash(uint64(best.Number))
+	if err != nil {
+		return nil, fmt.Errorf("error getting block hash at %d: %w", best.Number, err)
+	}
+	finalizedHash, finalized, err := c.FinalizedHead()
+	if err != nil {
+		return nil, err
+	}
+	return &ChainHeads{
+		BestHash:      bestHash,
+		Best:          uint64(best.Number),
+		FinalizedHash: finalizedHash,
+		Finalized:     finalized,
+	}, nil
+}
+
+// FinalityLag returns the number of blocks on the best chain that are not yet finalized.
+func (c *Connection) FinalityLag() (uint64, error) {
+	heads, err := c.ChainHeads()
+	if err != nil {
+		return 0, err
+	}
+	return heads.FinalityLag(), nil
+}
+
+// BlockStatus describes where a block stands relative to the best and finalized heads.
+type BlockStatus struct {
+	Hash   types.Hash
+	Number uint64
+	// Canonical is true if the block is on the best chain. A block that is not canonical has been, or may yet be,
+	// retracted by a reorg.
+	Canonical bool
+	// Finalized is true if the block is canonical and finalized, and so irreversible.
+	Finalized bool
+	// Confirmations is the number of blocks on the best chain, counting the block itself. Zero if the block is not
+	// canonical.
+	Confirmations uint64
+	// FinalizedConfirmations is the number of finalized blocks, counting the block itself. Zero if the block is
+	// not finalized.
+	FinalizedConfirmations uint64
+}
+
+func (s BlockStatus) String() string {
+	return fmt.Sprintf("Hash: %#x\nNumber: %d\nCanonical: %t\nFinalized: %t\nConfirmations: %d\nFinalizedConfirmations: %d\n",
+		s.Hash,
+		s.Number,
+		s.Canonical,
+		s.Finalized,
+		s.Confirmations,
+		s.FinalizedConfirmations,
+	)
+}
+
+// BlockStatus reports whether the block with the given hash is canonical and finalized, and its confirmations
+// relative to the best and finalized heads. Only credit deposits in blocks that are Finalized.
+func (c *Connection) BlockStatus(blockHash types.Hash) (*BlockStatus, error) {
+	heads, err := c.ChainHeads()
+	if err != nil {
+		return nil, err
+	}
+	return c.blockStatus(blockHash, heads)
+}
+
+// blockStatus is BlockStatus against heads that have already been fetched.
+func (c *Connection) blockStatus(blockHash types.Hash, heads *ChainHeads) (*BlockStatus, error) {
+	header, err := c.Api.RPC.Chain.GetHeader(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting header for block %#x: %w", blockHash, err)
+	}
+	number := uint64(header.Number)
+	var canonicalHash types.Hash
+	if number <= heads.Best {
+		canonicalHash, err = c.Api.RPC.Chain.GetBlockHash(number)
+		if err != nil {
+			return nil, fmt.Errorf("error getting block hash at %d: %w", number, err)
+		}
+	}
+	status := newBlockStatus(blockHash, number, canonicalHash, *heads)
+	return &status, nil
+}
+
+// IsFinalized reports whether the block with the given hash is canonical and finalized.
+func (c *Connection) IsFinalized(blockHash types.Hash) (bool, error) {
+	status, err := c.BlockStatus(blockHash)
+	if err != nil {
+		return false, err
+	}
+	return status.Finalized, nil
+}
+
+// newBlockStatus works out the status of a block given the hash of the block at the same height on the best
+// chain. Below the finalized head, the best chain is the finalized chain.
+func newBlockStatus(hash types.Hash, number uint64, canonicalHash types.Hash, heads ChainHeads) BlockStatus {
+	status := BlockStatus{Hash: hash, Number: number}
+	if number > heads.Best || canonicalHash != hash {
+		return status
+	}
+	status.Canonical = true
+	status.Confirmations = heads.Best - number + 1
+	if number <= heads.Finalized {
+		status.Finalized = true
+		status.FinalizedConfirmations = heads.Finalized - number + 1
+	}
+	return status
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFinalityLag(t *testing.T) {
+	assert.Equal(t, uint64(3), ChainHeads{Best: 103, Finalized: 100}.FinalityLag())
+	assert.Equal(t, uint64(0), ChainHeads{Best: 100, Finalized: 100}.FinalityLag())
+	// The best head can briefly trail the finalized head after a reorg.
+	assert.Equal(t, uint64(0), ChainHeads{Best: 99, Finalized: 100}.FinalityLag())
+}
+
+func TestNewBlockStatus(t *testing.T) {
+	heads := ChainHeads{Best: 105, Finalized: 102}
+	hash := types.NewHash([]byte{1})
+	other := types.NewHash([]byte{2})
+
+	status := newBlockStatus(hash, 100, hash, heads)
+	assert.True(t, status.Canonical)
+	assert.True(t, status.Finalized)
+	assert.Equal(t, uint64(6), status.Confirmations)
+	assert.Equal(t, uint64(3), status.FinalizedConfirmations)
+
+	status = newBlockStatus(hash, 102, hash, heads)
+	assert.True(t, status.Finalized)
+	assert.Equal(t, uint64(1), status.FinalizedConfirmations)
+
+	status = newBlockStatus(hash, 104, hash, heads)
+	assert.True(t, status.Canonical)
+	assert.False(t, status.Finalized)
+	assert.Equal(t, uint64(2), status.Confirmations)
+	assert.Zero(t, status.FinalizedConfirmations)
+
+	// A block replaced on the best chain, or beyond the best head, is neither canonical nor finalized.
+	for _, status := range []BlockStatus{
+		newBlockStatus(hash, 100, other, heads),
+		newBlockStatus(hash, 104, other, heads),
+		newBlockStatus(hash, 106, types.Hash{}, heads),
+	} {
+		assert.False(t, status.Canonical)
+		assert.False(t, status.Finalized)
+		assert.Zero(t, status.Confirmations)
+	}
+}
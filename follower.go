@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// followerBatch bounds the number of finalized blocks fetched by one walk back along parent hashes, so that
+// catching up after a long stop doesn't hold the whole gap in memory.
+const followerBatch = 1000
+
+// errNotDescendant is returned when a block is not descended from the follower's checkpoint.
+var errNotDescendant = errors.New("block does not descend from checkpoint")
+
+// BlockRef identifies a block by number and hash.
+type BlockRef struct {
+	Number uint64     `json:"number"`
+	Hash   types.Hash `json:"hash"`
+}
+
+func (b BlockRef) String() string {
+	return fmt.Sprintf("%d (%#x)", b.Number, b.Hash)
+}
+
+// FollowedBlock is a block delivered by a Follower.
+type FollowedBlock struct {
+	BlockRef
+	Block *types.SignedBlock
+	// Finalized is true if the block was finalized when it was delivered. Otherwise OnFinalized is called when it
+	// is finalized, or OnReorg when it is retracted.
+	Finalized bool
+}
+
+// Reorg reports a switch of the best chain from one branch to another.
+type Reorg struct {
+	// Ancestor is the last block common to both branches.
+	Ancestor BlockRef
+	// Retracted are the blocks of the old branch, and Enacted the blocks of the new branch, in ascending order.
+	// All retracted blocks were delivered to OnBlock, and all enacted blocks are delivered after OnReorg.
+	Retracted []BlockRef
+	Enacted   []BlockRef
+}
+
+func (r Reorg) String() string {
+	return fmt.Sprintf("Ancestor: %v\nRetracted: %v\nEnacted: %v\n", r.Ancestor, r.Retracted, r.Enacted)
+}
+
+type FollowerOptions struct {
+	// CheckpointFile is where the last finalized block processed is saved. Following resumes after it on restart.
+	// If empty, no checkpoint is kept.
+	CheckpointFile string
+	// Checkpoint is the number of the first block to process if there is no checkpoint file, as for
+	// GetStorageHistoryForID. Zero starts after the current finalized head.
+	Checkpoint uint64
+	// Unfinalized delivers blocks as soon as they are on the best chain, rather than once they are finalized.
+	Unfinalized bool
+}
+
+// headerSource is the part of the chain RPC used to walk parent hashes.
+type headerSource interface {
+	GetHeader(blockHash types.Hash) (*types.Header, error)
+}
+
+// Follower follows the chain, delivering every block once and in order. Gaps between heads are filled by walking
+// parent hashes, and the last finalized block processed is saved to the checkpoint file after it is delivered.
+// A block may be delivered again after a restart, so the callbacks should be idempotent.
+type Follower struct {
+	// OnBlock is called for each block. An error stops the follower, and the block is delivered again when it is
+	// restarted.
+	OnBlock func(FollowedBlock) error
+	// OnFinalized is called when a block delivered before it was finalized is finalized.
+	OnFinalized func(BlockRef) error
+	// OnReorg is called when unfinalized blocks that were delivered are retracted.
+	OnReorg func(Reorg) error
+
+	c        *Connection
+	opts     FollowerOptions
+	headers  headerSource
+	getBlock func(types.Hash) (*types.SignedBlock, error)
+
+	// last is the last finalized block processed - the checkpoint.
+	last BlockRef
+	// pending are the unfinalized blocks delivered after last, in ascending order.
+	pending []BlockRef
+	// finalized is the number of the latest finalized head seen.
+	finalized uint64
+}
+
+func (c *Connection) NewFollower(opts FollowerOptions) *Follower {
+	return &Follower{
+		c:        c,
+		opts:     opts,
+		headers:  c.Api.RPC.Chain,
+		getBlock: c.GetBlockByHash,
+	}
+}
+
+// Checkpoint returns the last finalized block processed.
+func (f *Follower) Checkpoint() BlockRef {
+	return f.last
+}
+
+// Run follows the chain until stop is closed or an error occurs.
+func (f *Follower) Run(stop <-chan struct{}) error {
+	finalizedHeads, err := f.c.Api.RPC.Chain.SubscribeFinalizedHeads()
+	if err != nil {
+		return fmt.Errorf("failure to subscribe to finalized heads: %w", err)
+	}
+	defer finalizedHeads.Unsubscribe()
+
+	var newHeadsChan <-chan types.Header
+	var newHeadsErr <-chan error
+	if f.opts.Unfinalized {
+		newHeads, err := f.c.Api.RPC.Chain.SubscribeNewHeads()
+		if err != nil {
+			return fmt.Errorf("failure to subscribe to new heads: %w", err)
+		}
+		defer newHeads.Unsubscribe()
+		newHeadsChan, newHeadsErr = newHeads.Chan(), newHeads.Err()
+	}
+
+	if err := f.start(); err != nil {
+		return err
+	}
+	// Catch up to the finalized head now, rather than waiting for the next one to be announced.
+	hash, number, err := f.c.FinalizedHead()
+	if err != nil {
+		return err
+	}
+	if err := f.handleFinalized(BlockRef{Number: number, Hash: hash}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case header := <-finalizedHeads.Chan():
+			head, err := headerRef(header)
+			if err != nil {
+				return err
+			}
+			if err := f.handleFinalized(head); err != nil {
+				return err
+			}
+		case header := <-newHeadsChan:
+			head, err := headerRef(header)
+			if err != nil {
+				return err
+			}
+			if err := f.handleNewHead(head); err != nil {
+				return err
+			}
+		case err := <-finalizedHeads.Err():
+			return fmt.Errorf("finalized heads subscription failed: %w", err)
+		case err := <-newHeadsErr:
+			return fmt.Errorf("new heads subscription failed: %w", err)
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// start sets the checkpoint from the checkpoint file, or else from the options.
+func (f *Follower) start() error {
+	last, ok, err := loadCheckpoint(f.opts.CheckpointFile)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ok:
+		f.last = last
+	case f.opts.Checkpoint > 0:
+		number := f.opts.Checkpoint - 1
+		hash, err := f.c.Api.RPC.Chain.GetBlockHash(number)
+		if err != nil {
+			return fmt.Errorf("error getting block hash at %d: %w", number, err)
+		}
+		f.last = BlockRef{Number: number, Hash: hash}
+	default:
+		hash, number, err := f.c.FinalizedHead()
+		if err != nil {
+			return err
+		}
+		f.last = BlockRef{Number: number, Hash: hash}
+	}
+
+	height, err := f.c.ChainHeight()
+	if err != nil {
+		return err
+	}
+	if height > f.last.Number {
+		fmt.Printf("following from block %d, %d blocks behind the best head\n", f.last.Number+1, height-f.last.Number)
+	}
+	return nil
+}
+
+// handleFinalized delivers the blocks up to a finalized head that haven't been delivered, and finalizes those
+// that have.
+func (f *Follower) handleFinalized(head BlockRef) error {
+	if head.Number > f.finalized {
+		f.finalized = head.Number
+	}
+	for f.last.Number < head.Number {
+		target := head
+		if head.Number-f.last.Number > followerBatch {
+			// Blocks below a finalized head are canonical, so can be looked up by number.
+			number := f.last.Number + followerBatch
+			hash, err := f.c.Api.RPC.Chain.GetBlockHash(number)
+			if err != nil {
+				return fmt.Errorf("error getting block hash at %d: %w", number, err)
+			}
+			target = BlockRef{Number: number, Hash: hash}
+		}
+
+		branch, _, err := f.walkBack(target, func(b BlockRef) bool { return b == f.last })
+		if err != nil {
+			return fmt.Errorf("finalized block %v: %w", target, err)
+		}
+
+		for i, b := range branch {
+			if len(f.pending) > 0 && f.pending[0] == b {
+				f.pending = f.pending[1:]
+				if f.OnFinalized != nil {
+					if err := f.OnFinalized(b); err != nil {
+						return err
+					}
+				}
+			} else {
+				// The delivered blocks that aren't on the finalized branch were on a fork that lost.
+				if len(f.pending) > 0 {
+					retracted := f.pending
+					f.pending = nil
+					if err := f.reorg(Reorg{Ancestor: f.last, Retracted: retracted, Enacted: branch[i:]}); err != nil {
+						return err
+					}
+				}
+				if err := f.deliver(b, true); err != nil {
+					return err
+				}
+			}
+			f.last = b
+			if err := saveCheckpoint(f.opts.CheckpointFile, b); err != nil {
+				return fmt.Errorf("error saving checkpoint: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleNewHead delivers the blocks of a new best head that haven't been delivered, reporting a reorg if the
+// head is not on the branch delivered so far.
+func (f *Follower) handleNewHead(head BlockRef) error {
+	if !f.opts.Unfinalized || head.Number <= f.last.Number {
+		return nil
+	}
+	if f.finalized > f.last.Number {
+		// Still catching up with finalized blocks, which will be delivered first.
+		return nil
+	}
+	if len(f.pending) > 0 && f.pending[len(f.pending)-1] == head {
+		return nil
+	}
+
+	index := make(map[types.Hash]int, len(f.pending))
+	for i, b := range f.pending {
+		index[b.Hash] = i
+	}
+	branch, ancestor, err := f.walkBack(head, func(b BlockRef) bool {
+		_, ok := index[b.Hash]
+		return ok || b == f.last
+	})
+	if errors.Is(err, errNotDescendant) {
+		fmt.Printf("ignoring new head %v: %v\n", head, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("new head %v: %w", head, err)
+	}
+
+	keep := 0
+	if i, ok := index[ancestor.Hash]; ok {
+		keep = i + 1
+	}
+	if keep < len(f.pending) {
+		retracted := append([]BlockRef{}, f.pending[keep:]...)
+		f.pending = f.pending[:keep]
+		if err := f.reorg(Reorg{Ancestor: ancestor, Retracted: retracted, Enacted: branch}); err != nil {
+			return err
+		}
+	}
+	for _, b := range branch {
+		if err := f.deliver(b, false); err != nil {
+			return err
+		}
+		f.pending = append(f.pending, b)
+	}
+	return nil
+}
+
+// walkBack walks parent hashes from tip until it reaches a known block, returning the blocks after the known
+// block in ascending order, and the known block.
+func (f *Follower) walkBack(tip BlockRef, known func(BlockRef) bool) (branch []BlockRef, ancestor BlockRef, err error) {
+	current := tip
+	for !known(current) {
+		if current.Number <= f.last.Number {
+			return nil, BlockRef{}, fmt.Errorf("%w %v", errNotDescendant, f.last)
+		}
+		branch = append(branch, current)
+		header, err := f.headers.GetHeader(current.Hash)
+		if err != nil {
+			return nil, BlockRef{}, fmt.Errorf("error getting header for block %#x: %w", current.Hash, err)
+		}
+		current = BlockRef{Number: current.Number - 1, Hash: header.ParentHash}
+	}
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+	return branch, current, nil
+}
+
+func (f *Follower) deliver(b BlockRef, finalized bool) error {
+	block, err := f.getBlock(b.Hash)
+	if err != nil {
+		return fmt.Errorf("error getting block for hash %#x: %w", b.Hash, err)
+	}
+	if f.OnBlock == nil {
+		return nil
+	}
+	return f.OnBlock(FollowedBlock{BlockRef: b, Block: block, Finalized: finalized})
+}
+
+func (f *Follower) reorg(r Reorg) error {
+	fmt.Printf("reorg at block %v: %d blocks retracted, %d enacted\n", r.Ancestor, len(r.Retracted), len(r.Enacted))
+	if f.OnReorg == nil {
+		return nil
+	}
+	return f.OnReorg(r)
+}
+
+// headerRef returns the number and hash of a header.
+func headerRef(header types.Header) (BlockRef, error) {
+	hash, err := types.GetHash(header)
+	if err != nil {
+		return BlockRef{}, fmt.Errorf("error hashing header %d: %w", header.Number, err)
+	}
+	return BlockRef{Number: uint64(header.Number), Hash: hash}, nil
+}
+
+// loadCheckpoint reads the checkpoint file. ok is false if there is no checkpoint.
+func loadCheckpoint(path string) (checkpoint BlockRef, ok bool, err error) {
+	if path == "" {
+		return BlockRef{}, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return BlockRef{}, false, nil
+	}
+	if err != nil {
+		return BlockRef{}, false, err
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return BlockRef{}, false, fmt.Errorf("invalid checkpoint in %s: %w", path, err)
+	}
+	return checkpoint, true, nil
+}
+
+func saveCheckpoint(path string, checkpoint BlockRef) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testChain is a headerSource for a chain with forks, built by adding blocks on top of their parents.
+type testChain map[types.Hash]types.Header
+
+func (tc testChain) add(parent BlockRef, name string) BlockRef {
+	b := BlockRef{Number: parent.Number + 1, Hash: types.NewHash([]byte(name))}
+	tc[b.Hash] = types.Header{ParentHash: parent.Hash, Number: types.BlockNumber(b.Number)}
+	return b
+}
+
+func (tc testChain) GetHeader(hash types.Hash) (*types.Header, error) {
+	header, ok := tc[hash]
+	if !ok {
+		return nil, fmt.Errorf("unknown block %#x", hash)
+	}
+	return &header, nil
+}
+
+// testFollower returns a follower of chain that records the callbacks it receives.
+func testFollower(chain testChain, start BlockRef, opts FollowerOptions) (*Follower, *[]string) {
+	calls := []string{}
+	f := &Follower{
+		opts:     opts,
+		headers:  chain,
+		getBlock: func(types.Hash) (*types.SignedBlock, error) { return &types.SignedBlock{}, nil },
+		last:     start,
+	}
+	f.OnBlock = func(b FollowedBlock) error {
+		calls = append(calls, fmt.Sprintf("block %d finalized=%t", b.Number, b.Finalized))
+		return nil
+	}
+	f.OnFinalized = func(b BlockRef) error {
+		calls = append(calls, fmt.Sprintf("finalized %d", b.Number))
+		return nil
+	}
+	f.OnReorg = func(r Reorg) error {
+		calls = append(calls, fmt.Sprintf("reorg at %d: -%d +%d", r.Ancestor.Number, len(r.Retracted), len(r.Enacted)))
+		return nil
+	}
+	return f, &calls
+}
+
+func TestFollowerFinalized(t *testing.T) {
+	chain := testChain{}
+	genesis := BlockRef{Number: 10, Hash: types.NewHash([]byte("a10"))}
+	a11 := chain.add(genesis, "a11")
+	a12 := chain.add(a11, "a12")
+	a13 := chain.add(a12, "a13")
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	f, calls := testFollower(chain, genesis, FollowerOptions{CheckpointFile: path})
+	// New heads are ignored unless following unfinalized blocks.
+	assert.NoError(t, f.handleNewHead(a13))
+	// The gap up to the finalized head is filled by walking parent hashes.
+	assert.NoError(t, f.handleFinalized(a12))
+	assert.NoError(t, f.handleFinalized(a12))
+	assert.NoError(t, f.handleFinalized(a13))
+	assert.Equal(t, []string{
+		"block 11 finalized=true",
+		"block 12 finalized=true",
+		"block 13 finalized=true",
+	}, *calls)
+
+	checkpoint, ok, err := loadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, a13, checkpoint)
+	assert.Equal(t, a13, f.Checkpoint())
+
+	// A finalized head that doesn't descend from the checkpoint is an error.
+	b11 := chain.add(genesis, "b11")
+	b12 := chain.add(b11, "b12")
+	b13 := chain.add(b12, "b13")
+	b14 := chain.add(b13, "b14")
+	assert.ErrorIs(t, f.handleFinalized(b14), errNotDescendant)
+}
+
+func TestFollowerReorg(t *testing.T) {
+	chain := testChain{}
+	start := BlockRef{Number: 10, Hash: types.NewHash([]byte("a10"))}
+	a11 := chain.add(start, "a11")
+	a12 := chain.add(a11, "a12")
+	a13 := chain.add(a12, "a13")
+	b12 := chain.add(a11, "b12")
+	b13 := chain.add(b12, "b13")
+	b14 := chain.add(b13, "b14")
+
+	f, calls := testFollower(chain, start, FollowerOptions{Unfinalized: true})
+	assert.NoError(t, f.handleNewHead(a11))
+	assert.NoError(t, f.handleNewHead(a13))
+	assert.NoError(t, f.handleNewHead(b14))
+	assert.NoError(t, f.handleFinalized(b12))
+	// A stale head on the retracted branch is ignored.
+	assert.NoError(t, f.handleNewHead(a13))
+	assert.NoError(t, f.handleFinalized(b14))
+	assert.Equal(t, []string{
+		"block 11 finalized=false",
+		"block 12 finalized=false",
+		"block 13 finalized=false",
+		"reorg at 11: -2 +3",
+		"block 12 finalized=false",
+		"block 13 finalized=false",
+		"block 14 finalized=false",
+		"finalized 11",
+		"finalized 12",
+		"finalized 13",
+		"finalized 14",
+	}, *calls)
+	assert.Empty(t, f.pending)
+	assert.Equal(t, b14, f.Checkpoint())
+}
+
+func TestFollowerReorgOnFinalization(t *testing.T) {
+	chain := testChain{}
+	start := BlockRef{Number: 10, Hash: types.NewHash([]byte("a10"))}
+	a11 := chain.add(start, "a11")
+	a12 := chain.add(a11, "a12")
+	b12 := chain.add(a11, "b12")
+
+	// The best head can be finalized on another branch before the new best head is announced.
+	f, calls := testFollower(chain, start, FollowerOptions{Unfinalized: true})
+	assert.NoError(t, f.handleNewHead(a12))
+	assert.NoError(t, f.handleFinalized(b12))
+	assert.Equal(t, []string{
+		"block 11 finalized=false",
+		"block 12 finalized=false",
+		"finalized 11",
+		"reorg at 11: -1 +1",
+		"block 12 finalized=true",
+	}, *calls)
+	assert.Empty(t, f.pending)
+}
+
+func TestCheckpointFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	_, ok, err := loadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	checkpoint := BlockRef{Number: 42, Hash: types.NewHash([]byte{1, 2, 3})}
+	assert.NoError(t, saveCheckpoint(path, checkpoint))
+	loaded, ok, err := loadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, checkpoint, loaded)
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
-	"strconv"
 	"time"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
@@ -51,28 +50,17 @@ func (c *Connection) getExtrinsic(blockHashStr string, index uint8) error {
 		return fmt.Errorf("error getting meta data latest: %w", err)
 	}
 
-	key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
+	events, err := c.getBlockEvents(blockHash, meta)
 	if err != nil {
-		return fmt.Errorf("error creating storage key: %w", err)
-	}
-
-	raw, err := c.Api.RPC.State.GetStorageRaw(key, blockHash)
-	if err != nil {
-		return fmt.Errorf("error retrieving raw storage data for key %v: %w", key, err)
-	}
-
-	events := types.EventRecords{}
-	err = types.EventRecordsRaw(*raw).DecodeEventRecords(meta, &events)
-	if err != nil {
-		return fmt.Errorf("error decoding event records for %v: %w", *raw, err)
+		return err
 	}
 
-	for _, event := range events.Balances_Transfer {
-		send, _ := subkey.SS58Address(event.From[:], 0) // 0 is the network identifier byte
-		to, _ := subkey.SS58Address(event.To[:], 0)
+	for _, event := range findEvents(events, "Balances.Transfer") {
+		send, _ := subkey.SS58Address(event.AccountField(0), 0) // 0 is the network identifier byte
+		to, _ := subkey.SS58Address(event.AccountField(1), 0)
 		fmt.Printf("from: %+v\n", send)
 		fmt.Printf("to: %+v\n", to)
-		fmt.Printf("value : %+v\n", event.Value)
+		fmt.Printf("value : %+v\n", event.BalanceField(2))
 		fmt.Printf("phase : %+v\n", event.Phase)
 		fmt.Printf("topics : %+v\n", event.Topics)
 
@@ -102,7 +90,7 @@ func (c *Connection) getExtrinsic(blockHashStr string, index uint8) error {
 }
 
 type Fee struct {
-	Weight     types.Weight
+	Weight     Weight
 	Class      string
 	PartialFee string
 }
@@ -198,232 +186,237 @@ func (c *Connection) GetTxEvents(blockHashBytes []byte, accountID string) error
 
 func (c *Connection) BuildTxEventFromBlock(block *types.SignedBlock, blockHash types.Hash, receiverAddress string) ([]*TxEvent, error) {
 
-	receiverPubKey, err := PublicKeyFromAddress(receiverAddress)
-	if err != nil {
+	watched := NewWatchSet()
+	if err := watched.Add(receiverAddress); err != nil {
 		return nil, err
 	}
 
-	meta, err := c.getMetadata(blockHash)
+	txEvents, err := c.blockTxEvents(block, blockHash, watched)
 	if err != nil {
 		return nil, err
 	}
+	if len(txEvents) == 0 {
+		return txEvents, nil
+	}
 
-	// TODO: Build once on initialisation of the Connection and store as a field.
-	allowedCalls, err := c.BuildAllowedCallIndexes(allowedCalls, meta)
+	heads, err := c.ChainHeads()
 	if err != nil {
-		return nil, fmt.Errorf("error getting callIndex: %w", err)
+		return nil, err
 	}
-
-	txEvents := []*TxEvent{}
-	for i, extrinsic := range block.Block.Extrinsics {
-
-		// Only allowed calls - those relating to balance transfer.
-		if _, ok := allowedCalls[extrinsic.Method.CallIndex]; !ok {
-			continue
-		}
-
-		// This must take place before the guard since we need to know the recipient ID
-		decodedArgs, err := DecodeExtrinsicArgs(&extrinsic)
-		if err != nil {
-			return nil, fmt.Errorf("error decoding Extrinsic arguments for Extrinsic %d in block %s: %w", i, blockHash, err)
-		}
-
-		if !bytes.Equal(decodedArgs.ReceiverPubKey, receiverPubKey) {
-			continue
-		}
-
-		txEvent := new(TxEvent)
-
-		timestamp, err := c.GetBlockTimestamp(block, blockHash)
-		if err != nil {
-			return nil, err
-		}
-
-		receivingPubkey := hex.EncodeToString(decodedArgs.ReceiverPubKey)
-		sendingPubkey := hex.EncodeToString(extrinsic.Signature.Signer.AsID[:])
-		currentHeight, err := c.ChainHeight()
-		if err != nil {
-			return nil, err
+	status, err := c.blockStatus(blockHash, heads)
+	if err != nil {
+		return nil, err
+	}
+	for _, txEvent := range txEvents {
+		// Blocks built on top of this one on the best and the finalized chain. A block that has been
+		// retracted has no confirmations.
+		if status.Canonical {
+			txEvent.Confirmations = status.Confirmations - 1
 		}
-
-		txEvent.BlockHash = hex.EncodeToString(blockHash[:])
-		txEvent.TimeStamp = *timestamp
-		txEvent.Hash = hex.EncodeToString(decodedArgs.TxHash)
-		txEvent.Value = decodedArgs.Amount.Int64()
-		txEvent.From = sendingPubkey
-		txEvent.To = receivingPubkey
-		txEvent.TransactionIndex = i
-		txEvent.BlockHeight = uint64(block.Block.Header.Number)
-		txEvent.Confirmations = currentHeight - txEvent.BlockHeight
-
-		txEvents = append(txEvents, txEvent)
-		err = c.GetFeePaid(blockHash, meta)
-		if err != nil {
-			return nil, fmt.Errorf("error getting event: %w", err)
+		if status.Finalized {
+			txEvent.FinalizedConfirmations = status.FinalizedConfirmations - 1
 		}
-
+		txEvent.Finalized = status.Finalized
 	}
 
 	return txEvents, nil
 }
 
-func (c *Connection) GetFeePaid(blockHash types.Hash, meta *types.Metadata) error {
-	key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
-	if err != nil {
-		return err
-	}
-
-	events := EventRecords{}
-	raw, err := c.Api.RPC.State.GetStorageRaw(key, blockHash)
+// blockTxEvents builds the TxEvents for transfers to any of the watched accounts in a block, fetching the
+// metadata, events and timestamp of the block once. The confirmation fields are left for the caller to set.
+func (c *Connection) blockTxEvents(block *types.SignedBlock, blockHash types.Hash, watched *WatchSet) ([]*TxEvent, error) {
+	meta, err := c.getMetadata(blockHash)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = types.EventRecordsRaw(*raw).DecodeEventRecords(meta, &events)
+	events, err := c.getBlockEvents(blockHash, meta)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Get the block
-	block, err := c.Api.RPC.Chain.GetBlock(blockHash)
+	timestamp, err := c.GetBlockTimestamp(block, blockHash)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, event := range events.Balances_Transfer {
-		ext := block.Block.Extrinsics[int(event.Phase.AsApplyExtrinsic)]
-		fmt.Printf("ext : %+v\n", ext)
-
-		fmt.Printf("nonce : %+v\n", ext.Signature.Nonce)
+	txEvents := []*TxEvent{}
+	for i, extrinsic := range block.Block.Extrinsics {
 
-		fmt.Printf("tip : %+v\n", ext.Signature.Tip)
+		// Only signed extrinsics can transfer balances - this skips the timestamp and other inherents.
+		if !extrinsic.IsSigned() {
+			continue
+		}
 
-		extBytes, err := types.EncodeToHexString(ext)
+		// Transfers may be nested in batch, proxy, multisig and sudo calls, so the whole call tree is searched.
+		// The call is only needed to locate transfers in the call tree and to report failed ones - successful
+		// transfers are taken from the events.
+		transfers, err := ExtractTransfers(meta, &extrinsic)
 		if err != nil {
-			return err
+			fmt.Printf("unable to decode call of Extrinsic %d in block %s: %v\n", i, blockHash, err)
 		}
 
-		fmt.Println(extBytes)
-		resInter := Fee{}
-		err = c.Api.Client.Call(&resInter, "payment_queryInfo", ext, blockHash.Hex())
+		extrinsicEvents := eventsForExtrinsic(events, uint32(i))
+		found := transferTxEvents(extrinsicEvents, transfers, watched)
+		if len(found) == 0 {
+			continue
+		}
+
+		hash, err := types.GetHash(extrinsic)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("problem getting extrinsic hash: %w", err)
+		}
+		var fee *big.Int
+		if signer, err := signerAccount(extrinsic.Signature.Signer); err == nil && extrinsic.IsSigned() {
+			fee = extrinsicFee(extrinsicEvents, signer)
 		}
 
-		fmt.Println("++++PartialFee: ", resInter.PartialFee)
+		for _, txEvent := range found {
+			txEvent.BlockHash = hex.EncodeToString(blockHash[:])
+			txEvent.TimeStamp = *timestamp
+			txEvent.Hash = hex.EncodeToString(hash[:])
+			txEvent.TransactionIndex = i
+			txEvent.BlockHeight = uint64(block.Block.Header.Number)
+			txEvent.Fee = fee
+			txEvents = append(txEvents, txEvent)
+		}
 	}
 
-	return nil
+	return txEvents, nil
 }
 
-// GetData gets data for a watched address in a given Block
-func (c *Connection) GetData(blockHash types.Hash, receiverAddress string) error {
-	receiverPubKey, err := PublicKeyFromAddress(receiverAddress)
-	if err != nil {
-		return fmt.Errorf("error decoding receiver public key from %s: %w", receiverAddress, err)
+// transferTxEvents builds the TxEvents for transfers to watched accounts made by an extrinsic, from the events of
+// the extrinsic. A transfer is only credited if it emitted Balances.Transfer - the events of a failed call are
+// reverted, so a transfer that failed, even within a batch that succeeded, emits none. Transfers to watched
+// accounts in the call of a failed extrinsic are reported with Success false. The transfers found in the call are
+// used to locate the transfers in the call tree.
+func transferTxEvents(events []Event, transfers []CallTransfer, watched *WatchSet) []*TxEvent {
+	var result *Event
+	for i, e := range events {
+		if e.Method() == "System.ExtrinsicSuccess" || e.Method() == "System.ExtrinsicFailed" {
+			result = &events[i]
+		}
 	}
-
-	meta, err := c.getMetadata(blockHash)
-	if err != nil {
-		return fmt.Errorf("error getting metadata for block %#x: %w", blockHash, err)
+	if result == nil {
+		return nil
 	}
 
-	key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
-	if err != nil {
-		return fmt.Errorf("error creating storage key: %w", err)
+	txEvents := []*TxEvent{}
+	if result.Method() == "System.ExtrinsicFailed" {
+		for _, transfer := range transfers {
+			if !watched.Contains(transfer.To) {
+				continue
+			}
+			txEvent := &TxEvent{
+				From:         hex.EncodeToString(transfer.From),
+				To:           hex.EncodeToString(transfer.To),
+				Path:         transfer.Path,
+				Success:      false,
+				Error:        eventError(*result).Error(),
+				EventIndices: []uint32{result.Index},
+			}
+			if transfer.Amount != nil {
+				txEvent.Value = new(big.Int).Set(transfer.Amount)
+			}
+			txEvents = append(txEvents, txEvent)
+		}
+		return txEvents
 	}
 
-	events := EventRecords{}
-	raw, err := c.Api.RPC.State.GetStorageRaw(key, blockHash)
-	if err != nil {
-		return fmt.Errorf("error getting raw storage for events in %#x: %w", blockHash, err)
+	unmatched := append([]CallTransfer{}, transfers...)
+	// Transfer { from, to, amount }
+	for _, e := range findEvents(events, "Balances.Transfer") {
+		if !watched.Contains(e.AccountField(1)) || e.BalanceField(2) == nil {
+			continue
+		}
+		txEvent := &TxEvent{
+			From:         hex.EncodeToString(e.AccountField(0)),
+			To:           hex.EncodeToString(e.AccountField(1)),
+			Value:        new(big.Int).Set(e.BalanceField(2)),
+			Success:      true,
+			EventIndices: []uint32{e.Index, result.Index},
+		}
+		// The call tree location of the first transfer in the call with the same recipient and amount. A
+		// transfer_all has no amount in the call, so it matches any amount.
+		for k, transfer := range unmatched {
+			if bytes.Equal(transfer.To, e.AccountField(1)) && (transfer.Amount == nil || transfer.Amount.Cmp(e.BalanceField(2)) == 0) {
+				txEvent.Path = transfer.Path
+				unmatched = append(unmatched[:k], unmatched[k+1:]...)
+				break
+			}
+		}
+		txEvents = append(txEvents, txEvent)
 	}
+	return txEvents
+}
 
-	err = types.EventRecordsRaw(*raw).DecodeEventRecords(meta, &events)
+// GetFeePaid returns the fee, including the tip, paid by each signed extrinsic in the block, by extrinsic index.
+// The fees are taken from the events the chain emitted, so they are exact and don't need historical state.
+func (c *Connection) GetFeePaid(blockHash types.Hash, meta *types.Metadata) (map[uint32]*big.Int, error) {
+	events, err := c.getBlockEvents(blockHash, meta)
 	if err != nil {
-		return fmt.Errorf("error decoding events in block %#x: %w", blockHash, err)
+		return nil, err
 	}
 
 	// Get the block
 	block, err := c.Api.RPC.Chain.GetBlock(blockHash)
 	if err != nil {
-		return fmt.Errorf("error getting block for hash %#x: %w", blockHash, err)
+		return nil, err
 	}
 
-	txEvents := []*TxEvent{}
-	for _, event := range events.Balances_Transfer {
-		if !bytes.Equal(event.To[:], receiverPubKey) {
+	fees := map[uint32]*big.Int{}
+	for i, extrinsic := range block.Block.Extrinsics {
+		if !extrinsic.IsSigned() {
 			continue
 		}
-
-		index := int(event.Phase.AsApplyExtrinsic)
-		extrinsic := block.Block.Extrinsics[index]
-
-		resInter := Fee{}
-		err = c.Api.Client.Call(&resInter, "payment_queryInfo", extrinsic, blockHash.Hex())
-		if err != nil {
-			return fmt.Errorf("error decoding fee: %w", err)
-		}
-
-		fmt.Println("PartialFee: ", resInter.PartialFee)
-
-		partialFee, err := strconv.ParseInt(resInter.PartialFee, 10, 64)
-		if err != nil {
-			return fmt.Errorf("error parsing partial fee %s: %w", resInter.PartialFee, err)
-		}
-
-		decodedArgs, err := DecodeExtrinsicArgs(&extrinsic)
+		signer, err := signerAccount(extrinsic.Signature.Signer)
 		if err != nil {
-			return fmt.Errorf("error decoding Extrinsic arguments for Extrinsic %d in block %s: %w", index, blockHash, err)
-		}
-
-		if !bytes.Equal(decodedArgs.ReceiverPubKey, receiverPubKey) {
 			continue
 		}
-
-		txEvent := new(TxEvent)
-
-		timestamp, err := c.GetBlockTimestamp(block, blockHash)
-		if err != nil {
-			return fmt.Errorf("error gettilng block timestamp for block %#x: %w", blockHash, err)
+		fee := extrinsicFee(eventsForExtrinsic(events, uint32(i)), signer)
+		if fee != nil {
+			fees[uint32(i)] = fee
 		}
+	}
+	return fees, nil
+}
 
-		receivingPubkey := hex.EncodeToString(decodedArgs.ReceiverPubKey)
-		sendingPubkey := hex.EncodeToString(extrinsic.Signature.Signer.AsID[:])
-		currentHeight, err := c.ChainHeight()
-		if err != nil {
-			return fmt.Errorf("error getting current height: %v", err)
-		}
+// GetData gets data for a watched address in a given Block
+func (c *Connection) GetData(blockHash types.Hash, receiverAddress string) error {
+	// Get the block
+	block, err := c.Api.RPC.Chain.GetBlock(blockHash)
+	if err != nil {
+		return fmt.Errorf("error getting block for hash %#x: %w", blockHash, err)
+	}
 
-		txEvent.BlockHash = hex.EncodeToString(blockHash[:])
-		txEvent.TimeStamp = *timestamp
-		txEvent.Hash = hex.EncodeToString(decodedArgs.TxHash)
-		txEvent.Value = decodedArgs.Amount.Int64()
-		txEvent.From = sendingPubkey
-		txEvent.To = receivingPubkey
-		txEvent.TransactionIndex = index
-		txEvent.BlockHeight = uint64(block.Block.Header.Number)
-		txEvent.Confirmations = currentHeight - txEvent.BlockHeight
-		txEvent.Fee = partialFee + extrinsic.Signature.Tip.Int64()
+	txEvents, err := c.BuildTxEventFromBlock(block, blockHash, receiverAddress)
+	if err != nil {
+		return fmt.Errorf("error building transactions for block %#x: %w", blockHash, err)
+	}
+	for _, txEvent := range txEvents {
 		fmt.Println(txEvent)
-
-		txEvents = append(txEvents, txEvent)
 	}
 
 	return nil
 }
 
 type TxEvent struct {
-	BlockHash        string    `json:"block_hash"` // Hash of the  L1 block which includes this transaction
-	TimeStamp        time.Time `json:"timeStamp"`
-	Hash             string    `json:"hash"`                     // Hash of the current Extrinsic
-	From             string    `json:"from"`                     // Hexstring of PubKey
-	To               string    `json:"to"`                       // Hexstring of PubKey
-	TransactionIndex int       `json:"transaction_index,string"` // Index of the Extrinsic in the L1 block
-	BlockHeight      uint64    `json:"block_height,string"`
-	Confirmations    uint64    `json:"confirmations,string"`
-	Value            int64     `json:"value,string"` // TODO: Should this be big.Int?
-	Fee              int64     `json:"fee"`
+	BlockHash              string    `json:"block_hash"` // Hash of the  L1 block which includes this transaction
+	TimeStamp              time.Time `json:"timeStamp"`
+	Hash                   string    `json:"hash"`                     // Hash of the current Extrinsic
+	From                   string    `json:"from"`                     // Hexstring of PubKey
+	To                     string    `json:"to"`                       // Hexstring of PubKey
+	TransactionIndex       int       `json:"transaction_index,string"` // Index of the Extrinsic in the L1 block
+	BlockHeight            uint64    `json:"block_height,string"`
+	Confirmations          uint64    `json:"confirmations,string"`           // Blocks on top of this one on the best chain
+	FinalizedConfirmations uint64    `json:"finalized_confirmations,string"` // Blocks on top of this one on the finalized chain
+	Finalized              bool      `json:"finalized"`                      // True once the block is canonical and finalized - only then credit the transfer
+	Value                  *big.Int  `json:"value"`                          // Amount transferred, nil for a failed transfer_all
+	Fee                    *big.Int  `json:"fee"`                            // Fee paid by the signer, including the tip, from the events of the Extrinsic
+	Success                bool      `json:"success"`                        // False if the Extrinsic failed and nothing was transferred
+	Error                  string    `json:"error,omitempty"`                // Dispatch error if the Extrinsic failed, e.g. Balances.InsufficientBalance
+	Path                   string    `json:"path,omitempty"`                 // Location of the transfer in the call tree, e.g. Utility.batch_all/calls[1]/Balances.transfer
+	EventIndices           []uint32  `json:"event_indices"`                  // Indices in the block's events of the Balances.Transfer event, if any, and the ExtrinsicSuccess or ExtrinsicFailed event
 }
 
 func (tx TxEvent) String() string {
@@ -437,7 +430,13 @@ func (tx TxEvent) String() string {
 			"TransactionIndex: %d\n" +
 			"BlockHeight: %d\n" +
 			"Confirmations: %d\n" +
-			"Fee: %d\n"
+			"FinalizedConfirmations: %d\n" +
+			"Finalized: %t\n" +
+			"Fee: %d\n" +
+			"Success: %t\n" +
+			"Error: %s\n" +
+			"Path: %s\n" +
+			"EventIndices: %v\n"
 
 	return fmt.Sprintf(formatString,
 		tx.BlockHash,
@@ -449,7 +448,13 @@ func (tx TxEvent) String() string {
 		tx.TransactionIndex,
 		tx.BlockHeight,
 		tx.Confirmations,
+		tx.FinalizedConfirmations,
+		tx.Finalized,
 		tx.Fee,
+		tx.Success,
+		tx.Error,
+		tx.Path,
+		tx.EventIndices,
 	)
 }
 
@@ -465,6 +470,8 @@ func (c *Connection) BuildAllowedCallIndexes(allowedCalls map[string]bool, meta
 	return callIndexes, nil
 }
 
+// DecodeExtrinsicArgs decodes the arguments of a top-level Balances.transfer or Balances.transfer_keep_alive. Use
+// ExtractTransfers to find transfers nested in batch, proxy, multisig and sudo calls.
 func DecodeExtrinsicArgs(extrinsic *types.Extrinsic) (*ExtrinsicArgs, error) {
 	hash, err := types.GetHash(extrinsic)
 	if err != nil {
@@ -472,19 +479,16 @@ func DecodeExtrinsicArgs(extrinsic *types.Extrinsic) (*ExtrinsicArgs, error) {
 	}
 	txHash := hash[:]
 
+	// The arguments of Balances.transfer and Balances.transfer_keep_alive: dest MultiAddress, value Compact<u128>.
 	argsDecoder := scale.NewDecoder(bytes.NewReader(extrinsic.Method.Args))
-	nCalls, err := argsDecoder.DecodeUintCompact()
+	dest := types.MultiAddress{}
+	err = argsDecoder.Decode(&dest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode call count for extrinsic %#x: %w", txHash, err)
+		return nil, fmt.Errorf("problem decoding dest for extrinsic %#x: %w", txHash, err)
 	}
-
-	accountID := types.AccountID{}
-	//	multiAddress := types.MultiAddress{}
-	err = argsDecoder.Decode(&accountID)
-	if err != nil {
-		return nil, fmt.Errorf("problem accountID for extrinsic %#x: %w", txHash, err)
+	if !dest.IsID {
+		return nil, fmt.Errorf("unsupported dest address type for extrinsic %#x", txHash)
 	}
-	//	accountID := multiAddress.AsID
 
 	amount, err := argsDecoder.DecodeUintCompact()
 	if err != nil {
@@ -493,240 +497,36 @@ func DecodeExtrinsicArgs(extrinsic *types.Extrinsic) (*ExtrinsicArgs, error) {
 
 	return &ExtrinsicArgs{
 		Amount:         *amount,
-		NCalls:         *nCalls,
-		ReceiverPubKey: []byte(accountID[:]),
+		ReceiverPubKey: []byte(dest.AsID[:]),
 		TxHash:         txHash,
 	}, nil
 }
 
 type ExtrinsicArgs struct {
-	NCalls         big.Int
 	Amount         big.Int
 	ReceiverPubKey []byte
 	TxHash         []byte
 }
 
 func (ext ExtrinsicArgs) String() string {
-	return fmt.Sprintf("NCalls: %d\nAmount: %d\nReceiver PubKey: %#x\nTxHash: %#x\n",
-		ext.NCalls.Int64(),
+	return fmt.Sprintf("Amount: %d\nReceiver PubKey: %#x\nTxHash: %#x\n",
 		ext.Amount.Int64(),
 		ext.ReceiverPubKey,
 		ext.TxHash,
 	)
 }
 
-// EventRecords is a default set of possible event records that can be used as a target for
-// `func (e EventRecordsRaw) Decode(...`
-type EventRecords struct {
-	Claims_Claimed                     []types.EventClaimsClaimed                     //nolint:stylecheck,golint
-	Balances_Endowed                   []types.EventBalancesEndowed                   //nolint:stylecheck,golint
-	Balances_DustLost                  []types.EventBalancesDustLost                  //nolint:stylecheck,golint
-	Balances_Transfer                  []types.EventBalancesTransfer                  //nolint:stylecheck,golint
-	Balances_BalanceSet                []types.EventBalancesBalanceSet                //nolint:stylecheck,golint
-	Balances_Deposit                   []types.EventBalancesDeposit                   //nolint:stylecheck,golint
-	Balances_Reserved                  []types.EventBalancesReserved                  //nolint:stylecheck,golint
-	Balances_Unreserved                []types.EventBalancesUnreserved                //nolint:stylecheck,golint
-	Balances_ReservedRepatriated       []types.EventBalancesReserveRepatriated        //nolint:stylecheck,golint
-	Balances_Withdraw                  []EventBalancesWithdraw                        //nolint:stylecheck,golint
-	Grandpa_NewAuthorities             []types.EventGrandpaNewAuthorities             //nolint:stylecheck,golint
-	Grandpa_Paused                     []types.EventGrandpaPaused                     //nolint:stylecheck,golint
-	Grandpa_Resumed                    []types.EventGrandpaResumed                    //nolint:stylecheck,golint
-	ImOnline_HeartbeatReceived         []types.EventImOnlineHeartbeatReceived         //nolint:stylecheck,golint
-	ImOnline_AllGood                   []types.EventImOnlineAllGood                   //nolint:stylecheck,golint
-	ImOnline_SomeOffline               []types.EventImOnlineSomeOffline               //nolint:stylecheck,golint
-	Indices_IndexAssigned              []types.EventIndicesIndexAssigned              //nolint:stylecheck,golint
-	Indices_IndexFreed                 []types.EventIndicesIndexFreed                 //nolint:stylecheck,golint
-	Indices_IndexFrozen                []types.EventIndicesIndexFrozen                //nolint:stylecheck,golint
-	Offences_Offence                   []types.EventOffencesOffence                   //nolint:stylecheck,golint
-	Session_NewSession                 []types.EventSessionNewSession                 //nolint:stylecheck,golint
-	Staking_EraPayout                  []types.EventStakingEraPayout                  //nolint:stylecheck,golint
-	Staking_Reward                     []types.EventStakingReward                     //nolint:stylecheck,golint
-	Staking_Slash                      []types.EventStakingSlash                      //nolint:stylecheck,golint
-	Staking_OldSlashingReportDiscarded []types.EventStakingOldSlashingReportDiscarded //nolint:stylecheck,golint
-	Staking_StakingElection            []types.EventStakingStakingElection            //nolint:stylecheck,golint
-	Staking_SolutionStored             []types.EventStakingSolutionStored             //nolint:stylecheck,golint
-	Staking_Bonded                     []types.EventStakingBonded                     //nolint:stylecheck,golint
-	Staking_Unbonded                   []types.EventStakingUnbonded                   //nolint:stylecheck,golint
-	Staking_Withdrawn                  []types.EventStakingWithdrawn                  //nolint:stylecheck,golint
-	System_ExtrinsicSuccess            []types.EventSystemExtrinsicSuccess            //nolint:stylecheck,golint
-	System_ExtrinsicFailed             []types.EventSystemExtrinsicFailed             //nolint:stylecheck,golint
-	System_CodeUpdated                 []types.EventSystemCodeUpdated                 //nolint:stylecheck,golint
-	System_NewAccount                  []types.EventSystemNewAccount                  //nolint:stylecheck,golint
-	System_KilledAccount               []types.EventSystemKilledAccount               //nolint:stylecheck,golint
-	Assets_Issued                      []types.EventAssetIssued                       //nolint:stylecheck,golint
-	Assets_Transferred                 []types.EventAssetTransferred                  //nolint:stylecheck,golint
-	Assets_Destroyed                   []types.EventAssetDestroyed                    //nolint:stylecheck,golint
-	Democracy_Proposed                 []types.EventDemocracyProposed                 //nolint:stylecheck,golint
-	Democracy_Tabled                   []types.EventDemocracyTabled                   //nolint:stylecheck,golint
-	Democracy_ExternalTabled           []types.EventDemocracyExternalTabled           //nolint:stylecheck,golint
-	Democracy_Started                  []types.EventDemocracyStarted                  //nolint:stylecheck,golint
-	Democracy_Passed                   []types.EventDemocracyPassed                   //nolint:stylecheck,golint
-	Democracy_NotPassed                []types.EventDemocracyNotPassed                //nolint:stylecheck,golint
-	Democracy_Cancelled                []types.EventDemocracyCancelled                //nolint:stylecheck,golint
-	Democracy_Executed                 []types.EventDemocracyExecuted                 //nolint:stylecheck,golint
-	Democracy_Delegated                []types.EventDemocracyDelegated                //nolint:stylecheck,golint
-	Democracy_Undelegated              []types.EventDemocracyUndelegated              //nolint:stylecheck,golint
-	Democracy_Vetoed                   []types.EventDemocracyVetoed                   //nolint:stylecheck,golint
-	Democracy_PreimageNoted            []types.EventDemocracyPreimageNoted            //nolint:stylecheck,golint
-	Democracy_PreimageUsed             []types.EventDemocracyPreimageUsed             //nolint:stylecheck,golint
-	Democracy_PreimageInvalid          []types.EventDemocracyPreimageInvalid          //nolint:stylecheck,golint
-	Democracy_PreimageMissing          []types.EventDemocracyPreimageMissing          //nolint:stylecheck,golint
-	Democracy_PreimageReaped           []types.EventDemocracyPreimageReaped           //nolint:stylecheck,golint
-	Democracy_Unlocked                 []types.EventDemocracyUnlocked                 //nolint:stylecheck,golint
-	Democracy_Blacklisted              []types.EventDemocracyBlacklisted              //nolint:stylecheck,golint
-	Council_Proposed                   []types.EventCollectiveProposed                //nolint:stylecheck,golint
-	Council_Voted                      []types.EventCollectiveVoted                   //nolint:stylecheck,golint
-	Council_Approved                   []types.EventCollectiveApproved                //nolint:stylecheck,golint
-	Council_Disapproved                []types.EventCollectiveDisapproved             //nolint:stylecheck,golint
-	Council_Executed                   []types.EventCollectiveExecuted                //nolint:stylecheck,golint
-	Council_MemberExecuted             []types.EventCollectiveMemberExecuted          //nolint:stylecheck,golint
-	Council_Closed                     []types.EventCollectiveClosed                  //nolint:stylecheck,golint
-	TechnicalCommittee_Proposed        []types.EventTechnicalCommitteeProposed        //nolint:stylecheck,golint
-	TechnicalCommittee_Voted           []types.EventTechnicalCommitteeVoted           //nolint:stylecheck,golint
-	TechnicalCommittee_Approved        []types.EventTechnicalCommitteeApproved        //nolint:stylecheck,golint
-	TechnicalCommittee_Disapproved     []types.EventTechnicalCommitteeDisapproved     //nolint:stylecheck,golint
-	TechnicalCommittee_Executed        []types.EventTechnicalCommitteeExecuted        //nolint:stylecheck,golint
-	TechnicalCommittee_MemberExecuted  []types.EventTechnicalCommitteeMemberExecuted  //nolint:stylecheck,golint
-	TechnicalCommittee_Closed          []types.EventTechnicalCommitteeClosed          //nolint:stylecheck,golint
-	TechnicalMembership_MemberAdded    []types.EventTechnicalMembershipMemberAdded    //nolint:stylecheck,golint
-	TechnicalMembership_MemberRemoved  []types.EventTechnicalMembershipMemberRemoved  //nolint:stylecheck,golint
-	TechnicalMembership_MembersSwapped []types.EventTechnicalMembershipMembersSwapped //nolint:stylecheck,golint
-	TechnicalMembership_MembersReset   []types.EventTechnicalMembershipMembersReset   //nolint:stylecheck,golint
-	TechnicalMembership_KeyChanged     []types.EventTechnicalMembershipKeyChanged     //nolint:stylecheck,golint
-	TechnicalMembership_Dummy          []types.EventTechnicalMembershipDummy          //nolint:stylecheck,golint
-	Elections_NewTerm                  []types.EventElectionsNewTerm                  //nolint:stylecheck,golint
-	Elections_EmptyTerm                []types.EventElectionsEmptyTerm                //nolint:stylecheck,golint
-	Elections_ElectionError            []types.EventElectionsElectionError            //nolint:stylecheck,golint
-	Elections_MemberKicked             []types.EventElectionsMemberKicked             //nolint:stylecheck,golint
-	Elections_MemberRenounced          []types.EventElectionsMemberRenounced          //nolint:stylecheck,golint
-	Elections_VoterReported            []types.EventElectionsVoterReported            //nolint:stylecheck,golint
-	Identity_IdentitySet               []types.EventIdentitySet                       //nolint:stylecheck,golint
-	Identity_IdentityCleared           []types.EventIdentityCleared                   //nolint:stylecheck,golint
-	Identity_IdentityKilled            []types.EventIdentityKilled                    //nolint:stylecheck,golint
-	Identity_JudgementRequested        []types.EventIdentityJudgementRequested        //nolint:stylecheck,golint
-	Identity_JudgementUnrequested      []types.EventIdentityJudgementUnrequested      //nolint:stylecheck,golint
-	Identity_JudgementGiven            []types.EventIdentityJudgementGiven            //nolint:stylecheck,golint
-	Identity_RegistrarAdded            []types.EventIdentityRegistrarAdded            //nolint:stylecheck,golint
-	Identity_SubIdentityAdded          []types.EventIdentitySubIdentityAdded          //nolint:stylecheck,golint
-	Identity_SubIdentityRemoved        []types.EventIdentitySubIdentityRemoved        //nolint:stylecheck,golint
-	Identity_SubIdentityRevoked        []types.EventIdentitySubIdentityRevoked        //nolint:stylecheck,golint
-	Society_Founded                    []types.EventSocietyFounded                    //nolint:stylecheck,golint
-	Society_Bid                        []types.EventSocietyBid                        //nolint:stylecheck,golint
-	Society_Vouch                      []types.EventSocietyVouch                      //nolint:stylecheck,golint
-	Society_AutoUnbid                  []types.EventSocietyAutoUnbid                  //nolint:stylecheck,golint
-	Society_Unbid                      []types.EventSocietyUnbid                      //nolint:stylecheck,golint
-	Society_Unvouch                    []types.EventSocietyUnvouch                    //nolint:stylecheck,golint
-	Society_Inducted                   []types.EventSocietyInducted                   //nolint:stylecheck,golint
-	Society_SuspendedMemberJudgement   []types.EventSocietySuspendedMemberJudgement   //nolint:stylecheck,golint
-	Society_CandidateSuspended         []types.EventSocietyCandidateSuspended         //nolint:stylecheck,golint
-	Society_MemberSuspended            []types.EventSocietyMemberSuspended            //nolint:stylecheck,golint
-	Society_Challenged                 []types.EventSocietyChallenged                 //nolint:stylecheck,golint
-	Society_Vote                       []types.EventSocietyVote                       //nolint:stylecheck,golint
-	Society_DefenderVote               []types.EventSocietyDefenderVote               //nolint:stylecheck,golint
-	Society_NewMaxMembers              []types.EventSocietyNewMaxMembers              //nolint:stylecheck,golint
-	Society_Unfounded                  []types.EventSocietyUnfounded                  //nolint:stylecheck,golint
-	Society_Deposit                    []types.EventSocietyDeposit                    //nolint:stylecheck,golint
-	Recovery_RecoveryCreated           []types.EventRecoveryCreated                   //nolint:stylecheck,golint
-	Recovery_RecoveryInitiated         []types.EventRecoveryInitiated                 //nolint:stylecheck,golint
-	Recovery_RecoveryVouched           []types.EventRecoveryVouched                   //nolint:stylecheck,golint
-	Recovery_RecoveryClosed            []types.EventRecoveryClosed                    //nolint:stylecheck,golint
-	Recovery_AccountRecovered          []types.EventRecoveryAccountRecovered          //nolint:stylecheck,golint
-	Recovery_RecoveryRemoved           []types.EventRecoveryRemoved                   //nolint:stylecheck,golint
-	Vesting_VestingUpdated             []types.EventVestingVestingUpdated             //nolint:stylecheck,golint
-	Vesting_VestingCompleted           []types.EventVestingVestingCompleted           //nolint:stylecheck,golint
-	Scheduler_Scheduled                []types.EventSchedulerScheduled                //nolint:stylecheck,golint
-	Scheduler_Canceled                 []types.EventSchedulerCanceled                 //nolint:stylecheck,golint
-	Scheduler_Dispatched               []types.EventSchedulerDispatched               //nolint:stylecheck,golint
-	Proxy_ProxyExecuted                []types.EventProxyProxyExecuted                //nolint:stylecheck,golint
-	Proxy_AnonymousCreated             []types.EventProxyAnonymousCreated             //nolint:stylecheck,golint
-	Proxy_Announced                    []types.EventProxyAnnounced                    //nolint:stylecheck,golint
-	Sudo_Sudid                         []types.EventSudoSudid                         //nolint:stylecheck,golint
-	Sudo_KeyChanged                    []types.EventSudoKeyChanged                    //nolint:stylecheck,golint
-	Sudo_SudoAsDone                    []types.EventSudoAsDone                        //nolint:stylecheck,golint
-	Treasury_Proposed                  []types.EventTreasuryProposed                  //nolint:stylecheck,golint
-	Treasury_Spending                  []types.EventTreasurySpending                  //nolint:stylecheck,golint
-	Treasury_Awarded                   []types.EventTreasuryAwarded                   //nolint:stylecheck,golint
-	Treasury_Rejected                  []types.EventTreasuryRejected                  //nolint:stylecheck,golint
-	Treasury_Burnt                     []types.EventTreasuryBurnt                     //nolint:stylecheck,golint
-	Treasury_Rollover                  []types.EventTreasuryRollover                  //nolint:stylecheck,golint
-	Treasury_Deposit                   []types.EventTreasuryDeposit                   //nolint:stylecheck,golint
-	Treasury_NewTip                    []types.EventTreasuryNewTip                    //nolint:stylecheck,golint
-	Treasury_TipClosing                []types.EventTreasuryTipClosing                //nolint:stylecheck,golint
-	Treasury_TipClosed                 []types.EventTreasuryTipClosed                 //nolint:stylecheck,golint
-	Treasury_TipRetracted              []types.EventTreasuryTipRetracted              //nolint:stylecheck,golint
-	Treasury_BountyProposed            []types.EventTreasuryBountyProposed            //nolint:stylecheck,golint
-	Treasury_BountyRejected            []types.EventTreasuryBountyRejected            //nolint:stylecheck,golint
-	Treasury_BountyBecameActive        []types.EventTreasuryBountyBecameActive        //nolint:stylecheck,golint
-	Treasury_BountyAwarded             []types.EventTreasuryBountyAwarded             //nolint:stylecheck,golint
-	Treasury_BountyClaimed             []types.EventTreasuryBountyClaimed             //nolint:stylecheck,golint
-	Treasury_BountyCanceled            []types.EventTreasuryBountyCanceled            //nolint:stylecheck,golint
-	Treasury_BountyExtended            []types.EventTreasuryBountyExtended            //nolint:stylecheck,golint
-	Contracts_Instantiated             []types.EventContractsInstantiated             //nolint:stylecheck,golint
-	Contracts_Evicted                  []types.EventContractsEvicted                  //nolint:stylecheck,golint
-	Contracts_Restored                 []types.EventContractsRestored                 //nolint:stylecheck,golint
-	Contracts_CodeStored               []types.EventContractsCodeStored               //nolint:stylecheck,golint
-	Contracts_ScheduleUpdated          []types.EventContractsScheduleUpdated          //nolint:stylecheck,golint
-	Contracts_ContractExecution        []types.EventContractsContractExecution        //nolint:stylecheck,golint
-	Utility_BatchInterrupted           []types.EventUtilityBatchInterrupted           //nolint:stylecheck,golint
-	Utility_BatchCompleted             []types.EventUtilityBatchCompleted             //nolint:stylecheck,golint
-	Multisig_NewMultisig               []types.EventMultisigNewMultisig               //nolint:stylecheck,golint
-	Multisig_MultisigApproval          []types.EventMultisigApproval                  //nolint:stylecheck,golint
-	Multisig_MultisigExecuted          []types.EventMultisigExecuted                  //nolint:stylecheck,golint
-	Multisig_MultisigCancelled         []types.EventMultisigCancelled                 //nolint:stylecheck,golint
-}
-
-// EventBalancesTransfer is emitted when a transfer succeeded (from, to, value)
-type EventBalancesWithdraw struct {
-	Phase types.Phase
-	From  types.AccountID
-	//	To     types.AccountID
-	Value  types.U128
-	Topics []types.Hash
-}
-
+// DecodeEvents prints the events emitted in the block with the given hash.
 func (c *Connection) DecodeEvents(blockHashBytes []byte) error {
 	blockHash := types.NewHash(blockHashBytes)
 
-	block, err := c.GetBlockByHash(blockHash)
+	events, err := c.GetEvents(blockHash)
 	if err != nil {
-		return fmt.Errorf("error getting block for hash %s: %#x", blockHash, err)
-	}
-	if block.Block.Header.Number == 0 {
-		return fmt.Errorf("can't get data for block hash %s - it may not exist", blockHashString)
+		return err
 	}
-
-	meta, err := c.getMetadata(blockHash)
-	if err != nil {
-		return fmt.Errorf("error getting meta data latest: %w", err)
+	for _, event := range events {
+		fmt.Println(event)
 	}
-	_ = meta // TODO: remove
-	/*
-		// TODO: unable to decode field 4 event #2 with EventID [5 8], field Balances_Withdraw:
-		// expected more bytes, but could not decode any more - problem with custom
-		// EventBalancesWithdraw struct 0- check Rust ref for the required data types...
-		//
-
-		key, err := types.CreateStorageKey(meta, "System", "Events", nil, nil)
-		if err != nil {
-			return fmt.Errorf("error creating storage key: %w", err)
-		}
-
-		raw, err := c.Api.RPC.State.GetStorageRaw(key, blockHash)
-		if err != nil {
-			return fmt.Errorf("error retrieving raw storage data for key %v: %w", key, err)
-		}
-
-		//	events := types.EventRecords{}
-		events := EventRecords{}
-		err = types.EventRecordsRaw(*raw).DecodeEventRecords(meta, &events)
-		if err != nil {
-			return fmt.Errorf("error decoding event records for %v: %w", *raw, err)
-		}
-		for _, event := range events.Balances_Transfer {
-			fmt.Println("event.Value = ", event.Value)
-
-		}
-	*/
 	return nil
 }
 
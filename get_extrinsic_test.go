@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
@@ -68,3 +71,71 @@ func TestGetData(t *testing.T) {
 	assert.NoError(t, err)
 
 }
+
+func TestTransferTxEvents(t *testing.T) {
+	signer := bytes.Repeat([]byte{1}, 32)
+	receiver := bytes.Repeat([]byte{2}, 32)
+	other := bytes.Repeat([]byte{3}, 32)
+	moduleError := DispatchError{Kind: "Module", Pallet: "Balances", Name: "InsufficientBalance"}
+	watched := NewWatchSet()
+	assert.NoError(t, watched.AddPublicKey(receiver))
+
+	transfers := []CallTransfer{
+		{Path: "Utility.force_batch/calls[0]/Balances.transfer", From: signer, To: receiver, Amount: big.NewInt(100)},
+		{Path: "Utility.force_batch/calls[1]/Balances.transfer", From: signer, To: other, Amount: big.NewInt(100)},
+		{Path: "Utility.force_batch/calls[2]/Balances.transfer", From: signer, To: receiver, Amount: big.NewInt(500)},
+		{Path: "Utility.force_batch/calls[3]/Balances.transfer", From: signer, To: receiver, Amount: big.NewInt(200)},
+	}
+	event := func(index uint32, method string, values ...interface{}) Event {
+		e := testEvent(1, method, values...)
+		e.Index = index
+		return e
+	}
+
+	// The transfer of 500 failed within the batch, so only two transfers to the receiver are credited.
+	events := []Event{
+		event(3, "Balances.Transfer", signer, receiver, big.NewInt(100)),
+		event(4, "Utility.ItemCompleted"),
+		event(5, "Balances.Transfer", signer, other, big.NewInt(100)),
+		event(6, "Utility.ItemCompleted"),
+		event(7, "Utility.ItemFailed", moduleError),
+		event(8, "Balances.Transfer", signer, receiver, big.NewInt(200)),
+		event(9, "Utility.ItemCompleted"),
+		event(10, "System.ExtrinsicSuccess"),
+	}
+	txEvents := transferTxEvents(events, transfers, watched)
+	if assert.Len(t, txEvents, 2) {
+		assert.True(t, txEvents[0].Success)
+		assert.Equal(t, big.NewInt(100), txEvents[0].Value)
+		assert.Equal(t, hex.EncodeToString(signer), txEvents[0].From)
+		assert.Equal(t, transfers[0].Path, txEvents[0].Path)
+		assert.Equal(t, []uint32{3, 10}, txEvents[0].EventIndices)
+		assert.Equal(t, big.NewInt(200), txEvents[1].Value)
+		assert.Equal(t, transfers[3].Path, txEvents[1].Path)
+		assert.Equal(t, []uint32{8, 10}, txEvents[1].EventIndices)
+	}
+
+	// Nothing is credited for a failed extrinsic.
+	events = []Event{event(3, "System.ExtrinsicFailed", moduleError)}
+	txEvents = transferTxEvents(events, transfers, watched)
+	if assert.Len(t, txEvents, 3) {
+		for _, txEvent := range txEvents {
+			assert.False(t, txEvent.Success)
+			assert.Equal(t, "Balances.InsufficientBalance", txEvent.Error)
+			assert.Equal(t, []uint32{3}, txEvent.EventIndices)
+		}
+	}
+
+	// Without the call, transfers are still found from the events.
+	events = []Event{
+		event(0, "Balances.Transfer", other, receiver, big.NewInt(7)),
+		event(1, "System.ExtrinsicSuccess"),
+	}
+	txEvents = transferTxEvents(events, nil, watched)
+	if assert.Len(t, txEvents, 1) {
+		assert.Equal(t, hex.EncodeToString(other), txEvents[0].From)
+		assert.Empty(t, txEvents[0].Path)
+	}
+
+	assert.Empty(t, transferTxEvents(nil, transfers, watched))
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// Kinds of LedgerEntry.
+const (
+	EntryTransferIn  = "transfer_in"
+	EntryTransferOut = "transfer_out"
+	EntryFee         = "fee"
+	EntryReward      = "reward"
+	EntrySlash       = "slash"
+	EntryReserve     = "reserve"
+	EntryUnreserve   = "unreserve"
+	EntryDustLost    = "dust_lost"
+	EntryRepatriated = "repatriated" // Reserved balance of another account moved to this one
+	EntryDeposit     = "deposit"     // Any other Balances.Deposit
+	EntryWithdraw    = "withdraw"    // Any other Balances.Withdraw
+)
+
+// LedgerEntry is an event that changed the free balance of an account.
+type LedgerEntry struct {
+	Kind string `json:"kind"`
+	// Amount is the change in free balance - negative for transfers out, fees, slashes, reserves, dust lost and
+	// withdrawals.
+	Amount *big.Int `json:"amount"`
+	Method string   `json:"method"`
+	// EventIndex is the position of the event in the block's events. The fee of an extrinsic has the index of its
+	// ExtrinsicSuccess or ExtrinsicFailed event.
+	EventIndex uint32 `json:"event_index,string"`
+	// ExtrinsicIndex is -1 for events emitted outside an extrinsic, such as staking rewards.
+	ExtrinsicIndex int `json:"extrinsic_index,string"`
+	// Counterparty is the hex public key of the other account of a transfer or repatriation.
+	Counterparty string `json:"counterparty,omitempty"`
+}
+
+func (e LedgerEntry) String() string {
+	return fmt.Sprintf("%s %s (%s, event %d, extrinsic %d) %s", e.Kind, e.Amount, e.Method, e.EventIndex, e.ExtrinsicIndex, e.Counterparty)
+}
+
+// AccountHistoryEntry is a block in which the balance of an account changed, with the events that explain the
+// change.
+type AccountHistoryEntry struct {
+	BlockHash   string    `json:"block_hash"`
+	BlockNumber uint64    `json:"block_number,string"`
+	TimeStamp   time.Time `json:"timeStamp"`
+	// Free and Reserved are the balances after the block.
+	Free     *big.Int `json:"free"`
+	Reserved *big.Int `json:"reserved"`
+	// Delta is the change in the free balance in the block.
+	Delta   *big.Int      `json:"delta"`
+	Entries []LedgerEntry `json:"entries"`
+	// Unexplained is Delta less the sum of the entries. It is non-zero if the balance changed in a way that no
+	// event explains, e.g. Balances.BalanceSet or a reward paid to a different account than the stash.
+	Unexplained *big.Int `json:"unexplained"`
+}
+
+func (h AccountHistoryEntry) String() string {
+	entries := ""
+	for _, e := range h.Entries {
+		entries += "\n  " + e.String()
+	}
+	return fmt.Sprintf("BlockHash: %s\nBlockNumber: %d\nTimestamp: %s\nFree: %s\nReserved: %s\nDelta: %s\nEntries:%s\nUnexplained: %s\n",
+		h.BlockHash,
+		h.BlockNumber,
+		h.TimeStamp.String(),
+		h.Free,
+		h.Reserved,
+		h.Delta,
+		entries,
+		h.Unexplained,
+	)
+}
+
+// AccountHistory returns a ledger of the changes to the free balance of the account with the given hex public
+// key, from block checkpoint as for GetStorageHistoryForID. Like GetChangeData, it needs an archive node.
+func (c *Connection) AccountHistory(ID string, checkpoint uint64) ([]AccountHistoryEntry, error) {
+	return c.historySource().accountHistory(ID, checkpoint)
+}
+
+// historySource reads the chain for AccountHistory.
+type historySource struct {
+	changeData        func(ID string, checkpoint uint64) ([]ChangeData, error)
+	freeBalanceBefore func(account []byte, blockHash types.Hash) (*big.Int, error)
+	block             func(blockHash types.Hash) (*types.SignedBlock, error)
+	events            func(blockHash types.Hash) ([]Event, error)
+	timestamp         func(block *types.SignedBlock, blockHash types.Hash) (*time.Time, error)
+}
+
+func (c *Connection) historySource() historySource {
+	return historySource{
+		changeData:        c.GetChangeData,
+		freeBalanceBefore: c.freeBalanceBefore,
+		block:             c.GetBlockByHash,
+		events:            c.GetEvents,
+		timestamp:         c.GetBlockTimestamp,
+	}
+}
+
+func (s historySource) accountHistory(ID string, checkpoint uint64) ([]AccountHistoryEntry, error) {
+	changes, err := s.changeData(ID, checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return []AccountHistoryEntry{}, nil
+	}
+	account, err := types.HexDecodeString(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first change set is the state at the first block whether or not it changed there, so the opening balance
+	// is taken from its parent.
+	first := types.NewHash(changes[0].BlockHash)
+	prevFree, err := s.freeBalanceBefore(account, first)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []AccountHistoryEntry{}
+	for _, change := range changes {
+		free := new(big.Int).Set(&change.AmountAtThisBlock)
+		delta := new(big.Int).Sub(free, prevFree)
+		prevFree = free
+		if delta.Sign() == 0 {
+			// Only the reserved balance, the nonce or the frozen balances changed.
+			continue
+		}
+
+		blockHash := types.NewHash(change.BlockHash)
+		block, err := s.block(blockHash)
+		if err != nil {
+			return nil, fmt.Errorf("error getting block for hash %#x: %w", blockHash, err)
+		}
+		events, err := s.events(blockHash)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := s.timestamp(block, blockHash)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := ledgerEntries(events, block, account)
+		unexplained := new(big.Int).Set(delta)
+		for _, e := range entries {
+			unexplained.Sub(unexplained, e.Amount)
+		}
+		history = append(history, AccountHistoryEntry{
+			BlockHash:   hex.EncodeToString(blockHash[:]),
+			BlockNumber: uint64(block.Block.Header.Number),
+			TimeStamp:   *timestamp,
+			Free:        free,
+			Reserved:    new(big.Int).Set(&change.Reserved),
+			Delta:       delta,
+			Entries:     entries,
+			Unexplained: unexplained,
+		})
+	}
+	return history, nil
+}
+
+// freeBalanceBefore returns the free balance of the account in the parent of the given block, zero for genesis.
+func (c *Connection) freeBalanceBefore(account []byte, blockHash types.Hash) (*big.Int, error) {
+	header, err := c.Api.RPC.Chain.GetHeader(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting header for block %#x: %w", blockHash, err)
+	}
+	if header.Number == 0 {
+		return big.NewInt(0), nil
+	}
+	meta, err := c.getMetadata(header.ParentHash)
+	if err != nil {
+		return nil, err
+	}
+	key, err := types.CreateStorageKey(meta, "System", "Account", account, nil)
+	if err != nil {
+		return nil, err
+	}
+	var accountInfo AccountInfo
+	if _, err := c.Api.RPC.State.GetStorage(key, &accountInfo, header.ParentHash); err != nil {
+		return nil, fmt.Errorf("error getting account at block %#x: %w", header.ParentHash, err)
+	}
+	return u128Int(accountInfo.Data.Free), nil
+}
+
+// ledgerEntries explains the changes to the free balance of an account in a block from the block's events.
+//
+// Balances.Withdraw and the trailing Balances.Deposit refund of an extrinsic signed by the account are its fee, and
+// are reported as one fee entry. The refund is followed by the fee deposits and, on newer runtimes,
+// TransactionPayment.TransactionFeePaid. A Balances.Deposit that pays a Staking.Rewarded, and a Staking.Slashed carried out
+// by a Balances.Slashed, are reported once. Older runtimes emit only the staking events.
+func ledgerEntries(events []Event, block *types.SignedBlock, account []byte) []LedgerEntry {
+	entries := []LedgerEntry{}
+	// feeEvents are the events accounted for by fee entries.
+	feeEvents := map[uint32]bool{}
+	for i, extrinsic := range block.Block.Extrinsics {
+		if !extrinsic.IsSigned() {
+			continue
+		}
+		if signer, err := signerAccount(extrinsic.Signature.Signer); err != nil || !bytes.Equal(signer, account) {
+			continue
+		}
+		extrinsicEvents := eventsForExtrinsic(events, uint32(i))
+		fee := extrinsicFee(extrinsicEvents, account)
+		if fee == nil || len(extrinsicEvents) == 0 {
+			continue
+		}
+		result := extrinsicEvents[len(extrinsicEvents)-1]
+		entries = append(entries, LedgerEntry{
+			Kind:           EntryFee,
+			Amount:         new(big.Int).Neg(fee),
+			Method:         result.Method(),
+			EventIndex:     result.Index,
+			ExtrinsicIndex: i,
+		})
+		for _, e := range findEvents(extrinsicEvents, "Balances.Withdraw") {
+			if bytes.Equal(e.AccountField(0), account) {
+				feeEvents[e.Index] = true
+				break
+			}
+		}
+		for j := len(extrinsicEvents) - 1; j >= 0; j-- {
+			switch e := extrinsicEvents[j]; e.Method() {
+			case "System.ExtrinsicSuccess", "System.ExtrinsicFailed", "Treasury.Deposit", "TransactionPayment.TransactionFeePaid":
+				continue
+			case "Balances.Deposit":
+				if bytes.Equal(e.AccountField(0), account) {
+					feeEvents[e.Index] = true
+				}
+				continue
+			}
+			break
+		}
+	}
+
+	// sameEvent reports whether there is an event with the method, account and amount in the phase of e.
+	sameEvent := func(e Event, method string) bool {
+		for _, other := range findEvents(events, method) {
+			if other.Phase == e.Phase && bytes.Equal(other.AccountField(0), e.AccountField(0)) &&
+				other.BalanceField(1) != nil && e.BalanceField(1) != nil && other.BalanceField(1).Cmp(e.BalanceField(1)) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, e := range events {
+		if feeEvents[e.Index] {
+			continue
+		}
+		entry := LedgerEntry{Method: e.Method(), EventIndex: e.Index, ExtrinsicIndex: -1}
+		if index, ok := e.ExtrinsicIndex(); ok {
+			entry.ExtrinsicIndex = int(index)
+		}
+		// Most balance events are { who, amount }.
+		who, amount := e.AccountField(0), e.BalanceField(1)
+
+		switch e.Method() {
+		case "Balances.Transfer":
+			// Transfer { from, to, amount }
+			amount = e.BalanceField(2)
+			if amount == nil {
+				continue
+			}
+			from, to := e.AccountField(0), e.AccountField(1)
+			if bytes.Equal(from, account) {
+				entries = append(entries, ledgerEntry(entry, EntryTransferOut, new(big.Int).Neg(amount), to))
+			}
+			if bytes.Equal(to, account) {
+				entries = append(entries, ledgerEntry(entry, EntryTransferIn, amount, from))
+			}
+			continue
+		case "Balances.ReserveRepatriated":
+			// ReserveRepatriated { from, to, amount, destination_status }
+			amount = e.BalanceField(2)
+			if amount != nil && bytes.Equal(e.AccountField(1), account) && e.Field(3) == "Free" {
+				entries = append(entries, ledgerEntry(entry, EntryRepatriated, amount, e.AccountField(0)))
+			}
+			continue
+		}
+
+		if !bytes.Equal(who, account) || amount == nil {
+			continue
+		}
+		switch e.Method() {
+		case "Balances.Reserved":
+			entries = append(entries, ledgerEntry(entry, EntryReserve, new(big.Int).Neg(amount), nil))
+		case "Balances.Unreserved":
+			entries = append(entries, ledgerEntry(entry, EntryUnreserve, amount, nil))
+		case "Balances.DustLost":
+			entries = append(entries, ledgerEntry(entry, EntryDustLost, new(big.Int).Neg(amount), nil))
+		case "Balances.Slashed":
+			entries = append(entries, ledgerEntry(entry, EntrySlash, new(big.Int).Neg(amount), nil))
+		case "Staking.Slashed":
+			if !sameEvent(e, "Balances.Slashed") {
+				entries = append(entries, ledgerEntry(entry, EntrySlash, new(big.Int).Neg(amount), nil))
+			}
+		case "Staking.Rewarded":
+			if !sameEvent(e, "Balances.Deposit") {
+				entries = append(entries, ledgerEntry(entry, EntryReward, amount, nil))
+			}
+		case "Balances.Deposit":
+			if sameEvent(e, "Staking.Rewarded") {
+				entries = append(entries, ledgerEntry(entry, EntryReward, amount, nil))
+			} else {
+				entries = append(entries, ledgerEntry(entry, EntryDeposit, amount, nil))
+			}
+		case "Balances.Withdraw":
+			entries = append(entries, ledgerEntry(entry, EntryWithdraw, new(big.Int).Neg(amount), nil))
+		}
+	}
+	return entries
+}
+
+func ledgerEntry(entry LedgerEntry, kind string, amount *big.Int, counterparty []byte) LedgerEntry {
+	entry.Kind = kind
+	entry.Amount = amount
+	if counterparty != nil {
+		entry.Counterparty = hex.EncodeToString(counterparty)
+	}
+	return entry
+}
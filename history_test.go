@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedgerEntries(t *testing.T) {
+	account := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	author := bytes.Repeat([]byte{3}, 32)
+
+	signed := func(signer []byte) types.Extrinsic {
+		extrinsic := types.Extrinsic{Version: types.ExtrinsicVersion4 | types.ExtrinsicBitSigned}
+		extrinsic.Signature.Signer = types.MultiAddress{IsID: true, AsID: types.NewAccountID(signer)}
+		return extrinsic
+	}
+	block := &types.SignedBlock{}
+	block.Block.Extrinsics = []types.Extrinsic{{Version: types.ExtrinsicVersion4}, signed(account), signed(other), signed(other)}
+
+	events := []Event{
+		testEvent(0, "System.ExtrinsicSuccess"),
+		// The account transfers 1000, paying a fee of 100 after a refund of 50.
+		testEvent(1, "Balances.Withdraw", account, big.NewInt(150)),
+		testEvent(1, "Balances.Reserved", account, big.NewInt(10)),
+		testEvent(1, "Balances.Transfer", account, other, big.NewInt(1000)),
+		testEvent(1, "Balances.Deposit", author, big.NewInt(20)),
+		testEvent(1, "Treasury.Deposit", big.NewInt(80)),
+		testEvent(1, "Balances.Deposit", account, big.NewInt(50)),
+		testEvent(1, "System.ExtrinsicSuccess"),
+		// Another account transfers to the account and pays out its staking reward.
+		testEvent(2, "Balances.Withdraw", other, big.NewInt(150)),
+		testEvent(2, "Balances.Transfer", other, account, big.NewInt(400)),
+		testEvent(2, "Balances.Deposit", account, big.NewInt(30)),
+		testEvent(2, "Staking.Rewarded", account, big.NewInt(30)),
+		testEvent(2, "Balances.Deposit", other, big.NewInt(150)),
+		testEvent(2, "System.ExtrinsicSuccess"),
+		// A deposit to the account that isn't a reward, and a slash reported by both pallets.
+		testEvent(3, "Balances.Deposit", account, big.NewInt(5)),
+		testEvent(3, "Balances.Slashed", account, big.NewInt(7)),
+		testEvent(3, "Staking.Slashed", account, big.NewInt(7)),
+		testEvent(3, "System.ExtrinsicSuccess"),
+		{Pallet: "Balances", Name: "Unreserved", Fields: []EventField{{Value: account}, {Value: big.NewInt(3)}}, Phase: types.Phase{IsFinalization: true}},
+		{Pallet: "Staking", Name: "Rewarded", Fields: []EventField{{Value: account}, {Value: big.NewInt(9)}}, Phase: types.Phase{IsFinalization: true}},
+		{Pallet: "Balances", Name: "DustLost", Fields: []EventField{{Value: account}, {Value: big.NewInt(1)}}, Phase: types.Phase{IsFinalization: true}},
+	}
+	for i := range events {
+		events[i].Index = uint32(i)
+	}
+
+	entries := ledgerEntries(events, block, account)
+	type line struct {
+		kind   string
+		amount int64
+		event  uint32
+	}
+	lines := []line{}
+	for _, e := range entries {
+		lines = append(lines, line{e.Kind, e.Amount.Int64(), e.EventIndex})
+	}
+	assert.Equal(t, []line{
+		{EntryFee, -100, 7},
+		{EntryReserve, -10, 2},
+		{EntryTransferOut, -1000, 3},
+		{EntryTransferIn, 400, 9},
+		{EntryReward, 30, 10},
+		{EntryDeposit, 5, 14},
+		{EntrySlash, -7, 15},
+		{EntryUnreserve, 3, 18},
+		{EntryReward, 9, 19},
+		{EntryDustLost, -1, 20},
+	}, lines)
+
+	if assert.Len(t, entries, 10) {
+		assert.Equal(t, 1, entries[0].ExtrinsicIndex)
+		assert.Equal(t, hex.EncodeToString(other), entries[2].Counterparty)
+		assert.Equal(t, -1, entries[9].ExtrinsicIndex)
+	}
+}
+
+func TestLedgerEntriesFeePaid(t *testing.T) {
+	account := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	author := bytes.Repeat([]byte{3}, 32)
+
+	block := &types.SignedBlock{}
+	extrinsic := types.Extrinsic{Version: types.ExtrinsicVersion4 | types.ExtrinsicBitSigned}
+	extrinsic.Signature.Signer = types.MultiAddress{IsID: true, AsID: types.NewAccountID(account)}
+	block.Block.Extrinsics = []types.Extrinsic{{Version: types.ExtrinsicVersion4}, extrinsic}
+
+	// The event order of current runtimes: the refund and fee deposits come before TransactionFeePaid, whose actual
+	// fee of 100 includes the tip of 10.
+	events := []Event{
+		testEvent(0, "System.ExtrinsicSuccess"),
+		testEvent(1, "Balances.Withdraw", account, big.NewInt(150)),
+		testEvent(1, "Balances.Transfer", account, other, big.NewInt(1000)),
+		testEvent(1, "Balances.Deposit", account, big.NewInt(50)),
+		testEvent(1, "Balances.Deposit", author, big.NewInt(28)),
+		testEvent(1, "Treasury.Deposit", big.NewInt(72)),
+		testEvent(1, "TransactionPayment.TransactionFeePaid", account, big.NewInt(100), big.NewInt(10)),
+		testEvent(1, "System.ExtrinsicSuccess"),
+	}
+	for i := range events {
+		events[i].Index = uint32(i)
+	}
+
+	entries := ledgerEntries(events, block, account)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, EntryFee, entries[0].Kind)
+		assert.Equal(t, big.NewInt(-100), entries[0].Amount)
+		assert.Equal(t, EntryTransferOut, entries[1].Kind)
+		assert.Equal(t, big.NewInt(-1000), entries[1].Amount)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Kinds of IndexRecord.
+const (
+	RecordTransfer = "transfer" // A Balances.Transfer event
+	RecordFee      = "fee"      // The fee paid by the signer of an extrinsic
+	RecordEvent    = "event"    // Any other event with an account field, e.g. Balances.Deposit or Staking.Rewarded
+)
+
+var (
+	recordsBucket    = []byte("records")
+	accountsBucket   = []byte("accounts")
+	extrinsicsBucket = []byte("extrinsics")
+	timesBucket      = []byte("times")
+	metaBucket       = []byte("meta")
+	checkpointKey    = []byte("checkpoint")
+)
+
+// IndexRecord is an event that affects accounts, stored in an Index.
+type IndexRecord struct {
+	Kind        string    `json:"kind"`
+	BlockNumber uint64    `json:"block_number,string"`
+	BlockHash   string    `json:"block_hash"`
+	TimeStamp   time.Time `json:"timeStamp"`
+	// ExtrinsicIndex is -1 for events emitted outside an extrinsic, such as staking rewards.
+	ExtrinsicIndex int    `json:"extrinsic_index,string"`
+	ExtrinsicHash  string `json:"extrinsic_hash,omitempty"`
+	// EventIndex is the position of the event in the block's events. The fee record of an extrinsic has the index
+	// of its ExtrinsicSuccess or ExtrinsicFailed event.
+	EventIndex uint32 `json:"event_index,string"`
+	Method     string `json:"method"`
+	// Accounts are the hex public keys of the accounts in the event's fields, or of the signer for a fee.
+	Accounts []string `json:"accounts"`
+	From     string   `json:"from,omitempty"` // Hexstring of PubKey, for transfers
+	To       string   `json:"to,omitempty"`   // Hexstring of PubKey, for transfers
+	// Amount is the amount transferred, the fee paid, or the first balance field of other events.
+	Amount *big.Int `json:"amount"`
+	// Success is false for the fee of an extrinsic that failed.
+	Success bool `json:"success"`
+}
+
+func (r IndexRecord) String() string {
+	return fmt.Sprintf("Kind: %s\nBlockNumber: %d\nBlockHash: %s\nTimestamp: %s\nExtrinsicIndex: %d\nExtrinsicHash: %s\nEventIndex: %d\nMethod: %s\nAccounts: %v\nFrom: %s\nTo: %s\nAmount: %d\nSuccess: %t\n",
+		r.Kind,
+		r.BlockNumber,
+		r.BlockHash,
+		r.TimeStamp.String(),
+		r.ExtrinsicIndex,
+		r.ExtrinsicHash,
+		r.EventIndex,
+		r.Method,
+		r.Accounts,
+		r.From,
+		r.To,
+		r.Amount,
+		r.Success,
+	)
+}
+
+// Index is an on-disk index of transfers, fees and account events, so that the history of an account can be
+// queried without an archive node. It is filled by IndexBlocks.
+//
+// Records are keyed by block number and event index. The accounts and extrinsics buckets map an account or
+// extrinsic hash followed by a record key to nothing, and the times bucket maps a block's timestamp followed by
+// its number to nothing, so that each query is a range scan.
+type Index struct {
+	db *bolt.DB
+}
+
+// OpenIndex opens the index at path, creating it if it doesn't exist.
+func OpenIndex(path string) (*Index, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening index %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, accountsBucket, extrinsicsBucket, timesBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating buckets in index %s: %w", path, err)
+	}
+	return &Index{db: db}, nil
+}
+
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// Checkpoint returns the last block indexed. ok is false if the index is empty.
+func (ix *Index) Checkpoint() (checkpoint BlockRef, ok bool, err error) {
+	err = ix.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(checkpointKey)
+		if data == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(data, &checkpoint)
+	})
+	return checkpoint, ok, err
+}
+
+// IndexBlocks indexes finalized blocks until stop is closed or an error occurs, resuming after the last block
+// indexed. If the index is empty it starts at block checkpoint, as for GetStorageHistoryForID, or after the
+// current finalized head if checkpoint is zero.
+func (c *Connection) IndexBlocks(ix *Index, checkpoint uint64, stop <-chan struct{}) error {
+	last, ok, err := ix.Checkpoint()
+	if err != nil {
+		return err
+	}
+	if ok {
+		checkpoint = last.Number + 1
+	}
+	follower := c.NewFollower(FollowerOptions{Checkpoint: checkpoint})
+	follower.OnBlock = func(b FollowedBlock) error {
+		return c.indexBlock(ix, b)
+	}
+	return follower.Run(stop)
+}
+
+func (c *Connection) indexBlock(ix *Index, b FollowedBlock) error {
+	meta, err := c.getMetadata(b.Hash)
+	if err != nil {
+		return err
+	}
+	events, err := c.getBlockEvents(b.Hash, meta)
+	if err != nil {
+		return err
+	}
+	timestamp, err := c.GetBlockTimestamp(b.Block, b.Hash)
+	if err != nil {
+		return err
+	}
+	records, err := blockIndexRecords(b.Block, b.Hash, *timestamp, events)
+	if err != nil {
+		return err
+	}
+	if err := ix.put(b.BlockRef, *timestamp, records); err != nil {
+		return fmt.Errorf("error indexing block %v: %w", b.BlockRef, err)
+	}
+	return nil
+}
+
+// blockIndexRecords builds the records for the events of a block, in event order.
+func blockIndexRecords(block *types.SignedBlock, blockHash types.Hash, timestamp time.Time, events []Event) ([]IndexRecord, error) {
+	extrinsicHashes := make([]string, len(block.Block.Extrinsics))
+	for i, extrinsic := range block.Block.Extrinsics {
+		hash, err := types.GetHash(extrinsic)
+		if err != nil {
+			return nil, fmt.Errorf("problem getting extrinsic hash: %w", err)
+		}
+		extrinsicHashes[i] = hex.EncodeToString(hash[:])
+	}
+
+	records := []IndexRecord{}
+	for _, e := range events {
+		record := IndexRecord{
+			BlockNumber:    uint64(block.Block.Header.Number),
+			BlockHash:      hex.EncodeToString(blockHash[:]),
+			TimeStamp:      timestamp,
+			ExtrinsicIndex: -1,
+			EventIndex:     e.Index,
+			Method:         e.Method(),
+			Success:        true,
+		}
+		index, inExtrinsic := e.ExtrinsicIndex()
+		if inExtrinsic && int(index) < len(block.Block.Extrinsics) {
+			record.ExtrinsicIndex = int(index)
+			record.ExtrinsicHash = extrinsicHashes[index]
+		}
+
+		switch {
+		case e.Method() == "Balances.Transfer":
+			// Transfer { from, to, amount }
+			record.Kind = RecordTransfer
+			record.From = hex.EncodeToString(e.AccountField(0))
+			record.To = hex.EncodeToString(e.AccountField(1))
+			record.Accounts = []string{record.From, record.To}
+			record.Amount = e.BalanceField(2)
+		case e.Method() == "System.ExtrinsicSuccess" || e.Method() == "System.ExtrinsicFailed":
+			if record.ExtrinsicIndex < 0 {
+				continue
+			}
+			extrinsic := block.Block.Extrinsics[record.ExtrinsicIndex]
+			if !extrinsic.IsSigned() {
+				continue
+			}
+			signer, err := signerAccount(extrinsic.Signature.Signer)
+			if err != nil {
+				continue
+			}
+			fee := extrinsicFee(eventsForExtrinsic(events, index), signer)
+			if fee == nil {
+				continue
+			}
+			record.Kind = RecordFee
+			record.Accounts = []string{hex.EncodeToString(signer)}
+			record.Amount = fee
+			record.Success = e.Method() == "System.ExtrinsicSuccess"
+		default:
+			for i, field := range e.Fields {
+				if strings.Contains(field.TypeName, "AccountId") {
+					if account := e.AccountField(i); account != nil {
+						record.Accounts = append(record.Accounts, hex.EncodeToString(account))
+					}
+				} else if strings.Contains(field.TypeName, "Balance") && record.Amount == nil {
+					record.Amount = e.BalanceField(i)
+				}
+			}
+			if len(record.Accounts) == 0 {
+				continue
+			}
+			record.Kind = RecordEvent
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// put stores the records of a block and moves the checkpoint to it, in one transaction.
+func (ix *Index) put(block BlockRef, timestamp time.Time, records []IndexRecord) error {
+	return ix.db.Update(func(tx *bolt.Tx) error {
+		for _, record := range records {
+			key := recordKey(record.BlockNumber, record.EventIndex)
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(recordsBucket).Put(key, data); err != nil {
+				return err
+			}
+			for _, account := range record.Accounts {
+				pubKey, err := hex.DecodeString(account)
+				if err != nil {
+					return err
+				}
+				if err := tx.Bucket(accountsBucket).Put(append(pubKey, key...), []byte{}); err != nil {
+					return err
+				}
+			}
+			if record.ExtrinsicHash != "" {
+				hash, err := hex.DecodeString(record.ExtrinsicHash)
+				if err != nil {
+					return err
+				}
+				if err := tx.Bucket(extrinsicsBucket).Put(append(hash, key...), []byte{}); err != nil {
+					return err
+				}
+			}
+		}
+
+		timeKey := make([]byte, 16)
+		binary.BigEndian.PutUint64(timeKey, uint64(timestamp.UnixMilli()))
+		binary.BigEndian.PutUint64(timeKey[8:], block.Number)
+		if err := tx.Bucket(timesBucket).Put(timeKey, []byte{}); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(block)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Put(checkpointKey, data)
+	})
+}
+
+// recordKey is the block number followed by the event index, so that records sort in chain order.
+func recordKey(blockNumber uint64, eventIndex uint32) []byte {
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint64(key, blockNumber)
+	binary.BigEndian.PutUint32(key[8:], eventIndex)
+	return key
+}
+
+// Account returns the records involving the account with the given SS58 address, in chain order.
+func (ix *Index) Account(address string) ([]IndexRecord, error) {
+	pubKey, err := PublicKeyFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return ix.AccountByPublicKey(pubKey)
+}
+
+// AccountByPublicKey returns the records involving the account with the given public key, in chain order.
+func (ix *Index) AccountByPublicKey(pubKey []byte) ([]IndexRecord, error) {
+	return ix.lookup(accountsBucket, pubKey)
+}
+
+// Extrinsic returns the records of the extrinsic with the given hash. An extrinsic hash is not unique - an
+// extrinsic can be repeated once its nonce allows - so the records of all extrinsics with the hash are returned.
+func (ix *Index) Extrinsic(hash types.Hash) ([]IndexRecord, error) {
+	return ix.lookup(extrinsicsBucket, hash[:])
+}
+
+// BlockRange returns the records in blocks from to to, inclusive.
+func (ix *Index) BlockRange(from, to uint64) ([]IndexRecord, error) {
+	records := []IndexRecord{}
+	err := ix.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(recordsBucket).Cursor()
+		end := recordKey(to, ^uint32(0))
+		for k, v := c.Seek(recordKey(from, 0)); k != nil && bytes.Compare(k, end) <= 0; k, v = c.Next() {
+			var record IndexRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("invalid record %x: %w", k, err)
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// TimeRange returns the records in blocks with timestamps from from to to, inclusive.
+func (ix *Index) TimeRange(from, to time.Time) ([]IndexRecord, error) {
+	first, last, ok, err := ix.blocksInTimeRange(from, to)
+	if err != nil || !ok {
+		return []IndexRecord{}, err
+	}
+	return ix.BlockRange(first, last)
+}
+
+// blocksInTimeRange returns the first and last indexed blocks with timestamps in the range. Timestamps increase
+// with block number, so the blocks in between are in the range too.
+func (ix *Index) blocksInTimeRange(from, to time.Time) (first, last uint64, ok bool, err error) {
+	err = ix.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(timesBucket).Cursor()
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, uint64(from.UnixMilli()))
+		for k, _ := c.Seek(start); k != nil && int64(binary.BigEndian.Uint64(k)) <= to.UnixMilli(); k, _ = c.Next() {
+			number := binary.BigEndian.Uint64(k[8:])
+			if !ok {
+				first, ok = number, true
+			}
+			last = number
+		}
+		return nil
+	})
+	return first, last, ok, err
+}
+
+// lookup returns the records whose keys follow prefix in the given bucket.
+func (ix *Index) lookup(bucket, prefix []byte) ([]IndexRecord, error) {
+	records := []IndexRecord{}
+	err := ix.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			key := k[len(prefix):]
+			data := tx.Bucket(recordsBucket).Get(key)
+			if data == nil {
+				return fmt.Errorf("missing record %x", key)
+			}
+			var record IndexRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return fmt.Errorf("invalid record %x: %w", key, err)
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
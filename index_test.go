@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testIndexBlock builds a block with a timestamp inherent and a transfer signed by signer, and its events.
+func testIndexBlock(number uint64, signer, receiver []byte) (*types.SignedBlock, []Event) {
+	signed := types.Extrinsic{
+		Version: types.ExtrinsicVersion4 | types.ExtrinsicBitSigned,
+		Method:  types.Call{CallIndex: types.CallIndex{SectionIndex: 5, MethodIndex: 0}, Args: []byte{byte(number)}},
+	}
+	signed.Signature.Signer = types.MultiAddress{IsID: true, AsID: types.NewAccountID(signer)}
+	block := &types.SignedBlock{}
+	block.Block.Header.Number = types.BlockNumber(number)
+	block.Block.Extrinsics = []types.Extrinsic{{Version: types.ExtrinsicVersion4}, signed}
+
+	typed := func(e Event, typeNames ...string) Event {
+		for i := range e.Fields {
+			e.Fields[i].TypeName = typeNames[i]
+		}
+		return e
+	}
+	events := []Event{
+		testEvent(0, "System.ExtrinsicSuccess"),
+		typed(testEvent(1, "Balances.Withdraw", signer, big.NewInt(150)), "T::AccountId", "T::Balance"),
+		typed(testEvent(1, "Balances.Transfer", signer, receiver, big.NewInt(1000)), "T::AccountId", "T::AccountId", "T::Balance"),
+		typed(testEvent(1, "TransactionPayment.TransactionFeePaid", signer, big.NewInt(150), big.NewInt(10)), "T::AccountId", "BalanceOf<T>", "BalanceOf<T>"),
+		testEvent(1, "System.ExtrinsicSuccess"),
+		typed(Event{Pallet: "Staking", Name: "Rewarded", Fields: []EventField{{Value: receiver}, {Value: big.NewInt(7)}}, Phase: types.Phase{IsFinalization: true}}, "T::AccountId", "BalanceOf<T>"),
+		typed(Event{Pallet: "System", Name: "Remarked", Fields: []EventField{{Value: bytes.Repeat([]byte{9}, 32)}}, Phase: types.Phase{IsFinalization: true}}, "T::Hash"),
+	}
+	for i := range events {
+		events[i].Index = uint32(i)
+	}
+	return block, events
+}
+
+func TestBlockIndexRecords(t *testing.T) {
+	signer := bytes.Repeat([]byte{1}, 32)
+	receiver := bytes.Repeat([]byte{2}, 32)
+	block, events := testIndexBlock(10, signer, receiver)
+	timestamp := time.Unix(1600000000, 0)
+
+	records, err := blockIndexRecords(block, types.NewHash([]byte{10}), timestamp, events)
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 5) {
+		return
+	}
+
+	withdraw, transfer, feePaid, fee, reward := records[0], records[1], records[2], records[3], records[4]
+	assert.Equal(t, RecordEvent, withdraw.Kind)
+	assert.Equal(t, []string{hex.EncodeToString(signer)}, withdraw.Accounts)
+	assert.Equal(t, big.NewInt(150), withdraw.Amount)
+
+	assert.Equal(t, RecordTransfer, transfer.Kind)
+	assert.Equal(t, hex.EncodeToString(signer), transfer.From)
+	assert.Equal(t, hex.EncodeToString(receiver), transfer.To)
+	assert.Equal(t, big.NewInt(1000), transfer.Amount)
+	assert.Equal(t, 1, transfer.ExtrinsicIndex)
+	hash, err := types.GetHash(block.Block.Extrinsics[1])
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(hash[:]), transfer.ExtrinsicHash)
+	assert.Equal(t, uint32(2), transfer.EventIndex)
+
+	assert.Equal(t, "TransactionPayment.TransactionFeePaid", feePaid.Method)
+	assert.Equal(t, RecordFee, fee.Kind)
+	assert.Equal(t, big.NewInt(150), fee.Amount)
+	assert.Equal(t, uint32(4), fee.EventIndex)
+	assert.True(t, fee.Success)
+
+	// Events outside extrinsics, such as staking rewards, are recorded too. Hashes are not accounts.
+	assert.Equal(t, "Staking.Rewarded", reward.Method)
+	assert.Equal(t, -1, reward.ExtrinsicIndex)
+	assert.Empty(t, reward.ExtrinsicHash)
+	assert.Equal(t, []string{hex.EncodeToString(receiver)}, reward.Accounts)
+}
+
+func TestIndex(t *testing.T) {
+	signer := bytes.Repeat([]byte{1}, 32)
+	receiver := bytes.Repeat([]byte{2}, 32)
+	path := filepath.Join(t.TempDir(), "index.db")
+	ix, err := OpenIndex(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, ok, err := ix.Checkpoint()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	start := time.Unix(1600000000, 0)
+	for number := uint64(10); number < 13; number++ {
+		hash := types.NewHash([]byte{byte(number)})
+		block, events := testIndexBlock(number, signer, receiver)
+		timestamp := start.Add(time.Duration(number-10) * 6 * time.Second)
+		records, err := blockIndexRecords(block, hash, timestamp, events)
+		assert.NoError(t, err)
+		assert.NoError(t, ix.put(BlockRef{Number: number, Hash: hash}, timestamp, records))
+	}
+	assert.NoError(t, ix.Close())
+
+	// The index is persistent.
+	ix, err = OpenIndex(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ix.Close()
+	checkpoint, ok, err := ix.Checkpoint()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, BlockRef{Number: 12, Hash: types.NewHash([]byte{12})}, checkpoint)
+
+	// The signer has a withdraw, transfer, fee paid and fee record in each block, the receiver a transfer and reward.
+	records, err := ix.AccountByPublicKey(signer)
+	assert.NoError(t, err)
+	assert.Len(t, records, 12)
+	records, err = ix.AccountByPublicKey(receiver)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 6) {
+		assert.Equal(t, uint64(10), records[0].BlockNumber)
+		assert.Equal(t, RecordTransfer, records[0].Kind)
+		assert.Equal(t, uint64(12), records[5].BlockNumber)
+	}
+
+	records, err = ix.BlockRange(11, 11)
+	assert.NoError(t, err)
+	assert.Len(t, records, 5)
+	records, err = ix.BlockRange(11, 100)
+	assert.NoError(t, err)
+	assert.Len(t, records, 10)
+
+	records, err = ix.TimeRange(start.Add(time.Second), start.Add(12*time.Second))
+	assert.NoError(t, err)
+	if assert.Len(t, records, 10) {
+		assert.Equal(t, uint64(11), records[0].BlockNumber)
+	}
+	records, err = ix.TimeRange(start.Add(time.Hour), start.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	block, _ := testIndexBlock(11, signer, receiver)
+	hash, err := types.GetHash(block.Block.Extrinsics[1])
+	assert.NoError(t, err)
+	records, err = ix.Extrinsic(hash)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 4) {
+		for _, record := range records {
+			assert.Equal(t, uint64(11), record.BlockNumber)
+		}
+	}
+}
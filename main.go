@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 )
@@ -28,6 +29,13 @@ func main() {
 	n := MyStruct{"David", 1}
 	_ = n
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	// `polka-connect <command> args...` runs a command - see commands.go.
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	// For local dev testnet use NewDefaultConnection()
 	//	nc, err := NewConnection("wss://westend-rpc.polkadot.io")
 	//	nc, err := NewConnection("wss://rpc.pinknode.io/westend/explorer")
@@ -47,7 +55,7 @@ func main() {
 
 	fmt.Println("sender: ", sender.Address)
 
-	//	if err := nc.Transfer(sender, WestendRecipient, dotToPlank(1)); err != nil {
+	//	if _, err := nc.Transfer(sender, WestendRecipient, dotToPlank(1), TransferOptions{}); err != nil {
 	//		log.Fatal(err)
 	//	}
 
@@ -58,7 +66,7 @@ func main() {
 	}
 	for _, result := range results {
 
-		fmt.Printf("ID: %s\nBlock: %#x\nAmount: %s\n", result.ID, result.blockHash, result.amountAtThisBlock.String())
+		fmt.Printf("ID: %s\nBlock: %#x\nAmount: %s\n", result.ID, result.BlockHash, result.AmountAtThisBlock.String())
 
 		//				fmt.Println("blockHash: ", result.Block.Hex())
 		//				for _, change := range result.Changes {
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+// DefaultMempoolInterval is how often a MempoolWatcher polls the transaction pool - about a third of a block.
+const DefaultMempoolInterval = 2 * time.Second
+
+// Statuses of a PendingTransfer.
+const (
+	PendingStatusPending  = "pending"
+	PendingStatusIncluded = "included"
+	PendingStatusDropped  = "dropped"
+)
+
+// PendingTransfer is a transfer to a watched account in an extrinsic in the node's transaction pool. It is not a
+// deposit - the extrinsic may never be included, and if it is, the transfer may fail. Credit deposits only from a
+// DepositEngine.
+type PendingTransfer struct {
+	Hash string `json:"hash"` // Hash of the Extrinsic
+	// Signer is the hex public key of the signer, From the hex public key of the account the transfer is from - they
+	// differ for transfers made through proxy or multisig calls.
+	Signer string   `json:"signer"`
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Amount *big.Int `json:"amount"` // Nil for Balances.transfer_all
+	Nonce  uint64   `json:"nonce,string"`
+	Tip    *big.Int `json:"tip"`
+	Call   string   `json:"call"`           // The transfer call, e.g. Balances.transfer_keep_alive
+	Path   string   `json:"path,omitempty"` // Location of the transfer in the call tree, as for TxEvent
+	Status string   `json:"status"`
+	// FirstSeen is when the extrinsic was first seen in the pool.
+	FirstSeen time.Time `json:"first_seen"`
+	// BlockHash and BlockHeight are set when the extrinsic is included.
+	BlockHash   string `json:"block_hash,omitempty"`
+	BlockHeight uint64 `json:"block_height,string,omitempty"`
+}
+
+func (p PendingTransfer) String() string {
+	return fmt.Sprintf("Hash: %s\nStatus: %s\nFrom: %s\nTo: %s\nAmount: %s\nNonce: %d\nCall: %s\nPath: %s\nFirstSeen: %s\nBlockHash: %s\nBlockHeight: %d\n",
+		p.Hash,
+		p.Status,
+		p.From,
+		p.To,
+		p.Amount,
+		p.Nonce,
+		p.Call,
+		p.Path,
+		p.FirstSeen.String(),
+		p.BlockHash,
+		p.BlockHeight,
+	)
+}
+
+// MempoolOptions controls how often a MempoolWatcher polls the pool.
+type MempoolOptions struct {
+	// Interval is the time between polls of author_pendingExtrinsics. Zero selects DefaultMempoolInterval.
+	Interval time.Duration
+}
+
+// MempoolWatcher reports transfers to watched accounts while their extrinsics are in the node's transaction pool,
+// including transfers nested in batch, proxy and multisig calls. Nodes have no subscription to the pool, so it is
+// polled. Each extrinsic is decoded once, when it first appears, with the metadata of the runtime at that poll. An
+// extrinsic that leaves the pool is looked for in the blocks imported since it was first seen - if found it was
+// included, otherwise it was dropped, e.g. as invalid, outdated or replaced by another with the same nonce.
+type MempoolWatcher struct {
+	// OnPending is called for each transfer to a watched account in an extrinsic that enters the pool.
+	OnPending func(PendingTransfer) error
+	// OnIncluded is called for a pending transfer when its extrinsic is included in a block on the best chain. The
+	// block may yet be retracted, and the transfer may have failed - the DepositEngine reports the outcome.
+	OnIncluded func(PendingTransfer) error
+	// OnDropped is called for a pending transfer when its extrinsic leaves the pool without being included.
+	OnDropped func(PendingTransfer) error
+	// OnDecodeError is called with the hex hash of an extrinsic in the pool that can't be decoded. The extrinsic is
+	// skipped unless it returns an error, which stops the watcher.
+	OnDecodeError func(hash string, err error) error
+
+	watched *WatchSet
+	opts    MempoolOptions
+
+	// pendingExtrinsics returns the SCALE encoded extrinsics in the pool.
+	pendingExtrinsics func() ([][]byte, error)
+	metadata          func() (*types.Metadata, error)
+	bestHeight        func() (uint64, error)
+	blockAt           func(number uint64) (types.Hash, *types.SignedBlock, error)
+
+	// pool maps the hashes of the extrinsics in the pool at the last poll to their transfers to watched accounts.
+	// Extrinsics without any are kept too, so that they aren't decoded again.
+	pool map[types.Hash]pooledExtrinsic
+	// best is the number of the best block at the last poll.
+	best uint64
+}
+
+// pooledExtrinsic is an extrinsic seen in the pool.
+type pooledExtrinsic struct {
+	transfers []PendingTransfer
+	// since is the best block before the extrinsic was first seen. The pool is updated after a block is imported,
+	// so the extrinsic may be in a block after it that was imported while it was still listed.
+	since uint64
+}
+
+// NewMempoolWatcher returns a MempoolWatcher for transfers to the watched accounts.
+func (c *Connection) NewMempoolWatcher(watched *WatchSet, opts MempoolOptions) *MempoolWatcher {
+	if opts.Interval == 0 {
+		opts.Interval = DefaultMempoolInterval
+	}
+	var meta *types.Metadata
+	var specVersion types.U32
+	return &MempoolWatcher{
+		watched: watched,
+		opts:    opts,
+		// The extrinsics are decoded with the metadata rather than as types.Extrinsic, which can't decode
+		// signatures or signed extensions other than those it was written for.
+		pendingExtrinsics: func() ([][]byte, error) {
+			var pending []string
+			if err := c.Api.Client.Call(&pending, "author_pendingExtrinsics"); err != nil {
+				return nil, err
+			}
+			extrinsics := make([][]byte, len(pending))
+			for i, encoded := range pending {
+				extrinsic, err := types.HexDecodeString(encoded)
+				if err != nil {
+					return nil, fmt.Errorf("problem decoding pending extrinsic %s: %w", encoded, err)
+				}
+				extrinsics[i] = extrinsic
+			}
+			return extrinsics, nil
+		},
+		// The metadata is only fetched again after a runtime upgrade.
+		metadata: func() (*types.Metadata, error) {
+			version, err := c.Api.RPC.State.GetRuntimeVersionLatest()
+			if err != nil {
+				return nil, fmt.Errorf("problem getting latest version of runtime: %w", err)
+			}
+			if meta == nil || version.SpecVersion != specVersion {
+				if meta, err = c.getLatestMetadata(); err != nil {
+					return nil, fmt.Errorf("can't get meta for api: %w", err)
+				}
+				specVersion = version.SpecVersion
+			}
+			return meta, nil
+		},
+		bestHeight: c.ChainHeight,
+		blockAt: func(number uint64) (types.Hash, *types.SignedBlock, error) {
+			hash, err := c.Api.RPC.Chain.GetBlockHash(number)
+			if err != nil {
+				return types.Hash{}, nil, fmt.Errorf("GetBlockHash failed for block %d: %w", number, err)
+			}
+			block, err := c.GetBlockByHash(hash)
+			if err != nil {
+				return types.Hash{}, nil, fmt.Errorf("error getting block for hash %#x: %w", hash, err)
+			}
+			return hash, block, nil
+		},
+		pool: map[types.Hash]pooledExtrinsic{},
+	}
+}
+
+// Run polls the transaction pool until stop is closed or an error occurs.
+func (w *MempoolWatcher) Run(stop <-chan struct{}) error {
+	best, err := w.bestHeight()
+	if err != nil {
+		return err
+	}
+	w.best = best
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.poll(time.Now()); err != nil {
+			return err
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the pool, reports the transfers in extrinsics that entered it, and works out whether those that
+// left it were included or dropped.
+func (w *MempoolWatcher) poll(now time.Time) error {
+	extrinsics, err := w.pendingExtrinsics()
+	if err != nil {
+		return fmt.Errorf("error getting pending extrinsics: %w", err)
+	}
+	// The best block is read after the pool, so any extrinsic that has left the pool by inclusion is in a block up
+	// to it.
+	best, err := w.bestHeight()
+	if err != nil {
+		return err
+	}
+
+	// The metadata is read for the first new extrinsic, once per poll.
+	var meta *types.Metadata
+	pool := map[types.Hash]pooledExtrinsic{}
+	for _, extrinsic := range extrinsics {
+		hash := types.Hash(blake2b.Sum256(extrinsic))
+		if pooled, ok := w.pool[hash]; ok {
+			pool[hash] = pooled
+			continue
+		}
+		if meta == nil && w.watched.Len() > 0 {
+			if meta, err = w.metadata(); err != nil {
+				return err
+			}
+		}
+		transfers, err := w.pendingTransfers(meta, extrinsic, best, now)
+		if err != nil {
+			return err
+		}
+		pool[hash] = pooledExtrinsic{transfers: transfers, since: w.best}
+		for _, transfer := range transfers {
+			if err := callPendingTransfer(w.OnPending, transfer); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Extrinsics that left the pool are looked for in every block since the first was seen.
+	left := map[types.Hash][]PendingTransfer{}
+	from := best
+	for hash, pooled := range w.pool {
+		if _, ok := pool[hash]; !ok && len(pooled.transfers) > 0 {
+			left[hash] = pooled.transfers
+			if pooled.since < from {
+				from = pooled.since
+			}
+		}
+	}
+	for n := from + 1; n <= best && len(left) > 0; n++ {
+		blockHash, block, err := w.blockAt(n)
+		if err != nil {
+			return err
+		}
+		for _, extrinsic := range block.Block.Extrinsics {
+			hash, err := types.GetHash(extrinsic)
+			if err != nil {
+				return fmt.Errorf("problem getting extrinsic hash: %w", err)
+			}
+			for _, transfer := range left[hash] {
+				transfer.Status = PendingStatusIncluded
+				transfer.BlockHash = hex.EncodeToString(blockHash[:])
+				transfer.BlockHeight = n
+				if err := callPendingTransfer(w.OnIncluded, transfer); err != nil {
+					return err
+				}
+			}
+			delete(left, hash)
+		}
+	}
+	for _, transfers := range left {
+		for _, transfer := range transfers {
+			transfer.Status = PendingStatusDropped
+			if err := callPendingTransfer(w.OnDropped, transfer); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.pool = pool
+	if best > w.best {
+		w.best = best
+	}
+	return nil
+}
+
+// pendingTransfers returns the transfers to watched accounts in a SCALE encoded extrinsic. A nil meta means no
+// accounts are watched.
+func (w *MempoolWatcher) pendingTransfers(meta *types.Metadata, extrinsic []byte, best uint64, now time.Time) ([]PendingTransfer, error) {
+	if meta == nil {
+		return nil, nil
+	}
+	d, err := decodeExtrinsic(meta, extrinsic, best, 0)
+	if err == nil && d.Signed {
+		var calls []CallTransfer
+		if calls, err = callTransfers(d.call, d.signer); err == nil {
+			return w.watchedTransfers(d, calls, now), nil
+		}
+	}
+	if err != nil && w.OnDecodeError != nil {
+		hash := blake2b.Sum256(extrinsic)
+		return nil, w.OnDecodeError(hex.EncodeToString(hash[:]), err)
+	}
+	return nil, nil
+}
+
+// watchedTransfers returns the calls of a decoded extrinsic that transfer to watched accounts.
+func (w *MempoolWatcher) watchedTransfers(d *DecodedExtrinsic, calls []CallTransfer, now time.Time) []PendingTransfer {
+	transfers := []PendingTransfer{}
+	for _, call := range calls {
+		if !w.watched.Contains(call.To) {
+			continue
+		}
+		transfer := PendingTransfer{
+			Hash:      d.Hash,
+			Signer:    hex.EncodeToString(d.signer),
+			From:      hex.EncodeToString(call.From),
+			To:        hex.EncodeToString(call.To),
+			Amount:    call.Amount,
+			Tip:       big.NewInt(0),
+			Call:      call.Call,
+			Path:      call.Path,
+			Status:    PendingStatusPending,
+			FirstSeen: now,
+		}
+		if d.Nonce != nil {
+			transfer.Nonce = d.Nonce.Uint64()
+		}
+		if d.Tip != nil {
+			transfer.Tip.Set(d.Tip)
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers
+}
+
+func callPendingTransfer(f func(PendingTransfer) error, transfer PendingTransfer) error {
+	if f == nil {
+		return nil
+	}
+	return f(transfer)
+}
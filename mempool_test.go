@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestMempoolWatcher(t *testing.T) {
+	meta := testMetadata(t)
+	watched := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	sender := bytes.Repeat([]byte{3}, 32)
+
+	signed := func(nonce uint64, call types.Call) types.Extrinsic {
+		extrinsic := types.NewExtrinsic(call)
+		extrinsic.Version |= types.ExtrinsicBitSigned
+		extrinsic.Signature.Signer = types.NewMultiAddressFromAccountID(sender)
+		extrinsic.Signature.Signature = types.MultiSignature{IsSr25519: true}
+		extrinsic.Signature.Era = types.ExtrinsicEra{IsImmortalEra: true}
+		extrinsic.Signature.Nonce = types.NewUCompactFromUInt(nonce)
+		return extrinsic
+	}
+	transfer := func(to []byte, amount uint64) types.Call {
+		call, err := types.NewCall(meta, "Balances.transfer", types.NewMultiAddressFromAccountID(to), types.NewUCompactFromUInt(amount))
+		assert.NoError(t, err)
+		return call
+	}
+	batch, err := NewDynamicCall(meta, "Utility.batch", CallArgs{"calls": []types.Call{transfer(other, 5), transfer(watched, 10)}})
+	assert.NoError(t, err)
+	remark, err := NewDynamicCall(meta, "System.remark", CallArgs{"remark": []byte("hello")})
+	assert.NoError(t, err)
+
+	inBatch := signed(1, batch)
+	direct := signed(2, transfer(watched, 20))
+	unrelated := signed(3, remark)
+	later := signed(4, transfer(watched, 30))
+
+	encode := func(extrinsics ...types.Extrinsic) [][]byte {
+		encoded := make([][]byte, len(extrinsics))
+		for i, extrinsic := range extrinsics {
+			var err error
+			encoded[i], err = types.EncodeToBytes(extrinsic)
+			assert.NoError(t, err)
+		}
+		return encoded
+	}
+	// A System.remark claiming more bytes than it has.
+	undecodable := []byte{4, remark.CallIndex.SectionIndex, remark.CallIndex.MethodIndex, 0x13, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	undecodableHash := blake2b.Sum256(undecodable)
+
+	watchSet := NewWatchSet()
+	assert.NoError(t, watchSet.AddPublicKey(watched))
+	pool := append(encode(inBatch, direct, unrelated), undecodable)
+	best := uint64(10)
+	blocks := map[uint64]*types.SignedBlock{}
+	metadataReads := 0
+	reported := []string{}
+	report := func(p PendingTransfer) error {
+		reported = append(reported, fmt.Sprintf("%s %s %s %d %s", p.Status, p.Amount, p.Path, p.BlockHeight, p.Hash[:4]))
+		return nil
+	}
+	w := &MempoolWatcher{
+		OnPending:  report,
+		OnIncluded: report,
+		OnDropped:  report,
+		OnDecodeError: func(hash string, err error) error {
+			reported = append(reported, "undecodable "+hash[:4])
+			return nil
+		},
+		watched:           watchSet,
+		pendingExtrinsics: func() ([][]byte, error) { return pool, nil },
+		metadata:          func() (*types.Metadata, error) { metadataReads++; return meta, nil },
+		bestHeight:        func() (uint64, error) { return best, nil },
+		blockAt: func(number uint64) (types.Hash, *types.SignedBlock, error) {
+			block, ok := blocks[number]
+			if !ok {
+				block = &types.SignedBlock{}
+			}
+			return types.Hash{byte(number)}, block, nil
+		},
+		pool: map[types.Hash]pooledExtrinsic{},
+		best: best,
+	}
+	hashOf := func(extrinsic types.Extrinsic) string {
+		hash, err := types.GetHash(extrinsic)
+		assert.NoError(t, err)
+		return hex.EncodeToString(hash[:])[:4]
+	}
+
+	now := time.Unix(1000, 0)
+	assert.NoError(t, w.poll(now))
+	assert.ElementsMatch(t, []string{
+		"pending 10 Utility.batch/calls[1]/Balances.transfer 0 " + hashOf(inBatch),
+		"pending 20 Balances.transfer 0 " + hashOf(direct),
+		"undecodable " + hex.EncodeToString(undecodableHash[:])[:4],
+	}, reported)
+	// The metadata is read once for the poll, not for each new extrinsic.
+	assert.Equal(t, 1, metadataReads)
+
+	// The batch is included in block 12 and the direct transfer replaced by one with another nonce.
+	reported = nil
+	block := &types.SignedBlock{}
+	block.Block.Extrinsics = []types.Extrinsic{{Version: types.ExtrinsicVersion4}, inBatch}
+	blocks[12] = block
+	best = 12
+	pool = encode(unrelated, later)
+	assert.NoError(t, w.poll(now.Add(time.Second)))
+	assert.ElementsMatch(t, []string{
+		"pending 30 Balances.transfer 0 " + hashOf(later),
+		"included 10 Utility.batch/calls[1]/Balances.transfer 12 " + hashOf(inBatch),
+		"dropped 20 Balances.transfer 0 " + hashOf(direct),
+	}, reported)
+	assert.Equal(t, 2, metadataReads)
+	assert.Equal(t, uint64(12), w.best)
+
+	// Extrinsics still in the pool are not reported or decoded again.
+	reported = nil
+	assert.NoError(t, w.poll(now.Add(2*time.Second)))
+	assert.Empty(t, reported)
+	assert.Equal(t, 2, metadataReads)
+
+	laterHash, err := types.GetHash(later)
+	assert.NoError(t, err)
+	if !assert.Len(t, w.pool[laterHash].transfers, 1) {
+		return
+	}
+	p := w.pool[laterHash].transfers[0]
+	assert.Equal(t, hex.EncodeToString(sender), p.Signer)
+	assert.Equal(t, hex.EncodeToString(sender), p.From)
+	assert.Equal(t, hex.EncodeToString(watched), p.To)
+	assert.Equal(t, uint64(4), p.Nonce)
+	assert.Equal(t, 0, p.Tip.Sign())
+	assert.Equal(t, now.Add(time.Second), p.FirstSeen)
+
+	// The pool still lists an extrinsic at the poll that sees the block including it, and it leaves at the next.
+	block = &types.SignedBlock{}
+	block.Block.Extrinsics = []types.Extrinsic{later}
+	blocks[13] = block
+	best = 13
+	assert.NoError(t, w.poll(now.Add(3*time.Second)))
+	assert.Empty(t, reported)
+	pool = encode(unrelated)
+	assert.NoError(t, w.poll(now.Add(4*time.Second)))
+	assert.Equal(t, []string{"included 30 Balances.transfer 13 " + hashOf(later)}, reported)
+
+	// An error from OnDecodeError stops the watcher.
+	w.OnDecodeError = func(hash string, err error) error { return err }
+	pool = [][]byte{undecodable}
+	assert.Error(t, w.poll(now.Add(5*time.Second)))
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// AccountNextIndex returns the next nonce for the account via system_accountNextIndex. Unlike the nonce held in
+// System.Account storage, this accounts for transactions from the account that are waiting in the node's pool.
+func (c *Connection) AccountNextIndex(publicKey []byte) (uint64, error) {
+	address, err := c.SS58Address(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	var next uint64
+	if err := c.Api.Client.Call(&next, "system_accountNextIndex", address); err != nil {
+		return 0, fmt.Errorf("system_accountNextIndex failed for %s: %w", address, err)
+	}
+	return next, nil
+}
+
+// NonceState is the persisted nonce state of an account. InFlight is informational - after a restart nobody is
+// left to complete or release those nonces, so any that the node hasn't used are reissued.
+type NonceState struct {
+	Next     uint64   `json:"next"`
+	InFlight []uint64 `json:"in_flight"`
+}
+
+// NonceStore persists nonce state so that a NonceManager can resume after a restart. Accounts are keyed by the
+// hex encoded public key.
+type NonceStore interface {
+	Load(account string) (state NonceState, ok bool, err error)
+	Save(account string, state NonceState) error
+}
+
+// NonceManager allocates nonces for accounts that submit many transactions concurrently. Reading the nonce from
+// chain state for each transaction gives the same nonce to every transaction sent before the first is included;
+// the manager instead hands out consecutive nonces and tracks those in flight.
+//
+// A nonce returned by Next must be passed back to either Complete, once the transaction has been accepted, or
+// Release, if it never reached the transaction pool. Released nonces leave a gap that would block every later
+// transaction, so they are reissued before new nonces are allocated. Transfer does this when given a manager in
+// TransferOptions.Nonces; other submissions can use Settle:
+//
+//	nonce, err := nm.Next(sender.PublicKey)
+//	signed, err := c.SignCall(sender, call, TxOptions{Nonce: &nonce})
+//	receipt, err := c.SubmitAndTrack(signed.Extrinsic, TrackOptions{})
+//	nm.Settle(sender.PublicKey, nonce, err)
+type NonceManager struct {
+	fetch func(publicKey []byte) (uint64, error)
+	store NonceStore
+
+	mu       sync.Mutex
+	accounts map[string]*accountNonces
+}
+
+type accountNonces struct {
+	mu       sync.Mutex
+	synced   bool
+	next     uint64
+	inFlight map[uint64]bool
+	free     map[uint64]bool
+}
+
+// NewNonceManager returns a nonce manager that syncs against the connected node. The store is optional - pass
+// nil to keep nonce state in memory only.
+func NewNonceManager(c *Connection, store NonceStore) *NonceManager {
+	return &NonceManager{
+		fetch:    c.AccountNextIndex,
+		store:    store,
+		accounts: make(map[string]*accountNonces),
+	}
+}
+
+func (m *NonceManager) account(publicKey []byte) *accountNonces {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := hex.EncodeToString(publicKey)
+	a, ok := m.accounts[key]
+	if !ok {
+		a = &accountNonces{inFlight: make(map[uint64]bool), free: make(map[uint64]bool)}
+		m.accounts[key] = a
+	}
+	return a
+}
+
+// Next allocates a nonce for the account. Gaps left by released nonces are filled first.
+func (m *NonceManager) Next(publicKey []byte) (uint64, error) {
+	a := m.account(publicKey)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.synced {
+		if err := m.load(publicKey, a); err != nil {
+			return 0, err
+		}
+		if err := m.resync(publicKey, a); err != nil {
+			return 0, err
+		}
+	}
+
+	var nonce uint64
+	if free := sortedNonces(a.free); len(free) > 0 {
+		nonce = free[0]
+		delete(a.free, nonce)
+	} else {
+		nonce = a.next
+		a.next++
+	}
+	a.inFlight[nonce] = true
+	if err := m.save(publicKey, a); err != nil {
+		delete(a.inFlight, nonce)
+		a.free[nonce] = true
+		return 0, err
+	}
+	return nonce, nil
+}
+
+// Complete marks the transaction with the given nonce as accepted by the network.
+func (m *NonceManager) Complete(publicKey []byte, nonce uint64) error {
+	a := m.account(publicKey)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.inFlight, nonce)
+	return m.save(publicKey, a)
+}
+
+// Release returns a nonce whose transaction failed before reaching the transaction pool, so that it is
+// reissued by the next call to Next.
+func (m *NonceManager) Release(publicKey []byte, nonce uint64) error {
+	a := m.account(publicKey)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.inFlight, nonce)
+	if nonce < a.next {
+		a.free[nonce] = true
+	}
+	return m.save(publicKey, a)
+}
+
+// Settle completes or releases a nonce given the error returned by SubmitAndTrack for its transaction. A
+// transaction that was included, or timed out while being tracked, reached the pool, so its nonce is completed.
+// Otherwise the nonce is released and the account resynced, which forgets the nonce if the node used it after
+// all, e.g. for a usurping transaction.
+func (m *NonceManager) Settle(publicKey []byte, nonce uint64, submitErr error) error {
+	if submitErr == nil || errors.Is(submitErr, ErrTxTimeout) || errors.Is(submitErr, ErrTxFinalityTimeout) {
+		return m.Complete(publicKey, nonce)
+	}
+	if err := m.Release(publicKey, nonce); err != nil {
+		return err
+	}
+	return m.Resync(publicKey)
+}
+
+// Resync reconciles the tracked state with the node. This should be called when the node rejects a
+// transaction because of its nonce (e.g. a stale or future transaction), or after transactions have been dropped
+// from the pool.
+func (m *NonceManager) Resync(publicKey []byte) error {
+	a := m.account(publicKey)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := m.resync(publicKey, a); err != nil {
+		return err
+	}
+	return m.save(publicKey, a)
+}
+
+// InFlight returns the nonces that have been allocated for the account but not yet completed or released.
+func (m *NonceManager) InFlight(publicKey []byte) []uint64 {
+	a := m.account(publicKey)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return sortedNonces(a.inFlight)
+}
+
+// resync fetches the next index from the node. Everything below it has been used, so it is forgotten. Any nonce
+// between the node's next index and the next nonce to be allocated that is not in flight is a gap - the
+// transaction using it was lost - and is queued for reuse.
+func (m *NonceManager) resync(publicKey []byte, a *accountNonces) error {
+	chainNext, err := m.fetch(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to sync nonce: %w", err)
+	}
+
+	for nonce := range a.inFlight {
+		if nonce < chainNext {
+			delete(a.inFlight, nonce)
+		}
+	}
+	for nonce := range a.free {
+		if nonce < chainNext {
+			delete(a.free, nonce)
+		}
+	}
+	if a.next < chainNext {
+		a.next = chainNext
+	}
+	for nonce := chainNext; nonce < a.next; nonce++ {
+		if !a.inFlight[nonce] {
+			a.free[nonce] = true
+		}
+	}
+	a.synced = true
+	return nil
+}
+
+func (m *NonceManager) load(publicKey []byte, a *accountNonces) error {
+	if m.store == nil {
+		return nil
+	}
+	state, ok, err := m.store.Load(hex.EncodeToString(publicKey))
+	if err != nil {
+		return fmt.Errorf("failed to load nonce state: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	// The persisted in-flight nonces are not restored. Those the node has used are below its next index, and the
+	// rest become gaps for resync to reissue.
+	a.next = state.Next
+	return nil
+}
+
+func (m *NonceManager) save(publicKey []byte, a *accountNonces) error {
+	if m.store == nil {
+		return nil
+	}
+	state := NonceState{Next: a.next, InFlight: sortedNonces(a.inFlight)}
+	if err := m.store.Save(hex.EncodeToString(publicKey), state); err != nil {
+		return fmt.Errorf("failed to save nonce state: %w", err)
+	}
+	return nil
+}
+
+func sortedNonces(set map[uint64]bool) []uint64 {
+	nonces := make([]uint64, 0, len(set))
+	for nonce := range set {
+		nonces = append(nonces, nonce)
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	return nonces
+}
+
+// FileNonceStore is a NonceStore that keeps the state of all accounts in a single JSON file.
+type FileNonceStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewFileNonceStore(path string) *FileNonceStore {
+	return &FileNonceStore{Path: path}
+}
+
+func (s *FileNonceStore) Load(account string) (NonceState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states, err := s.read()
+	if err != nil {
+		return NonceState{}, false, err
+	}
+	state, ok := states[account]
+	return state, ok, nil
+}
+
+func (s *FileNonceStore) Save(account string, state NonceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states, err := s.read()
+	if err != nil {
+		return err
+	}
+	states[account] = state
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, data)
+}
+
+// writeFileAtomic writes to a temporary file and renames it over path, so that a crash mid-write can't corrupt
+// the existing file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileNonceStore) read() (map[string]NonceState, error) {
+	states := map[string]NonceState{}
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("invalid nonce state in %s: %w", s.Path, err)
+	}
+	return states, nil
+}
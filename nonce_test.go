@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testNonceManager(chainNext *uint64, store NonceStore) *NonceManager {
+	var mu sync.Mutex
+	return &NonceManager{
+		fetch: func([]byte) (uint64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return *chainNext, nil
+		},
+		store:    store,
+		accounts: make(map[string]*accountNonces),
+	}
+}
+
+func TestNonceManagerConcurrent(t *testing.T) {
+	chainNext := uint64(7)
+	nm := testNonceManager(&chainNext, nil)
+	account := []byte{1, 2, 3}
+
+	const n = 200
+	nonces := make(chan uint64, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := nm.Next(account)
+			assert.NoError(t, err)
+			nonces <- nonce
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+
+	seen := map[uint64]bool{}
+	for nonce := range nonces {
+		assert.False(t, seen[nonce], "nonce %d allocated twice", nonce)
+		seen[nonce] = true
+	}
+	for nonce := uint64(7); nonce < 7+n; nonce++ {
+		assert.True(t, seen[nonce], "nonce %d not allocated", nonce)
+	}
+	assert.Len(t, nm.InFlight(account), n)
+}
+
+func TestNonceManagerReleaseAndResync(t *testing.T) {
+	chainNext := uint64(0)
+	nm := testNonceManager(&chainNext, nil)
+	account := []byte{1}
+
+	for i := uint64(0); i < 4; i++ {
+		nonce, err := nm.Next(account)
+		assert.NoError(t, err)
+		assert.Equal(t, i, nonce)
+	}
+
+	// A failed submission leaves a gap which is filled first.
+	assert.NoError(t, nm.Release(account, 1))
+	nonce, err := nm.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), nonce)
+
+	nonce, err = nm.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), nonce)
+
+	// The node reports 2 as its next index, so 0 and 1 have been included.
+	assert.NoError(t, nm.Complete(account, 0))
+	assert.NoError(t, nm.Complete(account, 3))
+	assert.NoError(t, nm.Complete(account, 4))
+	chainNext = 2
+	assert.NoError(t, nm.Resync(account))
+	assert.Equal(t, []uint64{2}, nm.InFlight(account))
+
+	// 3 and 4 were completed but the node doesn't account for them, so they are treated as gaps.
+	nonce, err = nm.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+}
+
+func TestFileNonceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	store := NewFileNonceStore(path)
+
+	chainNext := uint64(10)
+	nm := testNonceManager(&chainNext, store)
+	account := []byte{0xaa}
+	for i := 0; i < 3; i++ {
+		_, err := nm.Next(account)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, nm.Complete(account, 10))
+
+	state, ok, err := store.Load("aa")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, NonceState{Next: 13, InFlight: []uint64{11, 12}}, state)
+
+	// A new manager resumes from the persisted state. Nobody is left to complete or release the nonces that were
+	// in flight, so those the node hasn't used are reissued.
+	resumed := testNonceManager(&chainNext, NewFileNonceStore(path))
+	for _, expected := range []uint64{10, 11, 12, 13} {
+		nonce, err := resumed.Next(account)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, nonce)
+	}
+
+	// Nonces the node has used since are skipped.
+	chainNext = 12
+	resumed = testNonceManager(&chainNext, NewFileNonceStore(path))
+	nonce, err := resumed.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12), nonce)
+}
+
+type failingNonceStore struct{ fail bool }
+
+func (s *failingNonceStore) Load(string) (NonceState, bool, error) { return NonceState{}, false, nil }
+
+func (s *failingNonceStore) Save(string, NonceState) error {
+	if s.fail {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+func TestNonceManagerSaveFailure(t *testing.T) {
+	chainNext := uint64(5)
+	store := &failingNonceStore{fail: true}
+	nm := testNonceManager(&chainNext, store)
+	account := []byte{1}
+
+	// The nonce isn't handed out, and is reissued once the store recovers.
+	_, err := nm.Next(account)
+	assert.Error(t, err)
+	assert.Empty(t, nm.InFlight(account))
+
+	store.fail = false
+	nonce, err := nm.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), nonce)
+}
+
+func TestNonceManagerSettle(t *testing.T) {
+	chainNext := uint64(0)
+	nm := testNonceManager(&chainNext, nil)
+	account := []byte{1}
+	for i := 0; i < 3; i++ {
+		_, err := nm.Next(account)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, nm.Settle(account, 0, nil))
+	assert.NoError(t, nm.Settle(account, 1, fmt.Errorf("tracking: %w", ErrTxTimeout)))
+	assert.Equal(t, []uint64{2}, nm.InFlight(account))
+
+	// 2 never reached the pool and the node's next index is 2, so it is reissued.
+	chainNext = 2
+	assert.NoError(t, nm.Settle(account, 2, ErrTxInvalid))
+	nonce, err := nm.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), nonce)
+
+	// 3 was usurped by another transaction with the same nonce, so it is forgotten.
+	nonce, err = nm.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+	chainNext = 4
+	assert.NoError(t, nm.Settle(account, 3, ErrTxUsurped))
+	nonce, err = nm.Next(account)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), nonce)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Discrepancy is a block where the free balance computed from events differs from System.Account.
+type Discrepancy struct {
+	BlockHash   string `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number,string"`
+	// Expected is the free balance in System.Account after the block, Computed the balance from the events.
+	Expected *big.Int `json:"expected"`
+	Computed *big.Int `json:"computed"`
+	// Difference is Expected less Computed.
+	Difference *big.Int `json:"difference"`
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("BlockHash: %s\nBlockNumber: %d\nExpected: %s\nComputed: %s\nDifference: %s\n",
+		d.BlockHash,
+		d.BlockNumber,
+		d.Expected,
+		d.Computed,
+		d.Difference,
+	)
+}
+
+// Reconciliation is the result of recomputing the free balance of an account from events.
+type Reconciliation struct {
+	ID string `json:"id"`
+	// Opening is the free balance before the first change block, Closing the free balance in System.Account after
+	// the last.
+	Opening *big.Int `json:"opening"`
+	Closing *big.Int `json:"closing"`
+	// Blocks is the number of change blocks checked.
+	Blocks        int           `json:"blocks"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// OK reports whether the balance computed from events matched System.Account at every change block.
+func (r Reconciliation) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+func (r Reconciliation) String() string {
+	return fmt.Sprintf("ID: %s\nOpening: %s\nClosing: %s\nBlocks: %d\nDiscrepancies: %d\n",
+		r.ID,
+		r.Opening,
+		r.Closing,
+		r.Blocks,
+		len(r.Discrepancies),
+	)
+}
+
+// Reconcile recomputes the free balance of each account, given as hex public keys, by summing the ledger entries
+// of AccountHistory from block checkpoint, and compares it with System.Account at every change block. It needs
+// an archive node.
+func (c *Connection) Reconcile(IDs []string, checkpoint uint64) ([]Reconciliation, error) {
+	return c.historySource().reconcile(IDs, checkpoint)
+}
+
+func (s historySource) reconcile(IDs []string, checkpoint uint64) ([]Reconciliation, error) {
+	reconciliations := []Reconciliation{}
+	for _, ID := range IDs {
+		history, err := s.accountHistory(ID, checkpoint)
+		if err != nil {
+			return nil, fmt.Errorf("error getting history of account %s: %w", ID, err)
+		}
+		reconciliations = append(reconciliations, reconcile(ID, history))
+	}
+	return reconciliations, nil
+}
+
+// reconcile checks the balance computed from the ledger entries against the free balance at each change block.
+// After a discrepancy the computed balance restarts from the actual one, so that each discrepancy is reported at
+// the block it occurred in rather than at every block after it.
+func reconcile(ID string, history []AccountHistoryEntry) Reconciliation {
+	r := Reconciliation{ID: ID, Blocks: len(history), Discrepancies: []Discrepancy{}}
+	if len(history) == 0 {
+		return r
+	}
+	r.Opening = new(big.Int).Sub(history[0].Free, history[0].Delta)
+	r.Closing = history[len(history)-1].Free
+
+	computed := new(big.Int).Set(r.Opening)
+	for _, h := range history {
+		for _, e := range h.Entries {
+			computed.Add(computed, e.Amount)
+		}
+		if computed.Cmp(h.Free) != 0 {
+			r.Discrepancies = append(r.Discrepancies, Discrepancy{
+				BlockHash:   h.BlockHash,
+				BlockNumber: h.BlockNumber,
+				Expected:    h.Free,
+				Computed:    new(big.Int).Set(computed),
+				Difference:  new(big.Int).Sub(h.Free, computed),
+			})
+			computed.Set(h.Free)
+		}
+	}
+	return r
+}
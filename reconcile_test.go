@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	entry := func(amount int64) LedgerEntry {
+		return LedgerEntry{Amount: big.NewInt(amount)}
+	}
+	history := []AccountHistoryEntry{
+		{BlockHash: "0a", BlockNumber: 10, Free: big.NewInt(1400), Delta: big.NewInt(400), Entries: []LedgerEntry{entry(400)}},
+		{BlockHash: "0b", BlockNumber: 11, Free: big.NewInt(300), Delta: big.NewInt(-1100), Entries: []LedgerEntry{entry(-1000), entry(-100)}},
+		// A balance change that no event explains.
+		{BlockHash: "0c", BlockNumber: 12, Free: big.NewInt(350), Delta: big.NewInt(50), Entries: []LedgerEntry{entry(20)}},
+		{BlockHash: "0d", BlockNumber: 13, Free: big.NewInt(340), Delta: big.NewInt(-10), Entries: []LedgerEntry{entry(-10)}},
+	}
+
+	r := reconcile("01", history)
+	assert.False(t, r.OK())
+	assert.Equal(t, big.NewInt(1000), r.Opening)
+	assert.Equal(t, big.NewInt(340), r.Closing)
+	assert.Equal(t, 4, r.Blocks)
+	// Only the block with the unexplained change is reported.
+	if assert.Len(t, r.Discrepancies, 1) {
+		d := r.Discrepancies[0]
+		assert.Equal(t, "0c", d.BlockHash)
+		assert.Equal(t, uint64(12), d.BlockNumber)
+		assert.Equal(t, big.NewInt(350), d.Expected)
+		assert.Equal(t, big.NewInt(320), d.Computed)
+		assert.Equal(t, big.NewInt(30), d.Difference)
+	}
+
+	r = reconcile("01", history[:2])
+	assert.True(t, r.OK())
+	assert.True(t, reconcile("01", nil).OK())
+}
+
+func TestReconcileAccountHistory(t *testing.T) {
+	account := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	ID := types.HexEncodeToString(account)
+
+	blocks := map[types.Hash]*types.SignedBlock{}
+	events := map[types.Hash][]Event{}
+	addBlock := func(hash types.Hash, number uint32, extrinsic types.Extrinsic, blockEvents ...Event) {
+		block := &types.SignedBlock{}
+		block.Block.Header.Number = types.BlockNumber(number)
+		block.Block.Extrinsics = []types.Extrinsic{extrinsic}
+		blocks[hash] = block
+		for i := range blockEvents {
+			blockEvents[i].Index = uint32(i)
+		}
+		events[hash] = blockEvents
+	}
+	signed := types.Extrinsic{Version: types.ExtrinsicVersion4 | types.ExtrinsicBitSigned}
+	signed.Signature.Signer = types.MultiAddress{IsID: true, AsID: types.NewAccountID(account)}
+	unsigned := types.Extrinsic{Version: types.ExtrinsicVersion4}
+
+	// The account receives 400, then sends 1000 paying a fee of 100, then gains 50 of which only 20 is explained.
+	addBlock(types.Hash{1}, 10, unsigned,
+		testEvent(0, "Balances.Transfer", other, account, big.NewInt(400)),
+		testEvent(0, "System.ExtrinsicSuccess"))
+	addBlock(types.Hash{2}, 11, signed,
+		testEvent(0, "Balances.Withdraw", account, big.NewInt(100)),
+		testEvent(0, "Balances.Transfer", account, other, big.NewInt(1000)),
+		testEvent(0, "Treasury.Deposit", big.NewInt(100)),
+		testEvent(0, "System.ExtrinsicSuccess"))
+	addBlock(types.Hash{3}, 12, unsigned,
+		testEvent(0, "Balances.Transfer", other, account, big.NewInt(20)),
+		testEvent(0, "System.ExtrinsicSuccess"))
+	changes := []types.StorageChangeSet{
+		testAccountChange(t, 1, 1400, 0),
+		testAccountChange(t, 2, 300, 0),
+		testAccountChange(t, 3, 350, 0),
+	}
+
+	s := historySource{
+		changeData: func(ID string, checkpoint uint64) ([]ChangeData, error) {
+			return (&Connection{}).changeData(ID, account, changes), nil
+		},
+		freeBalanceBefore: func([]byte, types.Hash) (*big.Int, error) {
+			return big.NewInt(1000), nil
+		},
+		block: func(hash types.Hash) (*types.SignedBlock, error) {
+			return blocks[hash], nil
+		},
+		events: func(hash types.Hash) ([]Event, error) {
+			return events[hash], nil
+		},
+		timestamp: func(block *types.SignedBlock, _ types.Hash) (*time.Time, error) {
+			timestamp := time.Unix(int64(block.Block.Header.Number)*6, 0)
+			return &timestamp, nil
+		},
+	}
+
+	history, err := s.accountHistory(ID, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 3) {
+		for i, h := range history {
+			assert.Equal(t, uint64(10+i), h.BlockNumber)
+		}
+		assert.Equal(t, big.NewInt(-1100), history[1].Delta)
+		assert.Equal(t, 0, history[1].Unexplained.Sign())
+	}
+
+	reconciliations, err := s.reconcile([]string{ID}, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, reconciliations, 1) {
+		r := reconciliations[0]
+		assert.Equal(t, big.NewInt(1000), r.Opening)
+		assert.Equal(t, big.NewInt(350), r.Closing)
+		if assert.Len(t, r.Discrepancies, 1) {
+			assert.Equal(t, uint64(12), r.Discrepancies[0].BlockNumber)
+			assert.Equal(t, big.NewInt(30), r.Discrepancies[0].Difference)
+		}
+	}
+}
+
+func TestReconcileFeePaid(t *testing.T) {
+	account := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	author := bytes.Repeat([]byte{3}, 32)
+	ID := types.HexEncodeToString(account)
+
+	block := &types.SignedBlock{}
+	block.Block.Header.Number = 10
+	signed := types.Extrinsic{Version: types.ExtrinsicVersion4 | types.ExtrinsicBitSigned}
+	signed.Signature.Signer = types.MultiAddress{IsID: true, AsID: types.NewAccountID(account)}
+	block.Block.Extrinsics = []types.Extrinsic{{Version: types.ExtrinsicVersion4}, signed}
+	// The account sends 500 with a tip of 10. 150 is withdrawn for the fee and 50 refunded, so the actual fee,
+	// tip included, is 100.
+	events := []Event{
+		testEvent(0, "System.ExtrinsicSuccess"),
+		testEvent(1, "Balances.Withdraw", account, big.NewInt(150)),
+		testEvent(1, "Balances.Transfer", account, other, big.NewInt(500)),
+		testEvent(1, "Balances.Deposit", account, big.NewInt(50)),
+		testEvent(1, "Balances.Deposit", author, big.NewInt(28)),
+		testEvent(1, "Treasury.Deposit", big.NewInt(72)),
+		testEvent(1, "TransactionPayment.TransactionFeePaid", account, big.NewInt(100), big.NewInt(10)),
+		testEvent(1, "System.ExtrinsicSuccess"),
+	}
+	for i := range events {
+		events[i].Index = uint32(i)
+	}
+	// System.Account after the block: 1000 - 500 - 100.
+	changes := []types.StorageChangeSet{testAccountChange(t, 1, 400, 0)}
+
+	s := historySource{
+		changeData: func(ID string, checkpoint uint64) ([]ChangeData, error) {
+			return (&Connection{}).changeData(ID, account, changes), nil
+		},
+		freeBalanceBefore: func([]byte, types.Hash) (*big.Int, error) {
+			return big.NewInt(1000), nil
+		},
+		block: func(types.Hash) (*types.SignedBlock, error) {
+			return block, nil
+		},
+		events: func(types.Hash) ([]Event, error) {
+			return events, nil
+		},
+		timestamp: func(*types.SignedBlock, types.Hash) (*time.Time, error) {
+			timestamp := time.Unix(60, 0)
+			return &timestamp, nil
+		},
+	}
+
+	reconciliations, err := s.reconcile([]string{ID}, 0)
+	assert.NoError(t, err)
+	if assert.Len(t, reconciliations, 1) {
+		r := reconciliations[0]
+		assert.True(t, r.OK(), r.String())
+		assert.Equal(t, big.NewInt(1000), r.Opening)
+		assert.Equal(t, big.NewInt(400), r.Closing)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// typeRegistry wraps the V14 metadata of a runtime and provides lookups against its PortableRegistry. It is the
+// basis for building and reading SCALE data without knowing the concrete Go types in advance.
+type typeRegistry struct {
+	meta *types.MetadataV14
+	// dispatchErrors is the layout of DispatchError, read from the registry when first needed.
+	dispatchErrors *dispatchErrorLayout
+}
+
+func newTypeRegistry(meta *types.Metadata) (*typeRegistry, error) {
+	if meta == nil || meta.Version != 14 {
+		return nil, fmt.Errorf("type registry requires V14 metadata")
+	}
+	return &typeRegistry{meta: &meta.AsMetadataV14}, nil
+}
+
+// lookup returns the type definition registered against the given type ID.
+func (r *typeRegistry) lookup(id types.Si1LookupTypeID) (*types.Si1Type, error) {
+	typ, ok := r.meta.EfficientLookup[id.Int64()]
+	if !ok {
+		return nil, fmt.Errorf("type %d not found in metadata registry", id.Int64())
+	}
+	return typ, nil
+}
+
+// pallet finds a pallet by name.
+func (r *typeRegistry) pallet(name string) (*types.PalletMetadataV14, error) {
+	for i := range r.meta.Pallets {
+		if string(r.meta.Pallets[i].Name) == name {
+			return &r.meta.Pallets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("pallet %s not found in metadata", name)
+}
+
+// palletByIndex finds a pallet by its index - the first byte of a call index or event ID.
+func (r *typeRegistry) palletByIndex(index uint8) (*types.PalletMetadataV14, error) {
+	for i := range r.meta.Pallets {
+		if uint8(r.meta.Pallets[i].Index) == index {
+			return &r.meta.Pallets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("pallet with index %d not found in metadata", index)
+}
+
+// callVariant returns the pallet and call variant for a call given in the form "Pallet.call_name".
+func (r *typeRegistry) callVariant(call string) (*types.PalletMetadataV14, *types.Si1Variant, error) {
+	s := strings.Split(call, ".")
+	if len(s) != 2 {
+		return nil, nil, fmt.Errorf("call %s should be of the form Pallet.call_name", call)
+	}
+
+	pallet, err := r.pallet(s[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if !pallet.HasCalls {
+		return nil, nil, fmt.Errorf("pallet %s has no calls", s[0])
+	}
+
+	typ, err := r.lookup(pallet.Calls.Type)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range typ.Def.Variant.Variants {
+		if string(typ.Def.Variant.Variants[i].Name) == s[1] {
+			return pallet, &typ.Def.Variant.Variants[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("call %s not found in pallet %s", s[1], s[0])
+}
+
+// variantByIndex returns the variant of an enum type with the given index.
+func (r *typeRegistry) variantByIndex(id types.Si1LookupTypeID, index uint8) (*types.Si1Variant, error) {
+	typ, err := r.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if !typ.Def.IsVariant {
+		return nil, fmt.Errorf("type %d is not an enum", id.Int64())
+	}
+	for i := range typ.Def.Variant.Variants {
+		if uint8(typ.Def.Variant.Variants[i].Index) == index {
+			return &typ.Def.Variant.Variants[i], nil
+		}
+	}
+	return nil, fmt.Errorf("variant %d not found for type %d", index, id.Int64())
+}
+
+// typeName returns the last segment of the type path, e.g. "MultiAddress" for sp_runtime::multiaddress::MultiAddress.
+func typeName(typ *types.Si1Type) string {
+	if len(typ.Path) == 0 {
+		return ""
+	}
+	return string(typ.Path[len(typ.Path)-1])
+}
+
+// constant returns the SCALE encoded value of a pallet constant, e.g. ("System", "BlockWeights").
+func (r *typeRegistry) constant(palletName, name string) ([]byte, error) {
+	pallet, err := r.pallet(palletName)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range pallet.Constants {
+		if string(c.Name) == name {
+			return c.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("constant %s not found in pallet %s", name, palletName)
+}
+
+// typeByPath finds a type by its full path, e.g. ("sp_runtime", "DispatchError").
+func (r *typeRegistry) typeByPath(path ...string) (*types.Si1Type, error) {
+	for _, typ := range r.meta.EfficientLookup {
+		if len(typ.Path) != len(path) {
+			continue
+		}
+		match := true
+		for i := range path {
+			if string(typ.Path[i]) != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return typ, nil
+		}
+	}
+	return nil, fmt.Errorf("type %s not found in metadata registry", strings.Join(path, "::"))
+}
+
+// byteWidth returns the encoded size of a fixed size type.
+func (r *typeRegistry) byteWidth(id types.Si1LookupTypeID) (int, error) {
+	typ, err := r.lookup(id)
+	if err != nil {
+		return 0, err
+	}
+	def := typ.Def
+	switch {
+	case def.IsPrimitive:
+		if def.Primitive.Si0TypeDefPrimitive == types.IsBool {
+			return 1, nil
+		}
+		if width, ok := primitiveSizes[def.Primitive.Si0TypeDefPrimitive]; ok {
+			return width.size, nil
+		}
+	case def.IsArray:
+		width, err := r.byteWidth(def.Array.Type)
+		if err != nil {
+			return 0, err
+		}
+		return int(def.Array.Len) * width, nil
+	case def.IsComposite:
+		total := 0
+		for _, field := range def.Composite.Fields {
+			width, err := r.byteWidth(field.Type)
+			if err != nil {
+				return 0, err
+			}
+			total += width
+		}
+		return total, nil
+	case def.IsTuple:
+		total := 0
+		for _, elem := range def.Tuple {
+			width, err := r.byteWidth(elem)
+			if err != nil {
+				return 0, err
+			}
+			total += width
+		}
+		return total, nil
+	}
+	return 0, fmt.Errorf("type %d (%s) does not have a fixed size", id.Int64(), typeName(typ))
+}
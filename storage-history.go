@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// DefaultHistoryWindow is the number of blocks queried per state_queryStorage request by GetStorageHistory.
+const DefaultHistoryWindow = 5000
+
+// ErrStatePruned is returned when a node no longer has the state of the blocks queried, because it is not an
+// archive node. Use errors.As with a *StatePrunedError for the blocks affected.
+var ErrStatePruned = errors.New("state has been pruned")
+
+// StatePrunedError reports the blocks whose state the node has pruned.
+type StatePrunedError struct {
+	From, To uint64
+	Err      error
+}
+
+func (e *StatePrunedError) Error() string {
+	return fmt.Sprintf("state of blocks %d to %d has been pruned: %v", e.From, e.To, e.Err)
+}
+
+func (e *StatePrunedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StatePrunedError) Is(target error) bool {
+	return target == ErrStatePruned
+}
+
+// StorageHistoryOptions controls how GetStorageHistory queries the node.
+type StorageHistoryOptions struct {
+	// Window is the number of blocks per request. Zero selects DefaultHistoryWindow.
+	Window uint64
+	// To is the last block queried. Zero queries up to the best block.
+	To uint64
+	// Progress is called after each window with the blocks queried and the change sets found in them. To resume
+	// after an error, query again from to+1 of the last window reported. An error from Progress stops the query.
+	Progress func(from, to uint64, changes []types.StorageChangeSet) error
+	// FallbackToEvents reconstructs the history when the node has pruned state. The blocks whose state is retained
+	// are scanned for events involving the account, and the account is read in those blocks. The change sets from
+	// the retained blocks are returned along with a *StatePrunedError for the blocks skipped.
+	FallbackToEvents bool
+}
+
+// GetStorageHistory returns the changes to the System.Account storage of the account with the given hex public key
+// from block checkpoint, querying the node one window of blocks at a time. As with state_queryStorage, the first
+// change set is the state at the first block queried, whether or not it changed there. If the query fails part
+// way, the change sets found so far are returned with the error.
+func (c *Connection) GetStorageHistory(ID string, checkpoint uint64, opts StorageHistoryOptions) ([]types.StorageChangeSet, error) {
+	account, err := types.HexDecodeString(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := c.Api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, fmt.Errorf("can't get meta for api: %w", err)
+	}
+
+	storageKey, err := types.CreateStorageKey(meta, "System", "Account", account, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create storage key to query balance for account %s: %w", ID, err)
+	}
+
+	to := opts.To
+	if to == 0 {
+		if to, err = c.ChainHeight(); err != nil {
+			return nil, err
+		}
+	}
+	window := opts.Window
+	if window == 0 {
+		window = DefaultHistoryWindow
+	}
+
+	changes := []types.StorageChangeSet{}
+	var last *types.KeyValueOption
+	for from := checkpoint; from <= to; from += window {
+		end := from + window - 1
+		if end > to {
+			end = to
+		}
+		fromHash, err := c.Api.RPC.Chain.GetBlockHash(from)
+		if err != nil {
+			return changes, fmt.Errorf("GetBlockHash failed for block %d: %w", from, err)
+		}
+		endHash, err := c.Api.RPC.Chain.GetBlockHash(end)
+		if err != nil {
+			return changes, fmt.Errorf("GetBlockHash failed for block %d: %w", end, err)
+		}
+
+		sets, err := c.Api.RPC.State.QueryStorage([]types.StorageKey{storageKey}, fromHash, endHash)
+		if isStatePruned(err) {
+			if !opts.FallbackToEvents {
+				return changes, &StatePrunedError{From: from, To: end, Err: err}
+			}
+			return c.storageHistoryFromEvents(account, storageKey, from, to, window, changes, last, opts.Progress)
+		}
+		if err != nil {
+			return changes, fmt.Errorf("QueryStorage failed for account %s in blocks %d to %d: %w", ID, from, end, err)
+		}
+
+		sets = newChangeSets(sets, &last)
+		changes = append(changes, sets...)
+		if opts.Progress != nil {
+			if err := opts.Progress(from, end, sets); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+// storageHistoryFromEvents continues a history from block from, whose state has been pruned. It finds the first
+// block whose state is retained, then reads the account in that block and in each later block with events
+// involving the account or an extrinsic signed by it. Older runtimes emit no event naming the signer of an
+// extrinsic, though it pays a fee and its nonce changes.
+func (c *Connection) storageHistoryFromEvents(account []byte, storageKey types.StorageKey, from, to, window uint64,
+	changes []types.StorageChangeSet, last *types.KeyValueOption,
+	progress func(from, to uint64, changes []types.StorageChangeSet) error) ([]types.StorageChangeSet, error) {
+
+	retained, err := c.firstRetainedBlock(storageKey, from, to)
+	if err != nil {
+		return changes, err
+	}
+	var pruned error
+	if retained > from {
+		pruned = &StatePrunedError{From: from, To: retained - 1, Err: ErrStatePruned}
+		fmt.Printf("state of blocks %d to %d has been pruned, reconstructing history from events from block %d\n", from, retained-1, retained)
+	}
+
+	windowStart := retained
+	sets := []types.StorageChangeSet{}
+	for n := retained; n <= to; n++ {
+		hash, err := c.Api.RPC.Chain.GetBlockHash(n)
+		if err != nil {
+			return changes, fmt.Errorf("GetBlockHash failed for block %d: %w", n, err)
+		}
+		events, err := c.GetEvents(hash)
+		if err != nil {
+			return changes, err
+		}
+		relevant := n == retained || eventsInvolveAccount(events, account)
+		if !relevant {
+			block, err := c.GetBlockByHash(hash)
+			if err != nil {
+				return changes, fmt.Errorf("error getting block %d: %w", n, err)
+			}
+			relevant = blockSignedBy(block, account)
+		}
+		if relevant {
+			raw, err := c.Api.RPC.State.GetStorageRaw(storageKey, hash)
+			if err != nil {
+				return changes, fmt.Errorf("error getting account at block %d: %w", n, err)
+			}
+			set := types.StorageChangeSet{Block: hash, Changes: []types.KeyValueOption{{
+				StorageKey:     storageKey,
+				HasStorageData: len(*raw) > 0,
+				StorageData:    *raw,
+			}}}
+			sets = append(sets, newChangeSets([]types.StorageChangeSet{set}, &last)...)
+		}
+
+		if n == to || n-windowStart+1 == window {
+			changes = append(changes, sets...)
+			if progress != nil {
+				if err := progress(windowStart, n, sets); err != nil {
+					return changes, err
+				}
+			}
+			windowStart, sets = n+1, []types.StorageChangeSet{}
+		}
+	}
+	return changes, pruned
+}
+
+// firstRetainedBlock finds the first block from from to to whose state the node still has. Nodes prune state
+// from the oldest block up, so this is a binary search.
+func (c *Connection) firstRetainedBlock(storageKey types.StorageKey, from, to uint64) (uint64, error) {
+	low, high := from, to+1
+	for low < high {
+		mid := low + (high-low)/2
+		hash, err := c.Api.RPC.Chain.GetBlockHash(mid)
+		if err != nil {
+			return 0, fmt.Errorf("GetBlockHash failed for block %d: %w", mid, err)
+		}
+		_, err = c.Api.RPC.State.GetStorageRaw(storageKey, hash)
+		switch {
+		case isStatePruned(err):
+			low = mid + 1
+		case err != nil:
+			return 0, fmt.Errorf("error getting account at block %d: %w", mid, err)
+		default:
+			high = mid
+		}
+	}
+	if low > to {
+		return 0, &StatePrunedError{From: from, To: to, Err: ErrStatePruned}
+	}
+	return low, nil
+}
+
+// isStatePruned reports whether an RPC error is due to the node having pruned the state of a block.
+func isStatePruned(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "state already discarded") || strings.Contains(msg, "state pruned")
+}
+
+// newChangeSets drops the change sets whose value is the same as the one before. Each window of a windowed query
+// starts with the state at its first block whether or not it changed there. last is the last value seen, and is
+// updated.
+func newChangeSets(sets []types.StorageChangeSet, last **types.KeyValueOption) []types.StorageChangeSet {
+	changed := []types.StorageChangeSet{}
+	for _, set := range sets {
+		if len(set.Changes) == 0 {
+			continue
+		}
+		value := set.Changes[0]
+		if *last != nil && (*last).HasStorageData == value.HasStorageData && bytes.Equal((*last).StorageData, value.StorageData) {
+			continue
+		}
+		*last = &value
+		changed = append(changed, set)
+	}
+	return changed
+}
+
+// blockSignedBy reports whether any extrinsic in the block is signed by the account.
+func blockSignedBy(block *types.SignedBlock, account []byte) bool {
+	for _, extrinsic := range block.Block.Extrinsics {
+		if !extrinsic.IsSigned() {
+			continue
+		}
+		if signer, err := signerAccount(extrinsic.Signature.Signer); err == nil && bytes.Equal(signer, account) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventsInvolveAccount reports whether any event has the account in its fields.
+func eventsInvolveAccount(events []Event, account []byte) bool {
+	for _, e := range events {
+		for i := range e.Fields {
+			if bytes.Equal(e.AccountField(i), account) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsStatePruned(t *testing.T) {
+	assert.True(t, isStatePruned(errors.New("Client error: UnknownBlock: State already discarded for BlockId::Hash(0x01)")))
+	assert.True(t, isStatePruned(fmt.Errorf("query failed: %w", errors.New("State pruned"))))
+	assert.False(t, isStatePruned(errors.New("Method not found")))
+	assert.False(t, isStatePruned(nil))
+
+	var err error = &StatePrunedError{From: 10, To: 19, Err: errors.New("State already discarded")}
+	assert.True(t, errors.Is(err, ErrStatePruned))
+	var pruned *StatePrunedError
+	if assert.True(t, errors.As(fmt.Errorf("history: %w", err), &pruned)) {
+		assert.Equal(t, uint64(10), pruned.From)
+		assert.Equal(t, uint64(19), pruned.To)
+	}
+}
+
+func TestNewChangeSets(t *testing.T) {
+	set := func(block byte, data ...byte) types.StorageChangeSet {
+		return types.StorageChangeSet{Block: types.Hash{block}, Changes: []types.KeyValueOption{{
+			HasStorageData: data != nil,
+			StorageData:    data,
+		}}}
+	}
+	blocks := func(sets []types.StorageChangeSet) []byte {
+		numbers := []byte{}
+		for _, s := range sets {
+			numbers = append(numbers, s.Block[0])
+		}
+		return numbers
+	}
+
+	var last *types.KeyValueOption
+	// The first window.
+	assert.Equal(t, []byte{1, 3}, blocks(newChangeSets([]types.StorageChangeSet{set(1, 1), set(3, 2)}, &last)))
+	// The second window starts with the unchanged state at its first block.
+	assert.Equal(t, []byte{6}, blocks(newChangeSets([]types.StorageChangeSet{set(5, 2), set(6, 3)}, &last)))
+	// The account is reaped, then endowed with its previous balance.
+	assert.Equal(t, []byte{7, 8}, blocks(newChangeSets([]types.StorageChangeSet{set(7), set(8, 3), {Block: types.Hash{9}}}, &last)))
+	assert.Empty(t, newChangeSets([]types.StorageChangeSet{set(10, 3)}, &last))
+}
+
+func TestEventsInvolveAccount(t *testing.T) {
+	account := bytes.Repeat([]byte{1}, 32)
+	other := bytes.Repeat([]byte{2}, 32)
+	events := []Event{
+		testEvent(0, "System.ExtrinsicSuccess"),
+		testEvent(1, "Balances.Transfer", other, account, big.NewInt(10)),
+	}
+	assert.True(t, eventsInvolveAccount(events, account))
+	assert.True(t, eventsInvolveAccount(events, other))
+	assert.False(t, eventsInvolveAccount(events[:1], account))
+	assert.False(t, eventsInvolveAccount(events, bytes.Repeat([]byte{3}, 32)))
+}
+
+func TestBlockSignedBy(t *testing.T) {
+	account := bytes.Repeat([]byte{1}, 32)
+	signed := func(signer types.MultiAddress) types.Extrinsic {
+		extrinsic := types.Extrinsic{Version: types.ExtrinsicVersion4 | types.ExtrinsicBitSigned}
+		extrinsic.Signature.Signer = signer
+		return extrinsic
+	}
+	block := &types.SignedBlock{}
+	block.Block.Extrinsics = []types.Extrinsic{
+		{Version: types.ExtrinsicVersion4},
+		signed(types.NewMultiAddressFromAccountID(bytes.Repeat([]byte{2}, 32))),
+	}
+	assert.False(t, blockSignedBy(block, account))
+
+	block.Block.Extrinsics = append(block.Block.Extrinsics, signed(types.NewMultiAddressFromAccountID(account)))
+	assert.True(t, blockSignedBy(block, account))
+
+	var address32 [32]byte
+	copy(address32[:], account)
+	block.Block.Extrinsics = []types.Extrinsic{signed(types.MultiAddress{IsAddress32: true, AsAddress32: address32})}
+	assert.True(t, blockSignedBy(block, account))
+}
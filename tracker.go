@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// WaitFor selects the point in a transaction's lifecycle at which SubmitAndTrack returns.
+type WaitFor int
+
+const (
+	// WaitInBlock returns as soon as the transaction is included in a block. The block may still be retracted.
+	WaitInBlock WaitFor = iota
+	// WaitFinalized returns once the block including the transaction is finalized.
+	WaitFinalized
+	// WaitConfirmations returns once the including block has TrackOptions.Confirmations confirmations, or is
+	// finalized - whichever comes first.
+	WaitConfirmations
+)
+
+// TrackOptions controls how long SubmitAndTrack waits.
+type TrackOptions struct {
+	WaitFor WaitFor
+	// Confirmations is the number of blocks, counting the including block, required by WaitConfirmations.
+	Confirmations uint64
+	// Timeout bounds the wait. Zero waits until the node reports a final status for the transaction.
+	Timeout time.Duration
+	// DryRun validates the extrinsic with DryRun first, and returns the reason instead of submitting it if it
+	// would be rejected or fail.
+	DryRun bool
+}
+
+var (
+	ErrTxInvalid         = errors.New("transaction is invalid")
+	ErrTxDropped         = errors.New("transaction was dropped from the pool")
+	ErrTxUsurped         = errors.New("transaction was usurped by another with the same nonce")
+	ErrTxFinalityTimeout = errors.New("transaction block was not finalized in time")
+	ErrTxTimeout         = errors.New("timed out waiting for transaction")
+)
+
+// Receipt is the outcome of an extrinsic that has been included in a block.
+type Receipt struct {
+	ExtrinsicHash  types.Hash
+	BlockHash      types.Hash
+	BlockNumber    uint64
+	ExtrinsicIndex uint32
+	// Success is true if System.ExtrinsicSuccess was emitted, false for System.ExtrinsicFailed.
+	Success bool
+	// Err is the dispatch error of a failed extrinsic - a DispatchError.
+	Err       error
+	Finalized bool
+	// Confirmations is the number of blocks, counting the including block, on the best chain when the receipt
+	// was built.
+	Confirmations uint64
+	// Fee is the fee actually paid, including the tip. Nil if the events don't show the fee.
+	Fee *big.Int
+	// Events holds only the events emitted while applying the extrinsic, in the order they were emitted.
+	Events []Event
+}
+
+func (r Receipt) String() string {
+	return fmt.Sprintf("ExtrinsicHash: %#x\nBlockHash: %#x\nBlockNumber: %d\nExtrinsicIndex: %d\nSuccess: %t\nErr: %v\nFinalized: %t\nConfirmations: %d\nFee: %s\n",
+		r.ExtrinsicHash,
+		r.BlockHash,
+		r.BlockNumber,
+		r.ExtrinsicIndex,
+		r.Success,
+		r.Err,
+		r.Finalized,
+		r.Confirmations,
+		r.Fee,
+	)
+}
+
+// SubmitAndTrack submits the extrinsic and follows its status until the point selected by opts, then returns
+// its receipt. An error is returned if the transaction is invalid, dropped or usurped, if its block isn't
+// finalized in time, or if the subscription fails. A retracted block is not an error - the transaction returns
+// to the pool and tracking continues.
+func (c *Connection) SubmitAndTrack(extrinsic types.Extrinsic, opts TrackOptions) (*Receipt, error) {
+	if opts.DryRun {
+		result, err := c.DryRun(extrinsic)
+		if err != nil {
+			return nil, fmt.Errorf("dry run failed: %w", err)
+		}
+		if err := result.Err(); err != nil {
+			return nil, fmt.Errorf("dry run: %w", err)
+		}
+	}
+	return c.newTxTracker().track(extrinsic, opts)
+}
+
+// txTracker follows the status of a submitted extrinsic. The node is reached through its function fields.
+type txTracker struct {
+	submitAndWatch func(extrinsic types.Extrinsic) (statuses <-chan types.ExtrinsicStatus, errs <-chan error, unsubscribe func(), err error)
+	subscribeHeads func() (heads <-chan types.Header, errs <-chan error, unsubscribe func(), err error)
+	blockNumber    func(hash types.Hash) (uint64, error)
+	blockHash      func(number uint64) (types.Hash, error)
+	receipt        func(blockHash, extrinsicHash types.Hash) (*Receipt, error)
+}
+
+func (c *Connection) newTxTracker() *txTracker {
+	return &txTracker{
+		submitAndWatch: func(extrinsic types.Extrinsic) (<-chan types.ExtrinsicStatus, <-chan error, func(), error) {
+			subscription, err := c.Api.RPC.Author.SubmitAndWatchExtrinsic(extrinsic)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return subscription.Chan(), subscription.Err(), subscription.Unsubscribe, nil
+		},
+		subscribeHeads: func() (<-chan types.Header, <-chan error, func(), error) {
+			heads, err := c.Api.RPC.Chain.SubscribeNewHeads()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return heads.Chan(), heads.Err(), heads.Unsubscribe, nil
+		},
+		blockNumber: func(hash types.Hash) (uint64, error) {
+			header, err := c.Api.RPC.Chain.GetHeader(hash)
+			if err != nil {
+				return 0, err
+			}
+			return uint64(header.Number), nil
+		},
+		blockHash: c.Api.RPC.Chain.GetBlockHash,
+		receipt:   c.GetReceipt,
+	}
+}
+
+func (t *txTracker) track(extrinsic types.Extrinsic, opts TrackOptions) (*Receipt, error) {
+	extrinsicHash, err := types.GetHash(extrinsic)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, statusErr, unsubscribe, err := t.submitAndWatch(extrinsic)
+	if err != nil {
+		return nil, fmt.Errorf("failure to submit extrinsic: %w", err)
+	}
+	defer unsubscribe()
+
+	var headsChan <-chan types.Header
+	var headsErr <-chan error
+	if opts.WaitFor == WaitConfirmations {
+		var unsubscribeHeads func()
+		headsChan, headsErr, unsubscribeHeads, err = t.subscribeHeads()
+		if err != nil {
+			return nil, fmt.Errorf("failure to subscribe to new heads: %w", err)
+		}
+		defer unsubscribeHeads()
+	}
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var inBlock *types.Hash
+	var inBlockNumber uint64
+	for {
+		select {
+		case status := <-statuses:
+			switch {
+			case status.IsFuture, status.IsReady, status.IsBroadcast:
+				fmt.Println("Waiting for extrinsic to be included in a block...")
+			case status.IsInBlock:
+				fmt.Printf("included in block %#x\n", status.AsInBlock)
+				if opts.WaitFor == WaitInBlock || (opts.WaitFor == WaitConfirmations && opts.Confirmations <= 1) {
+					return t.receipt(status.AsInBlock, extrinsicHash)
+				}
+				number, err := t.blockNumber(status.AsInBlock)
+				if err != nil {
+					return nil, fmt.Errorf("error getting header for block %#x: %w", status.AsInBlock, err)
+				}
+				hash := status.AsInBlock
+				inBlock, inBlockNumber = &hash, number
+			case status.IsRetracted:
+				fmt.Printf("block %#x retracted, waiting for the extrinsic to be included again\n", status.AsRetracted)
+				inBlock = nil
+			case status.IsFinalized:
+				fmt.Printf("finalized in block %#x\n", status.AsFinalized)
+				return t.receipt(status.AsFinalized, extrinsicHash)
+			case status.IsFinalityTimeout:
+				return nil, fmt.Errorf("%w: block %#x", ErrTxFinalityTimeout, status.AsFinalityTimeout)
+			case status.IsUsurped:
+				return nil, fmt.Errorf("%w: %#x", ErrTxUsurped, status.AsUsurped)
+			case status.IsDropped:
+				return nil, ErrTxDropped
+			case status.IsInvalid:
+				return nil, ErrTxInvalid
+			}
+		case head := <-headsChan:
+			if inBlock == nil || uint64(head.Number)+1 < inBlockNumber+opts.Confirmations {
+				continue
+			}
+			// Only count confirmations if the block is still on the best chain.
+			canonical, err := t.blockHash(inBlockNumber)
+			if err != nil {
+				return nil, fmt.Errorf("error getting block hash at %d: %w", inBlockNumber, err)
+			}
+			if canonical == *inBlock {
+				return t.receipt(*inBlock, extrinsicHash)
+			}
+		case err := <-statusErr:
+			return nil, fmt.Errorf("extrinsic subscription failed: %w", err)
+		case err := <-headsErr:
+			return nil, fmt.Errorf("new heads subscription failed: %w", err)
+		case <-timeout:
+			return nil, ErrTxTimeout
+		}
+	}
+}
+
+// GetReceipt builds the receipt for the extrinsic with the given hash in the given block.
+func (c *Connection) GetReceipt(blockHash, extrinsicHash types.Hash) (*Receipt, error) {
+	block, err := c.GetBlockByHash(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting block for hash %#x: %w", blockHash, err)
+	}
+
+	receipt := &Receipt{
+		ExtrinsicHash: extrinsicHash,
+		BlockHash:     blockHash,
+		BlockNumber:   uint64(block.Block.Header.Number),
+	}
+
+	var extrinsic *types.Extrinsic
+	for i := range block.Block.Extrinsics {
+		h, err := types.GetHash(block.Block.Extrinsics[i])
+		if err != nil {
+			return nil, err
+		}
+		if h == extrinsicHash {
+			extrinsic = &block.Block.Extrinsics[i]
+			receipt.ExtrinsicIndex = uint32(i)
+			break
+		}
+	}
+	if extrinsic == nil {
+		return nil, fmt.Errorf("extrinsic %#x not found in block %#x", extrinsicHash, blockHash)
+	}
+
+	meta, err := c.getMetadata(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	events, err := c.getBlockEvents(blockHash, meta)
+	if err != nil {
+		return nil, err
+	}
+	receipt.Events = eventsForExtrinsic(events, receipt.ExtrinsicIndex)
+
+	receipt.Success = len(findEvents(receipt.Events, "System.ExtrinsicSuccess")) > 0
+	for _, e := range findEvents(receipt.Events, "System.ExtrinsicFailed") {
+		if dispatchError := e.DispatchError(); dispatchError != nil {
+			receipt.Err = *dispatchError
+		}
+	}
+	if extrinsic.IsSigned() {
+		if signer, err := signerAccount(extrinsic.Signature.Signer); err == nil {
+			receipt.Fee = extrinsicFee(receipt.Events, signer)
+		}
+	}
+
+	status, err := c.BlockStatus(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	receipt.Finalized = status.Finalized
+	receipt.Confirmations = status.Confirmations
+	return receipt, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var trackedExtrinsic = types.NewExtrinsic(types.Call{CallIndex: types.CallIndex{SectionIndex: 5}})
+
+// testTracker is a txTracker whose node sends the given statuses and new heads, in order, and reports the blocks
+// in numbers and canonical.
+type testTracker struct {
+	statuses chan types.ExtrinsicStatus
+	heads    chan types.Header
+	errs     chan error
+	// numbers are the numbers of the blocks by hash, canonical the hashes on the best chain by number.
+	numbers   map[types.Hash]uint64
+	canonical map[uint64]types.Hash
+}
+
+func newTestTracker() *testTracker {
+	return &testTracker{
+		statuses:  make(chan types.ExtrinsicStatus),
+		heads:     make(chan types.Header),
+		errs:      make(chan error),
+		numbers:   map[types.Hash]uint64{},
+		canonical: map[uint64]types.Hash{},
+	}
+}
+
+func (tt *testTracker) tracker() *txTracker {
+	return &txTracker{
+		submitAndWatch: func(types.Extrinsic) (<-chan types.ExtrinsicStatus, <-chan error, func(), error) {
+			return tt.statuses, tt.errs, func() {}, nil
+		},
+		subscribeHeads: func() (<-chan types.Header, <-chan error, func(), error) {
+			return tt.heads, nil, func() {}, nil
+		},
+		blockNumber: func(hash types.Hash) (uint64, error) {
+			return tt.numbers[hash], nil
+		},
+		blockHash: func(number uint64) (types.Hash, error) {
+			return tt.canonical[number], nil
+		},
+		receipt: func(blockHash, extrinsicHash types.Hash) (*Receipt, error) {
+			return &Receipt{BlockHash: blockHash, ExtrinsicHash: extrinsicHash, BlockNumber: tt.numbers[blockHash]}, nil
+		},
+	}
+}
+
+// send feeds the statuses and heads to the tracker one at a time, in order.
+func (tt *testTracker) send(events ...interface{}) {
+	go func() {
+		for _, e := range events {
+			switch e := e.(type) {
+			case types.ExtrinsicStatus:
+				tt.statuses <- e
+			case types.Header:
+				tt.heads <- e
+			case error:
+				tt.errs <- e
+			}
+		}
+	}()
+}
+
+func TestTrackInBlock(t *testing.T) {
+	block := types.Hash{1}
+	tt := newTestTracker()
+	tt.send(
+		types.ExtrinsicStatus{IsReady: true},
+		types.ExtrinsicStatus{IsBroadcast: true},
+		types.ExtrinsicStatus{IsInBlock: true, AsInBlock: block},
+	)
+	receipt, err := tt.tracker().track(trackedExtrinsic, TrackOptions{WaitFor: WaitInBlock})
+	assert.NoError(t, err)
+	assert.Equal(t, block, receipt.BlockHash)
+	hash, err := types.GetHash(trackedExtrinsic)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, receipt.ExtrinsicHash)
+}
+
+func TestTrackFinalizedAfterRetraction(t *testing.T) {
+	retracted, block := types.Hash{1}, types.Hash{2}
+	tt := newTestTracker()
+	tt.send(
+		types.ExtrinsicStatus{IsInBlock: true, AsInBlock: retracted},
+		types.ExtrinsicStatus{IsRetracted: true, AsRetracted: retracted},
+		types.ExtrinsicStatus{IsInBlock: true, AsInBlock: block},
+		types.ExtrinsicStatus{IsFinalized: true, AsFinalized: block},
+	)
+	receipt, err := tt.tracker().track(trackedExtrinsic, TrackOptions{WaitFor: WaitFinalized})
+	assert.NoError(t, err)
+	assert.Equal(t, block, receipt.BlockHash)
+}
+
+func TestTrackConfirmations(t *testing.T) {
+	reorged, block := types.Hash{1}, types.Hash{2}
+	tt := newTestTracker()
+	tt.numbers[reorged] = 10
+	tt.numbers[block] = 11
+	// Block 10 has been replaced on the best chain.
+	tt.canonical[10] = types.Hash{3}
+	tt.canonical[11] = block
+	tt.send(
+		types.ExtrinsicStatus{IsInBlock: true, AsInBlock: reorged},
+		types.Header{Number: 11},
+		// Three confirmations of block 10, but it is no longer on the best chain.
+		types.Header{Number: 12},
+		types.ExtrinsicStatus{IsRetracted: true, AsRetracted: reorged},
+		types.ExtrinsicStatus{IsInBlock: true, AsInBlock: block},
+		types.Header{Number: 12},
+		types.Header{Number: 13},
+	)
+	receipt, err := tt.tracker().track(trackedExtrinsic, TrackOptions{WaitFor: WaitConfirmations, Confirmations: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, block, receipt.BlockHash)
+	assert.Equal(t, uint64(11), receipt.BlockNumber)
+}
+
+func TestTrackFailures(t *testing.T) {
+	cases := []struct {
+		status   types.ExtrinsicStatus
+		expected error
+	}{
+		{types.ExtrinsicStatus{IsInvalid: true}, ErrTxInvalid},
+		{types.ExtrinsicStatus{IsDropped: true}, ErrTxDropped},
+		{types.ExtrinsicStatus{IsUsurped: true, AsUsurped: types.Hash{1}}, ErrTxUsurped},
+		{types.ExtrinsicStatus{IsFinalityTimeout: true, AsFinalityTimeout: types.Hash{1}}, ErrTxFinalityTimeout},
+	}
+	for _, tc := range cases {
+		tt := newTestTracker()
+		tt.send(types.ExtrinsicStatus{IsReady: true}, tc.status)
+		_, err := tt.tracker().track(trackedExtrinsic, TrackOptions{WaitFor: WaitFinalized})
+		assert.True(t, errors.Is(err, tc.expected), "expected %v, got %v", tc.expected, err)
+	}
+
+	tt := newTestTracker()
+	tt.send(types.ExtrinsicStatus{IsReady: true}, errors.New("connection closed"))
+	_, err := tt.tracker().track(trackedExtrinsic, TrackOptions{WaitFor: WaitFinalized})
+	assert.EqualError(t, err, "extrinsic subscription failed: connection closed")
+
+	tt = newTestTracker()
+	tt.send(types.ExtrinsicStatus{IsReady: true})
+	_, err = tt.tracker().track(trackedExtrinsic, TrackOptions{WaitFor: WaitFinalized, Timeout: 20 * time.Millisecond})
+	assert.Equal(t, ErrTxTimeout, err)
+}
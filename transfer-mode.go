@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// TransferMode selects the Balances call used for a transfer, which determines whether the sender's account may
+// be reaped.
+type TransferMode int
+
+const (
+	// KeepAlive sends with Balances.transfer_keep_alive. The transfer is refused if it would leave the sender
+	// below the existential deposit.
+	KeepAlive TransferMode = iota
+	// AllowDeath sends with Balances.transfer. The sender's account is reaped if the transfer leaves it below
+	// the existential deposit.
+	AllowDeath
+	// SweepAll sends the sender's entire transferable balance, less fees, with Balances.transfer_all. The
+	// sender's account is reaped. The amount passed with the transfer is ignored.
+	SweepAll
+)
+
+func (m TransferMode) String() string {
+	switch m {
+	case KeepAlive:
+		return "KeepAlive"
+	case AllowDeath:
+		return "AllowDeath"
+	case SweepAll:
+		return "SweepAll"
+	}
+	return fmt.Sprintf("TransferMode(%d)", int(m))
+}
+
+// TransferOptions controls how a transfer is built, checked, signed and tracked.
+type TransferOptions struct {
+	TxOptions
+	Mode  TransferMode
+	Track TrackOptions
+	// Nonces, if set and TxOptions.Nonce is not, allocates the nonce for Transfer and settles it with the outcome.
+	Nonces *NonceManager
+}
+
+var (
+	// ErrInsufficientBalance is returned when the sender can't cover a transfer and its fee.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrExistentialDeposit is returned when a transfer would leave the sender or recipient with a non-zero
+	// balance below the existential deposit.
+	ErrExistentialDeposit = errors.New("existential deposit not met")
+)
+
+// transferCall builds the Balances call for the transfer mode. The amount may be nil for SweepAll.
+func transferCall(meta *types.Metadata, to string, amount *big.Int, mode TransferMode) (types.Call, error) {
+	if amount == nil && mode != SweepAll {
+		return types.Call{}, fmt.Errorf("no amount given for %s transfer", mode)
+	}
+	switch mode {
+	case KeepAlive:
+		return NewDynamicCall(meta, "Balances.transfer_keep_alive", CallArgs{"dest": to, "value": amount})
+	case AllowDeath:
+		return NewDynamicCall(meta, "Balances.transfer", CallArgs{"dest": to, "value": amount})
+	case SweepAll:
+		return NewDynamicCall(meta, "Balances.transfer_all", CallArgs{"dest": to, "keep_alive": false})
+	}
+	return types.Call{}, fmt.Errorf("unknown transfer mode %d", mode)
+}
+
+// ExistentialDeposit returns the minimum balance an account must hold to exist, from the Balances pallet
+// constant.
+func (c *Connection) ExistentialDeposit() (*big.Int, error) {
+	meta, err := c.Api.RPC.State.GetMetadataLatest()
+	if err != nil {
+		return nil, fmt.Errorf("fetch metadata failed: %w", err)
+	}
+	return existentialDeposit(meta)
+}
+
+func existentialDeposit(meta *types.Metadata) (*big.Int, error) {
+	r, err := newTypeRegistry(meta)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := r.constant("Balances", "ExistentialDeposit")
+	if err != nil {
+		return nil, err
+	}
+	var ed types.U128
+	if err := types.DecodeFromBytes(raw, &ed); err != nil {
+		return nil, fmt.Errorf("error decoding Balances.ExistentialDeposit: %w", err)
+	}
+	return ed.Int, nil
+}
+
+// accountInfo reads the System.Account entry for the public key. Accounts that don't exist have a zero balance.
+func (c *Connection) accountInfo(meta *types.Metadata, publicKey []byte) (AccountInfo, error) {
+	key, err := types.CreateStorageKey(meta, "System", "Account", publicKey)
+	if err != nil {
+		return AccountInfo{}, fmt.Errorf("problem creating storage key: %w", err)
+	}
+	var info AccountInfo
+	if _, err := c.Api.RPC.State.GetStorageLatest(key, &info); err != nil {
+		return AccountInfo{}, fmt.Errorf("problem getting account info for %#x: %w", publicKey, err)
+	}
+	return info, nil
+}
+
+// preflightTransfer checks that the transfer can succeed without reaping either account unintentionally. The fee
+// is estimated from the extrinsic with a blank signature, so nothing is signed.
+func (c *Connection) preflightTransfer(meta *types.Metadata, senderPubKey []byte, to string, amount *big.Int, call types.Call, opts TransferOptions) error {
+	recipientPubKey, err := toBytes(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient %s: %w", to, err)
+	}
+	ed, err := existentialDeposit(meta)
+	if err != nil {
+		return err
+	}
+	sender, err := c.accountInfo(meta, senderPubKey)
+	if err != nil {
+		return err
+	}
+	recipient, err := c.accountInfo(meta, recipientPubKey)
+	if err != nil {
+		return err
+	}
+	fee, err := c.estimateCallFee(senderPubKey, call, opts.TxOptions)
+	if err != nil {
+		return fmt.Errorf("error estimating fee: %w", err)
+	}
+	return checkTransfer(opts.Mode, amount, fee.Total, ed, sender, recipient)
+}
+
+// estimateCallFee estimates the fee for sending the call from the account, without signing it. The fee depends
+// only on the length of the extrinsic, so a blank signature of the right size is used.
+func (c *Connection) estimateCallFee(publicKey []byte, call types.Call, opts TxOptions) (*FeeEstimate, error) {
+	o, _, err := c.signatureOptions(publicKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	extrinsic := types.NewExtrinsic(call)
+	extrinsic.Signature = types.ExtrinsicSignatureV4{
+		Signer:    types.NewMultiAddressFromAccountID(publicKey),
+		Signature: types.MultiSignature{IsSr25519: true},
+		Era:       o.Era,
+		Nonce:     o.Nonce,
+		Tip:       o.Tip,
+	}
+	extrinsic.Version |= types.ExtrinsicBitSigned
+	return c.EstimateFee(extrinsic)
+}
+
+// checkTransfer validates a transfer of amount plus fee against the existential deposit ed.
+func checkTransfer(mode TransferMode, amount, fee, ed *big.Int, sender, recipient AccountInfo) error {
+	free := u128(sender.Data.Free)
+	frozen := u128(sender.Data.MiscFrozen)
+	if feeFrozen := u128(sender.Data.FreeFrozen); feeFrozen.Cmp(frozen) > 0 {
+		frozen = feeFrozen
+	}
+	spendable := new(big.Int).Sub(free, frozen)
+	if spendable.Sign() < 0 {
+		spendable.SetInt64(0)
+	}
+
+	switch mode {
+	case SweepAll:
+		if fee.Cmp(spendable) >= 0 {
+			return fmt.Errorf("%w: transferable balance %s does not cover the fee %s", ErrInsufficientBalance, spendable, fee)
+		}
+		amount = new(big.Int).Sub(spendable, fee)
+	case KeepAlive, AllowDeath:
+		if amount == nil || amount.Sign() <= 0 {
+			return fmt.Errorf("transfer amount must be greater than zero")
+		}
+		required := new(big.Int).Add(amount, fee)
+		if required.Cmp(spendable) > 0 {
+			return fmt.Errorf("%w: transferable balance %s does not cover the amount %s plus fee %s", ErrInsufficientBalance, spendable, amount, fee)
+		}
+		remaining := new(big.Int).Sub(free, required)
+		if mode == KeepAlive && remaining.Cmp(ed) < 0 {
+			max := new(big.Int).Sub(spendable, fee)
+			max.Sub(max, ed)
+			if max.Sign() < 0 {
+				max.SetInt64(0)
+			}
+			return fmt.Errorf("%w: transfer would leave the sender with %s, below the existential deposit %s - the most that can be sent with KeepAlive is %s", ErrExistentialDeposit, remaining, ed, max)
+		}
+		if mode == AllowDeath && remaining.Sign() > 0 && remaining.Cmp(ed) < 0 {
+			return fmt.Errorf("%w: transfer would leave the sender with %s, below the existential deposit %s, which would be lost when the account is reaped - use SweepAll to send the full balance", ErrExistentialDeposit, remaining, ed)
+		}
+	default:
+		return fmt.Errorf("unknown transfer mode %d", mode)
+	}
+
+	// A new account is only created if it is funded with at least the existential deposit.
+	balance := new(big.Int).Add(u128(recipient.Data.Free), u128(recipient.Data.Reserved))
+	if balance.Cmp(ed) < 0 {
+		if total := new(big.Int).Add(balance, amount); total.Cmp(ed) < 0 {
+			return fmt.Errorf("%w: recipient balance would be %s, below the existential deposit %s", ErrExistentialDeposit, total, ed)
+		}
+	}
+	return nil
+}
+
+// u128 returns the value of a U128, treating the zero value as 0.
+func u128(v types.U128) *big.Int {
+	if v.Int == nil {
+		return big.NewInt(0)
+	}
+	return v.Int
+}
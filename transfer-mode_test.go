@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAccount(free, frozen int64) AccountInfo {
+	info := AccountInfo{}
+	info.Data.Free = types.NewU128(*big.NewInt(free))
+	info.Data.MiscFrozen = types.NewU128(*big.NewInt(frozen))
+	return info
+}
+
+func TestExistentialDeposit(t *testing.T) {
+	ed, err := existentialDeposit(testMetadata(t))
+	assert.NoError(t, err)
+	// Polkadot's existential deposit is 1 DOT.
+	assert.Equal(t, "10000000000", ed.String())
+}
+
+func TestTransferCall(t *testing.T) {
+	meta := testMetadata(t)
+	for _, mode := range []TransferMode{KeepAlive, AllowDeath, SweepAll} {
+		_, err := transferCall(meta, BobPubkey, big.NewInt(100), mode)
+		assert.NoError(t, err, mode.String())
+	}
+	_, err := transferCall(meta, BobPubkey, big.NewInt(100), TransferMode(9))
+	assert.Error(t, err)
+	_, err = transferCall(meta, BobPubkey, nil, KeepAlive)
+	assert.Error(t, err)
+	_, err = transferCall(meta, BobPubkey, nil, SweepAll)
+	assert.NoError(t, err)
+
+	// 2^70 - over a thousand tokens of an 18 decimal chain, and more than a uint64 holds.
+	amount := new(big.Int).Lsh(big.NewInt(1), 70)
+	call, err := transferCall(meta, BobPubkey, amount, KeepAlive)
+	assert.NoError(t, err)
+	decoded, err := DecodeCall(meta, call)
+	assert.NoError(t, err)
+	assert.Equal(t, amount, decoded.Args["value"])
+}
+
+func TestCheckTransfer(t *testing.T) {
+	ed := big.NewInt(100)
+	fee := big.NewInt(10)
+	funded := testAccount(1000, 0)
+	empty := AccountInfo{}
+
+	assert.NoError(t, checkTransfer(KeepAlive, big.NewInt(890), fee, ed, funded, funded))
+	assert.ErrorIs(t, checkTransfer(KeepAlive, big.NewInt(891), fee, ed, funded, funded), ErrExistentialDeposit)
+	assert.ErrorIs(t, checkTransfer(KeepAlive, big.NewInt(991), fee, ed, funded, funded), ErrInsufficientBalance)
+
+	// Frozen funds can't be sent.
+	assert.ErrorIs(t, checkTransfer(AllowDeath, big.NewInt(900), fee, ed, testAccount(1000, 200), funded), ErrInsufficientBalance)
+
+	// AllowDeath may empty the account, but not leave dust behind.
+	assert.NoError(t, checkTransfer(AllowDeath, big.NewInt(990), fee, ed, funded, funded))
+	assert.NoError(t, checkTransfer(AllowDeath, big.NewInt(500), fee, ed, funded, funded))
+	assert.ErrorIs(t, checkTransfer(AllowDeath, big.NewInt(950), fee, ed, funded, funded), ErrExistentialDeposit)
+
+	// A new recipient account must receive at least the existential deposit.
+	assert.ErrorIs(t, checkTransfer(KeepAlive, big.NewInt(99), fee, ed, funded, empty), ErrExistentialDeposit)
+	assert.NoError(t, checkTransfer(KeepAlive, big.NewInt(100), fee, ed, funded, empty))
+	assert.NoError(t, checkTransfer(KeepAlive, big.NewInt(1), fee, ed, funded, testAccount(100, 0)))
+
+	assert.NoError(t, checkTransfer(SweepAll, big.NewInt(0), fee, ed, funded, empty))
+	assert.ErrorIs(t, checkTransfer(SweepAll, big.NewInt(0), fee, ed, testAccount(50, 0), empty), ErrExistentialDeposit)
+	assert.ErrorIs(t, checkTransfer(SweepAll, big.NewInt(0), fee, ed, testAccount(10, 0), empty), ErrInsufficientBalance)
+
+	// Balances beyond a uint64.
+	large := new(big.Int).Lsh(big.NewInt(1), 70)
+	rich := AccountInfo{}
+	rich.Data.Free = types.NewU128(*new(big.Int).Mul(large, big.NewInt(2)))
+	assert.NoError(t, checkTransfer(KeepAlive, large, fee, ed, rich, empty))
+	assert.ErrorIs(t, checkTransfer(KeepAlive, new(big.Int).Mul(large, big.NewInt(3)), fee, ed, rich, empty), ErrInsufficientBalance)
+	assert.Error(t, checkTransfer(KeepAlive, nil, fee, ed, rich, empty))
+}
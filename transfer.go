@@ -2,25 +2,56 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 )
 
-func (c *Connection) Transfer(from signature.KeyringPair, to string, amount uint64) error {
+// Transfer sends amount from the sender to the recipient and tracks the transaction as set by opts.Track. A
+// receipt is returned once the transaction is included - check Receipt.Success, since a transaction that is
+// included may still fail to dispatch. If opts.Nonces is set, the nonce is allocated from it and settled with the
+// outcome.
+func (c *Connection) Transfer(from signature.KeyringPair, to string, amount *big.Int, opts TransferOptions) (*Receipt, error) {
+	if opts.Nonces == nil || opts.Nonce != nil {
+		return c.transfer(from, to, amount, opts)
+	}
+	nonce, err := opts.Nonces.Next(from.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	opts.Nonce = &nonce
+	receipt, err := c.transfer(from, to, amount, opts)
+	if settleErr := opts.Nonces.Settle(from.PublicKey, nonce, err); settleErr != nil && err == nil {
+		return receipt, settleErr
+	}
+	return receipt, err
+}
+
+func (c *Connection) transfer(from signature.KeyringPair, to string, amount *big.Int, opts TransferOptions) (*Receipt, error) {
 
 	// Specify a private key/phrase as an environment variable, or the inbuilt Alice identity will be used
-	extrinsic, err := c.NewExtrinsic(from, to, amount)
+	signed, err := c.NewExtrinsic(from, to, amount, opts)
 	if err != nil {
-		return fmt.Errorf("error building new extrinsic: %w", err)
+		return nil, fmt.Errorf("error building new extrinsic: %w", err)
 	}
+	extrinsic := &signed.Extrinsic
 
 	extrinsicString, err := types.EncodeToHexString(extrinsic)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	fmt.Printf("extrinsic: %s\n", extrinsicString)
+	if signed.ExpiresAt != 0 {
+		fmt.Printf("transaction expires at block %d\n", signed.ExpiresAt)
+	}
+
+	fee, err := c.EstimateFee(*extrinsic)
+	if err != nil {
+		return nil, fmt.Errorf("error estimating fee: %w", err)
+	}
+	fmt.Printf("estimated fee (including tip): %s\n", fee.Total)
 	/*
 		tx, err := c.Api.RPC.Author.SubmitExtrinsic(*extrinsic)
 		if err != nil {
@@ -30,19 +61,10 @@ func (c *Connection) Transfer(from signature.KeyringPair, to string, amount uint
 		fmt.Printf("tx.Hash() %#x\n", tx.Hex())
 	*/
 
-	subscription, err := c.Api.RPC.Author.SubmitAndWatchExtrinsic(*extrinsic)
+	receipt, err := c.SubmitAndTrack(*extrinsic, opts.Track)
 	if err != nil {
-		return fmt.Errorf("failure to submit extrinsic: %w", err)
-	}
-	defer subscription.Unsubscribe()
-	for {
-		status := <-subscription.Chan()
-		if status.IsInBlock || status.IsFinalized {
-			fmt.Printf("included in block %#x\n", status.AsInBlock)
-			break
-		}
-		fmt.Println("Waiting for extrinsic to be included in a block...")
+		return nil, err
 	}
-
-	return nil
+	fmt.Printf("fee paid: %s\n", receipt.Fee)
+	return receipt, nil
 }
@@ -4,14 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"log"
-	"math/big"
 	"time"
 
 	"github.com/btcsuite/btcutil/base58"
 	gsrpc "github.com/centrifuge/go-substrate-rpc-client/v4"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/config"
-	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/vedhavyas/go-subkey"
 	"golang.org/x/crypto/blake2b"
 )
 
@@ -92,22 +91,31 @@ func (c *Connection) GetBlockTimestamp(block *types.SignedBlock, blockHash types
 	if err != nil {
 		return nil, err
 	}
+	return blockTimestamp(block, callIndex)
+}
 
-	timestamp := new(big.Int)
-	for _, extrinsic := range block.Block.Extrinsics {
-		if extrinsic.Method.CallIndex != callIndex {
-			continue
-		}
-		timeDecoder := scale.NewDecoder(bytes.NewReader(extrinsic.Method.Args))
-		timestamp, err = timeDecoder.DecodeUintCompact()
-		if err != nil {
-			return nil, err
-		}
-		break
+// SS58Prefix returns the SS58 address format of the connected chain, as reported by system_properties.
+// Chains that don't report a format use the generic Substrate format, 42.
+func (c *Connection) SS58Prefix() (uint8, error) {
+	var props struct {
+		SS58Format *uint8 `json:"ss58Format"`
+	}
+	if err := c.Api.Client.Call(&props, "system_properties"); err != nil {
+		return 0, fmt.Errorf("failed to get chain properties: %w", err)
+	}
+	if props.SS58Format == nil {
+		return 42, nil
 	}
+	return *props.SS58Format, nil
+}
 
-	time := time.UnixMilli(timestamp.Int64())
-	return &time, nil
+// SS58Address encodes a public key as an address in the SS58 format of the connected chain.
+func (c *Connection) SS58Address(publicKey []byte) (string, error) {
+	prefix, err := c.SS58Prefix()
+	if err != nil {
+		return "", err
+	}
+	return subkey.SS58Address(publicKey, prefix)
 }
 
 // ChainHeight fetches the latest block header and returns the block number (the chain height).
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"github.com/ChainSafe/go-schnorrkel"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	secp256k1 "github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrInvalidSignature is returned when a signature does not verify against the signer and payload.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// VerifyExtrinsic checks the signature of a signed extrinsic. The signing payload is rebuilt from the extrinsic's
+// call, era, nonce and tip and the given chain parameters - the genesis hash, the spec and transaction versions
+// of the runtime it was signed for, and checkpointBlock, the hash of the block its era starts from (the genesis
+// hash for an immortal extrinsic). It needs no connection, so historical extrinsics can be audited offline.
+//
+// A signature that was made over a different payload, e.g. for another runtime version or checkpoint, fails to
+// verify the same as a forged one.
+func VerifyExtrinsic(ext types.Extrinsic, genesisHash types.Hash, specVersion, txVersion types.U32, checkpointBlock types.Hash) error {
+	if !ext.IsSigned() {
+		return fmt.Errorf("extrinsic is not signed")
+	}
+	signer, err := signerAccount(ext.Signature.Signer)
+	if err != nil {
+		return err
+	}
+	payload, err := createUnsignedPayload(&ext, types.SignatureOptions{
+		BlockHash:          checkpointBlock,
+		Era:                ext.Signature.Era,
+		GenesisHash:        genesisHash,
+		Nonce:              ext.Signature.Nonce,
+		SpecVersion:        specVersion,
+		Tip:                ext.Signature.Tip,
+		TransactionVersion: txVersion,
+	})
+	if err != nil {
+		return err
+	}
+	message, err := signingPayload(payload)
+	if err != nil {
+		return err
+	}
+	return verifySignature(message, ext.Signature.Signature, signer)
+}
+
+// VerifyPayload checks a signature over payload bytes to be signed, as returned by GenTransaction, e.g. one
+// produced by MPC signing, before it is attached to the transaction. signer is the signer's public key - its
+// account ID.
+func VerifyPayload(payload []byte, sig types.MultiSignature, signer []byte) error {
+	if len(payload) > 256 {
+		h := blake2b.Sum256(payload)
+		payload = h[:]
+	}
+	return verifySignature(payload, sig, signer)
+}
+
+// signingPayload returns the bytes that are signed for an extrinsic payload. Payloads longer than 256 bytes are
+// signed by their blake2-256 hash.
+func signingPayload(payload types.ExtrinsicPayloadV4) ([]byte, error) {
+	b, err := types.EncodeToBytes(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 256 {
+		h := blake2b.Sum256(b)
+		b = h[:]
+	}
+	return b, nil
+}
+
+// signerAccount returns the account ID of the signer of an extrinsic.
+func signerAccount(address types.MultiAddress) ([]byte, error) {
+	switch {
+	case address.IsID:
+		return address.AsID[:], nil
+	case address.IsAddress32:
+		return address.AsAddress32[:], nil
+	}
+	return nil, fmt.Errorf("signer is not given by account ID")
+}
+
+// verifySignature checks a MultiSignature over a message against an account ID. For sr25519 and ed25519 the
+// account ID is the public key. For ecdsa it is the blake2-256 hash of the compressed public key, which is
+// recovered from the signature over the blake2-256 hash of the message.
+func verifySignature(message []byte, sig types.MultiSignature, account []byte) error {
+	if len(account) != 32 {
+		return fmt.Errorf("invalid account ID length %d", len(account))
+	}
+	var ok bool
+	switch {
+	case sig.IsSr25519:
+		var publicKey [32]byte
+		copy(publicKey[:], account)
+		var s schnorrkel.Signature
+		if err := s.Decode([64]byte(sig.AsSr25519)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		ok = schnorrkel.NewPublicKey(publicKey).Verify(&s, schnorrkel.NewSigningContext([]byte("substrate"), message))
+	case sig.IsEd25519:
+		ok = ed25519.Verify(account, message, sig.AsEd25519[:])
+	case sig.IsEcdsa:
+		if len(sig.AsEcdsa) != 65 {
+			return fmt.Errorf("%w: ecdsa signature length %d", ErrInvalidSignature, len(sig.AsEcdsa))
+		}
+		recoverable := append([]byte{}, sig.AsEcdsa...)
+		// Some signers give the recovery ID Ethereum style, as 27 or 28.
+		if recoverable[64] >= 27 {
+			recoverable[64] -= 27
+		}
+		digest := blake2b.Sum256(message)
+		publicKey, err := secp256k1.SigToPub(digest[:], recoverable)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		recovered := blake2b.Sum256(secp256k1.CompressPubkey(publicKey))
+		ok = bytes.Equal(recovered[:], account)
+	default:
+		return fmt.Errorf("unknown signature scheme")
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	secp256k1 "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestVerifyExtrinsic(t *testing.T) {
+	meta := testMetadata(t)
+	bob, err := types.HexDecodeString(BobPubkey)
+	assert.NoError(t, err)
+	transfer, err := types.NewCall(meta, "Balances.transfer", types.NewMultiAddressFromAccountID(bob), types.NewUCompactFromUInt(4200000000))
+	assert.NoError(t, err)
+	// Enough calls for the payload to be over 256 bytes, and so signed by its hash.
+	calls := []types.Call{}
+	for i := 0; i < 8; i++ {
+		calls = append(calls, transfer)
+	}
+	batch, err := NewDynamicCall(meta, "Utility.batch", CallArgs{"calls": calls})
+	assert.NoError(t, err)
+
+	genesis := types.Hash{1}
+	checkpoint := types.Hash{2}
+	for _, call := range []types.Call{transfer, batch} {
+		extrinsic := types.NewExtrinsic(call)
+		opts := types.SignatureOptions{
+			BlockHash:          checkpoint,
+			Era:                NewMortalEra(100, 64),
+			GenesisHash:        genesis,
+			Nonce:              types.NewUCompactFromUInt(3),
+			SpecVersion:        9000,
+			Tip:                types.NewUCompactFromUInt(0),
+			TransactionVersion: 8,
+		}
+		assert.NoError(t, signExtrinsic(&extrinsic, signature.TestKeyringPairAlice, opts))
+
+		assert.NoError(t, VerifyExtrinsic(extrinsic, genesis, 9000, 8, checkpoint))
+		assert.True(t, errors.Is(VerifyExtrinsic(extrinsic, genesis, 9001, 8, checkpoint), ErrInvalidSignature))
+		assert.True(t, errors.Is(VerifyExtrinsic(extrinsic, genesis, 9000, 8, genesis), ErrInvalidSignature))
+
+		tampered := extrinsic
+		tampered.Signature.Nonce = types.NewUCompactFromUInt(4)
+		assert.True(t, errors.Is(VerifyExtrinsic(tampered, genesis, 9000, 8, checkpoint), ErrInvalidSignature))
+		tampered = extrinsic
+		tampered.Signature.Signer = types.NewMultiAddressFromAccountID(bob)
+		assert.True(t, errors.Is(VerifyExtrinsic(tampered, genesis, 9000, 8, checkpoint), ErrInvalidSignature))
+	}
+
+	assert.Error(t, VerifyExtrinsic(types.NewExtrinsic(transfer), genesis, 9000, 8, checkpoint))
+}
+
+func TestVerifyPayload(t *testing.T) {
+	payload := []byte("payload to be signed")
+	long := make([]byte, 300)
+	digest := blake2b.Sum256(long)
+
+	// sr25519, as signed by signPayload.
+	alice := signature.TestKeyringPairAlice
+	sig, err := signature.Sign(digest[:], alice.URI)
+	assert.NoError(t, err)
+	sr25519 := types.MultiSignature{IsSr25519: true, AsSr25519: types.NewSignature(sig)}
+	assert.NoError(t, VerifyPayload(long, sr25519, alice.PublicKey))
+	assert.True(t, errors.Is(VerifyPayload(payload, sr25519, alice.PublicKey), ErrInvalidSignature))
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	ed := types.MultiSignature{IsEd25519: true, AsEd25519: types.NewSignature(ed25519.Sign(privateKey, payload))}
+	assert.NoError(t, VerifyPayload(payload, ed, publicKey))
+	assert.True(t, errors.Is(VerifyPayload(payload, ed, alice.PublicKey), ErrInvalidSignature))
+
+	key, err := secp256k1.GenerateKey()
+	assert.NoError(t, err)
+	account := blake2b.Sum256(secp256k1.CompressPubkey(&key.PublicKey))
+	payloadDigest := blake2b.Sum256(payload)
+	recoverable, err := secp256k1.Sign(payloadDigest[:], key)
+	assert.NoError(t, err)
+	ecdsa := types.MultiSignature{IsEcdsa: true, AsEcdsa: recoverable}
+	assert.NoError(t, VerifyPayload(payload, ecdsa, account[:]))
+	assert.True(t, errors.Is(VerifyPayload(long, ecdsa, account[:]), ErrInvalidSignature))
+	// Ethereum-style recovery IDs.
+	ethereum := append([]byte{}, recoverable...)
+	ethereum[64] += 27
+	assert.NoError(t, VerifyPayload(payload, types.MultiSignature{IsEcdsa: true, AsEcdsa: ethereum}, account[:]))
+	assert.Error(t, VerifyPayload(payload, types.MultiSignature{IsEcdsa: true, AsEcdsa: recoverable[:64]}, account[:]))
+}